@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/command"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/game"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/ledger"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/boardsecret"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/envelope"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/flowclient"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/flowevent"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/inbox"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/pubsub"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/registration"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/shop"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type backfillEvent struct {
+	route  flowevent.Route
+	height uint64
+	event  flow.Event
+}
+
+// Re-ingests BattleBlocks contract events of a block height range. The events
+// go through the same idempotent handlers as the subscriptions, events that
+// were already processed are skipped. Every event is printed with the
+// statements its handler ran. A dry run delivers every event in one
+// transaction that is rolled back at the end, so the events of a game are
+// checked against the changes of the ones before.
+//
+//	go run ./cmd/backfill -start 1000 -end 2000 -events Moved,GameOver -dry-run
+func main() {
+	start := flag.Uint64("start", 0, "first block height")
+	end := flag.Uint64("end", 0, "last block height, the latest sealed block by default")
+	events := flag.String("events", "", "comma separated event names, every BattleBlocks event by default")
+	chunk := flag.Uint64("chunk", 200, "block heights per access API query")
+	dryRun := flag.Bool("dry-run", false, "roll back every change")
+	flag.Parse()
+
+	viper.AutomaticEnv()
+	viper.SetConfigFile("./.env")
+	viper.ReadInConfig()
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+
+	ctx := context.Background()
+
+	db, err := gorm.Open(postgres.Open(viper.Get("DB_URL").(string)), &gorm.Config{})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize database")
+	}
+
+	flowClient, err := flowclient.NewFromConfig()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize flow access client")
+	}
+	defer flowClient.Close()
+
+	if *end == 0 {
+		*end, err = flowClient.GetLatestBlockHeight(ctx)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to fetch the latest block height")
+		}
+	}
+	if *start > *end || *chunk == 0 {
+		fmt.Fprintln(os.Stderr, "invalid block height range")
+		os.Exit(2)
+	}
+
+	var names []string
+	if *events != "" {
+		names = strings.Split(*events, ",")
+	}
+	routes, err := flowevent.RoutesFor(names)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	registerHandlers(ctx, db, flowClient)
+
+	collected, err := fetch(ctx, flowClient, routes, *start, *end, *chunk)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to fetch events")
+	}
+
+	mode := "applied"
+	if *dryRun {
+		mode = "would apply"
+	}
+	fmt.Printf("%d events between heights %d and %d\n", len(collected), *start, *end)
+
+	target := db
+	if *dryRun {
+		target = db.Begin()
+		if target.Error != nil {
+			log.Fatal().Err(target.Error).Msg("Failed to start the dry run transaction")
+		}
+		defer target.Rollback()
+	}
+
+	counts := map[string]int{}
+	for _, e := range collected {
+		status, statements := deliver(ctx, target, e, *dryRun)
+		if status == "" {
+			status = mode
+		}
+		counts[status]++
+
+		fmt.Printf("%d %s/%d %s: %s\n", e.height, e.event.TransactionID, e.event.EventIndex, e.route.Event, status)
+		for _, statement := range statements {
+			fmt.Printf("    %s\n", statement)
+		}
+	}
+
+	fmt.Printf("%d %s, %d already processed, %d failed\n", counts[mode], mode, counts["already processed"], len(collected)-counts[mode]-counts["already processed"])
+}
+
+// registerHandlers wires the bridges like the backend does, with a broker
+// that is never published to so no subscription is consumed
+func registerHandlers(ctx context.Context, db *gorm.DB, flowClient flowclient.Client) {
+	keyProvider, err := envelope.NewKeyProviderFromConfig(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize board key provider")
+	}
+
+	broker := pubsub.NewMemoryBroker()
+	routerGroup := gin.New().Group("/api")
+	stakeLedger := &ledger.LedgerService{Db: db, FlowClient: flowClient}
+
+	registration.RegisterRoutesAndSubscriptions(routerGroup, db, broker)
+	shop.RegisterRoutesAndSubscriptions(routerGroup, db, broker)
+	game.RegisterRoutes(routerGroup, db, broker, boardsecret.NewVault(keyProvider), stakeLedger)
+	command.RegisterRoutesAndSubscriptions(routerGroup, db, broker, stakeLedger)
+}
+
+// fetch returns the events of the routes in chain order
+func fetch(ctx context.Context, flowClient flowclient.Client, routes []flowevent.Route, start uint64, end uint64, chunk uint64) ([]backfillEvent, error) {
+	var collected []backfillEvent
+
+	for _, route := range routes {
+		for from := start; from <= end; from += chunk {
+			to := from + chunk - 1
+			if to > end {
+				to = end
+			}
+
+			blocks, err := flowClient.GetEventsForHeightRange(ctx, route.EventType(), from, to)
+			if err != nil {
+				return nil, fmt.Errorf("%s between heights %d and %d: %w", route.EventType(), from, to, err)
+			}
+
+			for _, block := range blocks {
+				for _, event := range block.Events {
+					collected = append(collected, backfillEvent{route: route, height: block.Height, event: event})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(collected, func(i, j int) bool {
+		a, b := collected[i], collected[j]
+		if a.height != b.height {
+			return a.height < b.height
+		}
+		if a.event.TransactionIndex != b.event.TransactionIndex {
+			return a.event.TransactionIndex < b.event.TransactionIndex
+		}
+		return a.event.EventIndex < b.event.EventIndex
+	})
+
+	return collected, nil
+}
+
+// deliver runs the handler of the event and returns the statements it ran,
+// the status is empty when the event was applied
+func deliver(ctx context.Context, db *gorm.DB, e backfillEvent, dryRun bool) (string, []string) {
+	message, err := flowevent.Message(e.event)
+	if err != nil {
+		return "failed: " + err.Error(), nil
+	}
+
+	recorder := &statementRecorder{}
+	session := db.Session(&gorm.Session{Logger: recorder})
+
+	duplicate, err := inbox.Deliver(ctx, session, e.route.Consumer, message, dryRun)
+	switch {
+	case err != nil:
+		return "failed: " + err.Error(), recorder.statements
+	case duplicate:
+		return "already processed", nil
+	}
+	return "", recorder.statements
+}
+
+// writes tells the statements of the handlers apart from reads and the
+// savepoints of a dry run
+func writes(sql string) bool {
+	statement := strings.ToUpper(strings.TrimSpace(sql))
+	for _, prefix := range []string{"SELECT", "SAVEPOINT", "ROLLBACK TO SAVEPOINT"} {
+		if strings.HasPrefix(statement, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// statementRecorder is a gorm logger keeping the statements that write
+type statementRecorder struct {
+	statements []string
+}
+
+func (r *statementRecorder) LogMode(logger.LogLevel) logger.Interface {
+	return r
+}
+
+func (r *statementRecorder) Info(context.Context, string, ...any) {}
+
+func (r *statementRecorder) Warn(context.Context, string, ...any) {}
+
+func (r *statementRecorder) Error(context.Context, string, ...any) {}
+
+func (r *statementRecorder) Trace(_ context.Context, _ time.Time, fc func() (string, int64), err error) {
+	sql, rows := fc()
+	if err != nil || !writes(sql) {
+		return
+	}
+	r.statements = append(r.statements, fmt.Sprintf("%s -- %d rows", sql, rows))
+}
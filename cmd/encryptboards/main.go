@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/boardsecret"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/envelope"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// Migrates game_grid_point from the plaintext block_present and nonce columns
+// to the encrypted secret column. Run it before starting a version that
+// encrypts boards; it can be run again after it stopped. The plaintext
+// columns are dropped once every board is encrypted, the last boards are
+// encrypted with the table locked so none is written in the meantime.
+func main() {
+	viper.AutomaticEnv()
+	viper.SetConfigFile("./.env")
+	viper.ReadInConfig()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	db, err := gorm.Open(postgres.Open(viper.Get("DB_URL").(string)), &gorm.Config{})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize database")
+	}
+
+	var plaintext int64
+	result := db.Raw(`SELECT count(*) FROM information_schema.columns
+		WHERE table_name = 'game_grid_point' AND column_name = 'block_present'`).Scan(&plaintext)
+	if result.Error != nil {
+		log.Fatal().Err(result.Error).Msg("Failed to read the game_grid_point columns")
+	}
+	if plaintext == 0 {
+		log.Info().Msg("Boards are encrypted already")
+		return
+	}
+
+	statements := []string{
+		"ALTER TABLE game_grid_point ADD COLUMN IF NOT EXISTS secret BYTEA",
+		`CREATE TABLE IF NOT EXISTS board_key (
+			game_id BIGINT NOT NULL,
+			user_id BIGINT NOT NULL,
+			key_id TEXT NOT NULL,
+			wrapped_key BYTEA NOT NULL,
+
+			PRIMARY KEY (game_id, user_id)
+		)`,
+		"CREATE INDEX IF NOT EXISTS board_key_key_id_idx ON board_key (key_id)",
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			log.Fatal().Err(err).Msg("Failed to add the encrypted board columns")
+		}
+	}
+
+	keyProvider, err := envelope.NewKeyProviderFromConfig(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize board key provider")
+	}
+	vault := boardsecret.NewVault(keyProvider)
+
+	encrypted, err := vault.EncryptPlaintext(ctx, db)
+	if err != nil {
+		log.Fatal().Err(err).Msg(fmt.Sprintf("Board encryption stopped after %d boards", encrypted))
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("LOCK TABLE game_grid_point IN EXCLUSIVE MODE").Error; err != nil {
+			return err
+		}
+
+		last, err := vault.EncryptPlaintext(ctx, tx)
+		encrypted += last
+		if err != nil {
+			return err
+		}
+
+		return tx.Exec(`ALTER TABLE game_grid_point
+			ALTER COLUMN secret SET NOT NULL,
+			DROP COLUMN block_present,
+			DROP COLUMN nonce`).Error
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to drop the plaintext board columns")
+	}
+
+	log.Info().Msg(fmt.Sprintf("Board encryption done, %d boards encrypted", encrypted))
+}
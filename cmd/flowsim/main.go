@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/flowsim"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/pubsub"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// Runs the Flow simulator next to a backend sharing the same Pub/Sub project
+// (or the Pub/Sub emulator) instead of the transaction service. The backend
+// cannot see the simulated balances from another process, run it with
+// FLOW_NETWORK=fake or use FLOW_NETWORK=simulator to run both in one process.
+func main() {
+	viper.AutomaticEnv()
+	viper.SetConfigFile("./.env")
+	viper.ReadInConfig()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	broker, err := pubsub.NewBrokerFromConfig(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize message broker")
+	}
+	defer broker.Close()
+
+	err = flowsim.New(broker, flowsim.OptionsFromConfig()).Run(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Flow simulator stopped")
+	}
+}
@@ -1,18 +1,32 @@
 package main
 
 import (
+	"context"
+	"expvar"
+
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/paypal"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/auth"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/command"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/cosign"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/deadletter"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/flowsim"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/game"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/ledger"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/notification"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/boardsecret"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/envelope"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/firebase"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/flowclient"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/middleware"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/outbox"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/pubsub"
+	pkgws "github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/ws"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/profile"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/reconcile"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/registration"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/shop"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/ws"
@@ -26,11 +40,17 @@ import (
 func main() {
 	setupViper()
 	setupZerolog()
-	pubsub.InitPubSub()
 	db := setupDb()
-	apiRouter := setupApiRouter(db)
+	broker := setupBroker()
+	boardSecrets := setupBoardSecrets()
+	flowClient := setupFlowClient(broker)
+	setupWebsocketHub(db, broker)
+	apiRouter := setupApiRouter(db, broker, boardSecrets, flowClient)
+
+	defer func() { broker.Close() }()
+	defer func() { flowClient.Close() }()
 
-	defer func() { pubsub.CloseClient() }()
+	go outbox.NewRelay(db, broker).Run(context.Background())
 
 	firebase.InitFirebaseSdk()
 
@@ -64,7 +84,68 @@ func setupDb() *gorm.DB {
 	return db
 }
 
-func setupApiRouter(db *gorm.DB) *gin.Engine {
+func setupBoardSecrets() *boardsecret.Vault {
+	keyProvider, err := envelope.NewKeyProviderFromConfig(context.Background())
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize board key provider")
+	}
+
+	return boardsecret.NewVault(keyProvider)
+}
+
+func setupBroker() pubsub.Broker {
+	broker, err := pubsub.NewBrokerFromConfig(context.Background())
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize message broker")
+	}
+
+	return broker
+}
+
+func setupWebsocketHub(db *gorm.DB, broker pubsub.Broker) {
+	hub := pkgws.NewNotificationHub()
+
+	eventLog, err := pkgws.NewEventLogFromConfig(db)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize websocket event log")
+	}
+	hub.UseEventLog(eventLog)
+
+	presence, err := pkgws.NewPresenceStoreFromConfig(db)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize websocket presence")
+	}
+	hub.UsePresence(context.Background(), presence)
+
+	fanout, err := pkgws.NewFanoutFromConfig(context.Background(), db, broker)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize websocket fanout")
+	}
+	if fanout != nil {
+		hub.UseFanout(context.Background(), fanout)
+	}
+}
+
+func setupFlowClient(broker pubsub.Broker) flowclient.Client {
+	if viper.GetString("FLOW_NETWORK") == flowsim.Network {
+		simulator := flowsim.New(broker, flowsim.OptionsFromConfig())
+		go func() {
+			if err := simulator.Run(context.Background()); err != nil {
+				log.Error().Err(err).Msg("Flow simulator stopped")
+			}
+		}()
+		return simulator.FlowClient()
+	}
+
+	flowClient, err := flowclient.NewFromConfig()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize flow access client")
+	}
+
+	return flowClient
+}
+
+func setupApiRouter(db *gorm.DB, broker pubsub.Broker, boardSecrets *boardsecret.Vault, flowClient flowclient.Client) *gin.Engine {
 	apiRouter := gin.Default()
 
 	// gcp health check
@@ -74,15 +155,25 @@ func setupApiRouter(db *gorm.DB) *gin.Engine {
 
 	middleware.RegisterGlobalMiddleware(apiRouter)
 	routerGroup := apiRouter.Group("/api")
+	routerGroup.GET("/admin/debug/vars", middleware.VerifyAuthToken, middleware.RequireAdmin, gin.WrapH(expvar.Handler()))
+	stakeLedger := &ledger.LedgerService{Db: db, FlowClient: flowClient}
 
-	ws.RegisterRoutes(routerGroup)
+	ws.RegisterRoutes(routerGroup, db)
 	auth.RegisterRoutes(routerGroup, db)
 	paypal.RegisterRoutes(routerGroup)
-	registration.RegisterRoutesAndSubscriptions(routerGroup, db)
+	registration.RegisterRoutesAndSubscriptions(routerGroup, db, broker)
 	profile.RegisterRoutes(routerGroup, db)
-	shop.RegisterRoutesAndSubscriptions(routerGroup, db)
-	game.RegisterRoutes(routerGroup, db)
+	shop.RegisterRoutesAndSubscriptions(routerGroup, db, broker)
+	ledger.RegisterRoutes(routerGroup, stakeLedger)
+	game.RegisterRoutes(routerGroup, db, broker, boardSecrets, stakeLedger)
 	cosign.RegisterRoutes(routerGroup, db)
+	command.RegisterRoutesAndSubscriptions(routerGroup, db, broker, stakeLedger)
+	deadletter.RegisterRoutes(routerGroup, db)
+	notification.RegisterRoutes(routerGroup, db)
+
+	reconciler := reconcile.NewReconciler(db, flowClient, stakeLedger)
+	reconcile.RegisterRoutes(routerGroup, db, reconciler)
+	go reconciler.Run(context.Background())
 
 	return apiRouter
 }
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/boardsecret"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/envelope"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// Rewraps board data keys with the current primary key of the configured key
+// provider. Run it after adding a new primary key to the local key file or
+// after rotating the KMS key, before retiring the old key (version).
+func main() {
+	viper.AutomaticEnv()
+	viper.SetConfigFile("./.env")
+	viper.ReadInConfig()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	db, err := gorm.Open(postgres.Open(viper.Get("DB_URL").(string)), &gorm.Config{})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize database")
+	}
+
+	keyProvider, err := envelope.NewKeyProviderFromConfig(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize board key provider")
+	}
+
+	rotated, err := boardsecret.NewVault(keyProvider).Rotate(ctx, db)
+	if err != nil {
+		log.Fatal().Err(err).Msg(fmt.Sprintf("Key rotation stopped after %d board keys", rotated))
+	}
+
+	log.Info().Msg(fmt.Sprintf("Key rotation done, %d board keys rewrapped", rotated))
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/money"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/reject"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/ws"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/wsevent"
+	"github.com/rs/zerolog/log"
+)
+
+type schema map[string]any
+
+var (
+	amountType  = reflect.TypeOf(money.Amount(0))
+	rawJSONType = reflect.TypeOf(json.RawMessage{})
+	problemType = reflect.TypeOf(reject.Problem{})
+)
+
+// Writes the JSON Schema of the websocket messages, generated from the event
+// catalogue. Every event is a definition of the schema, messages match one
+// of them.
+func main() {
+	out := flag.String("out", "docs/websocket-events.schema.json", "file the schema is written to, - for stdout")
+	flag.Parse()
+
+	data, err := json.MarshalIndent(buildSchema(), "", "  ")
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to encode schema")
+	}
+	data = append(data, '\n')
+
+	if *out == "-" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(*out, data, 0644); err != nil {
+		log.Fatal().Err(err).Msg("Failed to write schema")
+	}
+}
+
+func buildSchema() schema {
+	defs := schema{"Problem": typeSchema(problemType)}
+	var messages []any
+
+	for _, definition := range wsevent.Catalogue {
+		eventType := definition.Type()
+		defs[eventType] = envelopeSchema(definition)
+		messages = append(messages, schema{"$ref": "#/$defs/" + eventType})
+	}
+
+	for _, responseType := range []string{ws.AckType, ws.ProblemType} {
+		defs[responseType] = responseSchema(responseType)
+		messages = append(messages, schema{"$ref": "#/$defs/" + responseType})
+	}
+
+	return schema{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"title":       "Battleblocks websocket messages",
+		"description": "Messages sent to websocket and Server-Sent Events clients. Ids are database ids, gameId is never the Flow id of a game.",
+		"oneOf":       messages,
+		"$defs":       defs,
+	}
+}
+
+func envelopeSchema(definition wsevent.Definition) schema {
+	description := definition.Description
+	if len(definition.Topics) > 0 {
+		description += ". Topics: " + strings.Join(definition.Topics, ", ")
+	}
+
+	return schema{
+		"description": description,
+		"type":        "object",
+		"properties": schema{
+			"type":      schema{"const": definition.Type()},
+			"version":   schema{"const": definition.Version},
+			"topic":     schema{"type": "string"},
+			"seq":       schema{"type": "integer", "minimum": 1},
+			"timestamp": schema{"type": "integer", "description": "Unix milliseconds"},
+			"payload":   typeSchema(reflect.TypeOf(definition.Payload)),
+		},
+		"required": []string{"type", "version", "timestamp", "payload"},
+	}
+}
+
+func responseSchema(responseType string) schema {
+	payload := schema{"description": "Result of the command"}
+	if responseType == ws.ProblemType {
+		payload = schema{"$ref": "#/$defs/Problem"}
+	}
+
+	return schema{
+		"description": "Response to the command with the same id",
+		"type":        "object",
+		"properties": schema{
+			"id":      schema{"type": "string"},
+			"type":    schema{"const": responseType},
+			"payload": payload,
+		},
+		"required": []string{"id", "type"},
+	}
+}
+
+func typeSchema(t reflect.Type) schema {
+	switch t {
+	case amountType:
+		return schema{"type": "number", "description": "FLOW amount with up to 8 decimals"}
+	case rawJSONType:
+		return schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := typeSchema(t.Elem())
+		if kind, ok := s["type"].(string); ok {
+			s["type"] = []string{kind, "null"}
+		}
+		return s
+	case reflect.Bool:
+		return schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return schema{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return schema{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return schema{"type": "number"}
+	case reflect.String:
+		return schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return schema{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return schema{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	}
+	return schema{}
+}
+
+func structSchema(t reflect.Type) schema {
+	properties := schema{}
+	required := []string{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = typeSchema(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	return schema{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
@@ -1,6 +1,6 @@
 module github.com/kollektive-hackathon/battleblocks-backend
 
-go 1.18
+go 1.20
 
 require (
 	cloud.google.com/go/kms v1.6.0
@@ -10,6 +10,7 @@ require (
 	github.com/gin-gonic/gin v1.9.0
 	github.com/google/uuid v1.3.0
 	github.com/gorilla/websocket v1.4.1-0.20190629185528-ae1634f6a989
+	github.com/jackc/pgx/v5 v5.3.0
 	github.com/jpillora/backoff v1.0.0
 	github.com/onflow/cadence v0.31.3
 	github.com/onflow/flow-go-sdk v0.33.0
@@ -17,6 +18,7 @@ require (
 	github.com/spf13/viper v1.15.0
 	github.com/txaty/go-merkletree v0.1.15
 	github.com/wealdtech/go-merkletree v1.0.0
+	google.golang.org/grpc v1.52.0
 	gorm.io/driver/postgres v1.4.8
 	gorm.io/gorm v1.24.5
 )
@@ -54,7 +56,6 @@ require (
 	github.com/hashicorp/hcl v1.0.0 // indirect
 	github.com/jackc/pgpassfile v1.0.0 // indirect
 	github.com/jackc/pgservicefile v0.0.0-20221227161230-091c0ba34f0a // indirect
-	github.com/jackc/pgx/v5 v5.3.0 // indirect
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	github.com/jinzhu/now v1.1.5 // indirect
 	github.com/json-iterator/go v1.1.12 // indirect
@@ -103,7 +104,6 @@ require (
 	google.golang.org/appengine v1.6.7 // indirect
 	google.golang.org/appengine/v2 v2.0.2 // indirect
 	google.golang.org/genproto v0.0.0-20221227171554-f9683d7f8bef // indirect
-	google.golang.org/grpc v1.52.0 // indirect
 	google.golang.org/protobuf v1.28.1 // indirect
 	gopkg.in/ini.v1 v1.67.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
@@ -0,0 +1,46 @@
+package command
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/ledger"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/middleware"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/pubsub"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/utils"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/ws"
+	"gorm.io/gorm"
+)
+
+type commandHandler struct {
+	command *commandService
+}
+
+func RegisterRoutesAndSubscriptions(rg *gin.RouterGroup, db *gorm.DB, broker pubsub.Broker, ledger *ledger.LedgerService) {
+	handler := commandHandler{
+		command: &commandService{
+			db: db,
+			bridge: &transactionStatusBridge{
+				broker:          broker,
+				db:              db,
+				notificationHub: ws.NewNotificationHub(),
+				ledger:          ledger,
+			},
+		},
+	}
+
+	routes := rg.Group("/commands")
+	routes.GET("/:id", middleware.VerifyAuthToken, handler.getCommand)
+
+	handler.command.bridge.subscribe()
+}
+
+func (h commandHandler) getCommand(c *gin.Context) {
+	command, err := h.command.findById(c.Param("id"), utils.GetUserEmail(c))
+	if err != nil {
+		c.JSON(err.Problem.Status, err.Problem)
+		return
+	}
+
+	c.JSON(http.StatusOK, command)
+}
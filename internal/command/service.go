@@ -0,0 +1,68 @@
+package command
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/model"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/reject"
+	"gorm.io/gorm"
+)
+
+type commandService struct {
+	db     *gorm.DB
+	bridge *transactionStatusBridge
+}
+
+type CommandView struct {
+	model.BlockchainCommand
+	Payload json.RawMessage `json:"payload"`
+}
+
+func (s *commandService) findById(commandId string, userEmail string) (*CommandView, *reject.ProblemWithTrace) {
+	var address *string
+	result := s.db.Raw(`SELECT cw.address FROM battleblocks_user bu
+		JOIN custodial_wallet cw ON bu.custodial_wallet_id = cw.id
+		WHERE bu.email = ?`, userEmail).Scan(&address)
+
+	if result.Error != nil {
+		return nil, &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(result.Error),
+			Cause:   result.Error,
+		}
+	}
+
+	if address == nil {
+		err := errors.New("user has no custodial wallet address")
+		return nil, &reject.ProblemWithTrace{
+			Problem: reject.NotFoundProblem(),
+			Cause:   err,
+		}
+	}
+
+	// users only see commands their own wallet had to authorize
+	authorizerFilter, _ := json.Marshal([]map[string]string{{"resourceOwnerAddress": *address}})
+
+	var command model.BlockchainCommand
+	result = s.db.
+		Where("id = ? AND authorizers @> ?::jsonb", commandId, string(authorizerFilter)).
+		First(&command)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, &reject.ProblemWithTrace{
+			Problem: reject.NotFoundProblem(),
+			Cause:   result.Error,
+		}
+	}
+	if result.Error != nil {
+		return nil, &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(result.Error),
+			Cause:   result.Error,
+		}
+	}
+
+	return &CommandView{
+		BlockchainCommand: command,
+		Payload:           json.RawMessage(command.Payload),
+	}, nil
+}
@@ -0,0 +1,124 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/ledger"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/blockchain"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/inbox"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/model"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/pubsub"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/utils"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/ws"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/wsevent"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+type TransactionSubmitted struct {
+	CommandId     string `json:"commandId"`
+	TransactionId string `json:"transactionId"`
+}
+
+type TransactionFailed struct {
+	CommandId     string `json:"commandId"`
+	TransactionId string `json:"transactionId"`
+	Error         string `json:"error"`
+}
+
+type transactionStatusBridge struct {
+	broker          pubsub.Broker
+	db              *gorm.DB
+	notificationHub *ws.WebSocketNotificationHub
+	ledger          *ledger.LedgerService
+}
+
+func (b *transactionStatusBridge) handleTransactionSubmitted(_ context.Context, event *inbox.Event) error {
+	messagePayload, err := utils.JsonDecodeByteStream[TransactionSubmitted](event.Message.Data)
+	if err != nil {
+		log.Warn().Err(err).Msg("Error while parsing TransactionSubmitted message")
+		return inbox.Permanent(err)
+	}
+
+	return blockchain.MarkSubmitted(event.Tx, messagePayload.CommandId, messagePayload.TransactionId)
+}
+
+func (b *transactionStatusBridge) handleTransactionFailed(_ context.Context, event *inbox.Event) error {
+	messagePayload, err := utils.JsonDecodeByteStream[TransactionFailed](event.Message.Data)
+	if err != nil {
+		log.Warn().Err(err).Msg("Error while parsing TransactionFailed message")
+		return inbox.Permanent(err)
+	}
+
+	command, err := blockchain.MarkFailed(event.Tx, messagePayload.CommandId, messagePayload.TransactionId, messagePayload.Error)
+	if err != nil || command == nil {
+		return err
+	}
+
+	err = b.releaseStakes(event.Tx, command)
+	if err != nil {
+		return err
+	}
+
+	// game command references are the game websocket topics
+	if command.Reference != nil && strings.HasPrefix(*command.Reference, "game/") {
+		event.AfterCommit(func() {
+			b.notificationHub.Publish(*command.Reference, wsevent.CommandFailed{
+				CommandId:   command.Id,
+				CommandType: command.Type,
+				Error:       messagePayload.Error,
+			})
+		})
+	}
+	return nil
+}
+
+// a game that could not be created or joined on-chain never locks the stake
+func (b *transactionStatusBridge) releaseStakes(tx *gorm.DB, command *model.BlockchainCommand) error {
+	if command.Reference == nil {
+		return nil
+	}
+
+	var gameId uint64
+	if _, err := fmt.Sscanf(*command.Reference, "game/%d", &gameId); err != nil {
+		return nil
+	}
+
+	switch command.Type {
+	case blockchain.CommandGameCreate:
+		return b.ledger.ReleaseGame(tx, gameId)
+	case blockchain.CommandGameJoin:
+		userId, err := authorizerUserId(tx, command)
+		if err != nil {
+			return err
+		}
+		return b.ledger.ReleaseChallenger(tx, gameId, userId)
+	}
+
+	return nil
+}
+
+// authorizerUserId is the user whose custodial wallet authorized the command
+func authorizerUserId(tx *gorm.DB, command *model.BlockchainCommand) (uint64, error) {
+	var authorizers []blockchain.Authorizer
+	if err := json.Unmarshal([]byte(command.Authorizers), &authorizers); err != nil {
+		return 0, err
+	}
+	if len(authorizers) == 0 {
+		return 0, fmt.Errorf("command %s has no authorizer", command.Id)
+	}
+
+	var userId uint64
+	result := tx.Raw(`SELECT bu.id FROM battleblocks_user bu
+		JOIN custodial_wallet cw ON bu.custodial_wallet_id = cw.id
+		WHERE cw.address = ?`, authorizers[0].ResourceOwnerAddress).First(&userId)
+	return userId, result.Error
+}
+
+func (b *transactionStatusBridge) subscribe() {
+	inbox.Subscribe(b.broker, b.db, "blockchain.flow.transactions.submitted-sub", b.handleTransactionSubmitted)
+	inbox.Subscribe(b.broker, b.db, "blockchain.flow.transactions.failed-sub", b.handleTransactionFailed)
+}
@@ -0,0 +1,99 @@
+package deadletter
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/middleware"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/reject"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/utils"
+	"gorm.io/gorm"
+)
+
+type deadLetterHandler struct {
+	deadLetters *deadLetterService
+}
+
+func RegisterRoutes(rg *gin.RouterGroup, db *gorm.DB) {
+	handler := deadLetterHandler{
+		deadLetters: &deadLetterService{db: db},
+	}
+
+	routes := rg.Group("/admin/dead-letters", middleware.VerifyAuthToken, middleware.RequireAdmin)
+	routes.GET("", handler.getDeadLetters)
+	routes.GET("/:id", handler.getDeadLetter)
+	routes.POST("/:id/replay", handler.replay)
+	routes.DELETE("/:id", handler.discard)
+}
+
+func (h deadLetterHandler) getDeadLetters(c *gin.Context) {
+	page, err := utils.NewPageRequest(c)
+	if err != nil {
+		c.JSON(err.Problem.Status, err.Problem)
+		return
+	}
+
+	deadLetters, count, err := h.deadLetters.findAll(page, c.Query("status"))
+	if err != nil {
+		c.JSON(err.Problem.Status, err.Problem)
+		return
+	}
+
+	response := utils.NewPageResponse[DeadLetterView]().
+		WithItems(deadLetters).
+		WithItemCount(count)
+
+	if int(count) > (page.Token+1)*page.Size {
+		response.WithNextPageToken(int64(page.Token + 1))
+	}
+
+	c.JSON(http.StatusOK, response.Build())
+}
+
+func (h deadLetterHandler) getDeadLetter(c *gin.Context) {
+	id, parseErr := strconv.ParseUint(c.Param("id"), 0, 64)
+	if parseErr != nil {
+		c.JSON(http.StatusBadRequest, reject.RequestParamsProblem())
+		return
+	}
+
+	deadLetter, err := h.deadLetters.findById(id)
+	if err != nil {
+		c.JSON(err.Problem.Status, err.Problem)
+		return
+	}
+
+	c.JSON(http.StatusOK, deadLetter)
+}
+
+func (h deadLetterHandler) replay(c *gin.Context) {
+	id, parseErr := strconv.ParseUint(c.Param("id"), 0, 64)
+	if parseErr != nil {
+		c.JSON(http.StatusBadRequest, reject.RequestParamsProblem())
+		return
+	}
+
+	deadLetter, err := h.deadLetters.replay(id)
+	if err != nil {
+		c.JSON(err.Problem.Status, err.Problem)
+		return
+	}
+
+	c.JSON(http.StatusOK, deadLetter)
+}
+
+func (h deadLetterHandler) discard(c *gin.Context) {
+	id, parseErr := strconv.ParseUint(c.Param("id"), 0, 64)
+	if parseErr != nil {
+		c.JSON(http.StatusBadRequest, reject.RequestParamsProblem())
+		return
+	}
+
+	if err := h.deadLetters.discard(id); err != nil {
+		c.JSON(err.Problem.Status, err.Problem)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
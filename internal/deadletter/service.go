@@ -0,0 +1,157 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/inbox"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/model"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/reject"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	notReplayable = "error.dead-letter.not-replayable"
+	replayFailed  = "error.dead-letter.replay-failed"
+)
+
+type deadLetterService struct {
+	db *gorm.DB
+}
+
+type DeadLetterView struct {
+	model.DeadLetter
+	Payload    string            `json:"payload"`
+	Attributes map[string]string `json:"attributes"`
+}
+
+func (s *deadLetterService) findAll(page utils.PageRequest, status string) ([]DeadLetterView, int64, *reject.ProblemWithTrace) {
+	query := s.db.Model(&model.DeadLetter{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var count int64
+	result := query.Count(&count)
+	if result.Error != nil {
+		return nil, 0, &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(result.Error),
+			Cause:   result.Error,
+		}
+	}
+
+	var deadLetters []model.DeadLetter
+	result = query.
+		Order("created_at DESC, id DESC").
+		Limit(page.Size).
+		Offset(page.Offset).
+		Find(&deadLetters)
+
+	if result.Error != nil {
+		return nil, 0, &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(result.Error),
+			Cause:   result.Error,
+		}
+	}
+
+	views := make([]DeadLetterView, 0, len(deadLetters))
+	for _, deadLetter := range deadLetters {
+		views = append(views, toView(deadLetter))
+	}
+
+	return views, count, nil
+}
+
+func (s *deadLetterService) findById(id uint64) (*DeadLetterView, *reject.ProblemWithTrace) {
+	deadLetter, problem := s.load(id)
+	if problem != nil {
+		return nil, problem
+	}
+
+	view := toView(*deadLetter)
+	return &view, nil
+}
+
+func (s *deadLetterService) replay(id uint64) (*DeadLetterView, *reject.ProblemWithTrace) {
+	deadLetter, problem := s.load(id)
+	if problem != nil {
+		return nil, problem
+	}
+
+	err := inbox.Replay(context.Background(), s.db, *deadLetter)
+	if errors.Is(err, inbox.ErrNotReplayable) {
+		return nil, &reject.ProblemWithTrace{
+			Problem: reject.NewProblem().
+				WithTitle("Dead letter was already replayed").
+				WithStatus(http.StatusConflict).
+				WithCode(notReplayable).
+				Build(),
+			Cause: err,
+		}
+	}
+	if err != nil {
+		return nil, &reject.ProblemWithTrace{
+			Problem: reject.NewProblem().
+				WithTitle("Replay failed").
+				WithStatus(http.StatusUnprocessableEntity).
+				WithCode(replayFailed).
+				WithDetail(err.Error()).
+				Build(),
+			Cause: err,
+		}
+	}
+
+	return s.findById(id)
+}
+
+func (s *deadLetterService) discard(id uint64) *reject.ProblemWithTrace {
+	result := s.db.Delete(&model.DeadLetter{}, id)
+	if result.Error != nil {
+		return &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(result.Error),
+			Cause:   result.Error,
+		}
+	}
+	if result.RowsAffected == 0 {
+		return &reject.ProblemWithTrace{
+			Problem: reject.NotFoundProblem(),
+			Cause:   gorm.ErrRecordNotFound,
+		}
+	}
+
+	return nil
+}
+
+func (s *deadLetterService) load(id uint64) (*model.DeadLetter, *reject.ProblemWithTrace) {
+	var deadLetter model.DeadLetter
+	result := s.db.First(&deadLetter, id)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, &reject.ProblemWithTrace{
+			Problem: reject.NotFoundProblem(),
+			Cause:   result.Error,
+		}
+	}
+	if result.Error != nil {
+		return nil, &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(result.Error),
+			Cause:   result.Error,
+		}
+	}
+
+	return &deadLetter, nil
+}
+
+func toView(deadLetter model.DeadLetter) DeadLetterView {
+	attributes := map[string]string{}
+	json.Unmarshal([]byte(deadLetter.Attributes), &attributes)
+
+	return DeadLetterView{
+		DeadLetter: deadLetter,
+		Payload:    string(deadLetter.Payload),
+		Attributes: attributes,
+	}
+}
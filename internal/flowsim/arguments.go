@@ -0,0 +1,191 @@
+package flowsim
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/money"
+	"github.com/onflow/cadence"
+	jsoncdc "github.com/onflow/cadence/encoding/json"
+)
+
+// arguments are the decoded JSON-CDC arguments of a command, read in the
+// order the Cadence transaction declares them
+type arguments struct {
+	values []cadence.Value
+	err    error
+}
+
+func decodeArguments(payload []json.RawMessage) (*arguments, error) {
+	values := make([]cadence.Value, len(payload))
+	for i, raw := range payload {
+		value, err := jsoncdc.Decode(nil, raw)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %w", i, err)
+		}
+		values[i] = value
+	}
+
+	return &arguments{values: values}, nil
+}
+
+// value returns the argument at index, the first error is kept and reported
+// by Err so a transaction reads all its arguments before checking
+func (a *arguments) value(index int) cadence.Value {
+	if a.err != nil {
+		return nil
+	}
+	if index >= len(a.values) {
+		a.err = fmt.Errorf("missing argument %d", index)
+		return nil
+	}
+	return a.values[index]
+}
+
+func (a *arguments) fail(index int, expected string, value cadence.Value) {
+	if a.err == nil {
+		a.err = fmt.Errorf("argument %d: expected %s, got %v", index, expected, value)
+	}
+}
+
+func (a *arguments) Err() error {
+	return a.err
+}
+
+func (a *arguments) uint64(index int) uint64 {
+	value := a.value(index)
+	if value == nil {
+		return 0
+	}
+	v, ok := value.(cadence.UInt64)
+	if !ok {
+		a.fail(index, "UInt64", value)
+	}
+	return uint64(v)
+}
+
+func (a *arguments) ufix64(index int) money.Amount {
+	value := a.value(index)
+	if value == nil {
+		return 0
+	}
+	v, ok := value.(cadence.UFix64)
+	if !ok {
+		a.fail(index, "UFix64", value)
+	}
+	return money.FromUFix64(v)
+}
+
+func (a *arguments) string(index int) string {
+	value := a.value(index)
+	if value == nil {
+		return ""
+	}
+	v, ok := value.(cadence.String)
+	if !ok {
+		a.fail(index, "String", value)
+	}
+	return string(v)
+}
+
+func (a *arguments) address(index int) string {
+	value := a.value(index)
+	if value == nil {
+		return ""
+	}
+	v, ok := value.(cadence.Address)
+	if !ok {
+		a.fail(index, "Address", value)
+	}
+	return v.String()
+}
+
+func (a *arguments) bytes(index int) []byte {
+	value := a.value(index)
+	if value == nil {
+		return nil
+	}
+	v, ok := value.(cadence.Array)
+	if !ok {
+		a.fail(index, "[UInt8]", value)
+		return nil
+	}
+	return a.toBytes(index, v)
+}
+
+func (a *arguments) bytesArray(index int) [][]byte {
+	value := a.value(index)
+	if value == nil {
+		return nil
+	}
+	v, ok := value.(cadence.Array)
+	if !ok {
+		a.fail(index, "[[UInt8]]", value)
+		return nil
+	}
+
+	result := make([][]byte, len(v.Values))
+	for i, element := range v.Values {
+		array, ok := element.(cadence.Array)
+		if !ok {
+			a.fail(index, "[[UInt8]]", value)
+			return nil
+		}
+		result[i] = a.toBytes(index, array)
+	}
+	return result
+}
+
+func (a *arguments) optionalUInt64(index int) *uint64 {
+	value := a.optional(index)
+	if value == nil {
+		return nil
+	}
+	v, ok := value.(cadence.UInt64)
+	if !ok {
+		a.fail(index, "UInt64?", value)
+		return nil
+	}
+	result := uint64(v)
+	return &result
+}
+
+func (a *arguments) optionalBool(index int) *bool {
+	value := a.optional(index)
+	if value == nil {
+		return nil
+	}
+	v, ok := value.(cadence.Bool)
+	if !ok {
+		a.fail(index, "Bool?", value)
+		return nil
+	}
+	result := bool(v)
+	return &result
+}
+
+func (a *arguments) optional(index int) cadence.Value {
+	value := a.value(index)
+	if value == nil {
+		return nil
+	}
+	v, ok := value.(cadence.Optional)
+	if !ok {
+		a.fail(index, "optional", value)
+		return nil
+	}
+	return v.Value
+}
+
+func (a *arguments) toBytes(index int, array cadence.Array) []byte {
+	result := make([]byte, len(array.Values))
+	for i, element := range array.Values {
+		b, ok := element.(cadence.UInt8)
+		if !ok {
+			a.fail(index, "UInt8", element)
+			return nil
+		}
+		result[i] = uint8(b)
+	}
+	return result
+}
@@ -0,0 +1,54 @@
+package flowsim
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/onflow/cadence"
+	jsoncdc "github.com/onflow/cadence/encoding/json"
+)
+
+func encode(t *testing.T, values ...cadence.Value) []json.RawMessage {
+	t.Helper()
+
+	payload := make([]json.RawMessage, len(values))
+	for i, value := range values {
+		encoded, err := jsoncdc.Encode(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload[i] = encoded
+	}
+	return payload
+}
+
+func TestArguments(t *testing.T) {
+	cases := []struct {
+		name    string
+		payload []cadence.Value
+		wantErr bool
+	}{
+		{name: "game id", payload: []cadence.Value{cadence.NewUInt64(7), cadence.NewOptional(nil)}},
+		{name: "missing argument", payload: []cadence.Value{cadence.NewUInt64(7)}, wantErr: true},
+		{name: "wrong type", payload: []cadence.Value{cadence.String("7"), cadence.NewOptional(nil)}, wantErr: true},
+		{name: "optional expected", payload: []cadence.Value{cadence.NewUInt64(7), cadence.NewUInt64(1)}, wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			args, err := decodeArguments(encode(t, c.payload...))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			gameId := args.uint64(0)
+			nonce := args.optionalUInt64(1)
+			if (args.Err() != nil) != c.wantErr {
+				t.Fatalf("got error %v, want error %t", args.Err(), c.wantErr)
+			}
+			if !c.wantErr && (gameId != 7 || nonce != nil) {
+				t.Errorf("read game %d and nonce %v", gameId, nonce)
+			}
+		})
+	}
+}
@@ -0,0 +1,502 @@
+package flowsim
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/blockchain"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/flowclient"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/flowevent"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/money"
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+)
+
+const boardSize = 10
+
+type gameState int
+
+const (
+	gameWaiting gameState = iota
+	gamePlaying
+	gameFinished
+)
+
+type coordinate struct {
+	x uint64
+	y uint64
+}
+
+type account struct {
+	address   string
+	publicKey string
+	balance   money.Amount
+}
+
+type nft struct {
+	id       uint64
+	owner    string
+	name     string
+	metadata map[string]string
+}
+
+type game struct {
+	id      uint64
+	payload uint64
+	wager   money.Amount
+	state   gameState
+	playerA string
+	playerB string
+	winner  string
+	roots   map[string][]byte
+	// turn of the next move, player A moves on odd turns
+	turn uint64
+	// guesses whose result the guessed player has not revealed yet
+	pendingGuesses map[string]*coordinate
+	hits           map[string]uint
+}
+
+// ledger is the state of the emulated contracts. It is not safe for
+// concurrent use, the Simulator serializes transactions.
+type ledger struct {
+	hitsToWin uint
+
+	accounts    map[string]*account
+	nfts        map[uint64]*nft
+	games       map[uint64]*game
+	playerIds   map[string]uint64
+	nextAddress uint64
+	nextNftId   uint64
+	nextGameId  uint64
+}
+
+func newLedger(hitsToWin uint) *ledger {
+	return &ledger{
+		hitsToWin:   hitsToWin,
+		accounts:    map[string]*account{},
+		nfts:        map[uint64]*nft{},
+		games:       map[uint64]*game{},
+		playerIds:   map[string]uint64{},
+		nextAddress: 0x1000,
+	}
+}
+
+// execute runs the transaction of the command and returns the events it
+// emitted. Transactions check their preconditions before changing the state
+// so a failed transaction leaves it as it was.
+func (l *ledger) execute(command blockchain.Command) ([]event, error) {
+	args, err := decodeArguments(command.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	switch command.Type {
+	case blockchain.CommandCreateUserAccount:
+		return l.createAccount(args)
+	case blockchain.CommandNftMint:
+		return l.mint(args)
+	case blockchain.CommandNftTransfer:
+		return l.transfer(args, signer(command))
+	case blockchain.CommandNftTransferAdmin:
+		return l.transfer(args, blockchain.GetAdminAuthorizer().ResourceOwnerAddress)
+	case blockchain.CommandNftBurn:
+		return l.burn(args)
+	case blockchain.CommandGameCreate:
+		return l.createGame(args, signer(command))
+	case blockchain.CommandGameJoin:
+		return l.joinGame(args, signer(command))
+	case blockchain.CommandGameMove:
+		return l.move(args, signer(command))
+	case blockchain.CommandGameResign:
+		return l.resign(args, signer(command))
+	default:
+		return nil, fmt.Errorf("unsupported command type %s", command.Type)
+	}
+}
+
+func (l *ledger) balance(address string) money.Amount {
+	if account, ok := l.accounts[normalizeAddress(address)]; ok {
+		return account.balance
+	}
+	return 0
+}
+
+func (l *ledger) gameState(gameId uint64) *flowclient.GameState {
+	g, ok := l.games[gameId]
+	if !ok {
+		return nil
+	}
+
+	state := &flowclient.GameState{
+		Turn:    g.turn,
+		PlayerA: g.playerA,
+		PlayerB: g.playerB,
+		Winner:  g.winner,
+	}
+	switch g.state {
+	case gameWaiting:
+		state.Status = flowclient.GameWaitingForChallenger
+	case gamePlaying:
+		state.Status = flowclient.GamePlaying
+	case gameFinished:
+		state.Status = flowclient.GameFinished
+	}
+	return state
+}
+
+func (l *ledger) createAccount(args *arguments) ([]event, error) {
+	publicKey := args.string(0)
+	funding := args.ufix64(1)
+	if err := args.Err(); err != nil {
+		return nil, err
+	}
+
+	var addressBytes [cadence.AddressLength]byte
+	binary.BigEndian.PutUint64(addressBytes[:], l.nextAddress)
+	address := cadence.Address(addressBytes).String()
+	l.nextAddress++
+	l.accounts[address] = &account{address: address, publicKey: publicKey, balance: funding}
+
+	return []event{{
+		topic:   topicAccountCreated,
+		payload: flowevent.AccountCreated{PublicKey: publicKey, Address: address},
+	}}, nil
+}
+
+func (l *ledger) mint(args *arguments) ([]event, error) {
+	recipient := args.address(0)
+	name := args.string(1)
+	metadata := args.value(2)
+	if err := args.Err(); err != nil {
+		return nil, err
+	}
+
+	l.nextNftId++
+	token := &nft{id: l.nextNftId, owner: recipient, name: name, metadata: map[string]string{}}
+	if dictionary, ok := metadata.(cadence.Dictionary); ok {
+		for _, pair := range dictionary.Pairs {
+			key, _ := pair.Key.(cadence.String)
+			value, _ := pair.Value.(cadence.String)
+			token.metadata[string(key)] = string(value)
+		}
+	}
+	l.nfts[token.id] = token
+
+	return []event{{
+		topic:   topicMinted,
+		payload: flowevent.Minted{To: recipient, Name: name, Id: token.id},
+	}}, nil
+}
+
+func (l *ledger) transfer(args *arguments, sender string) ([]event, error) {
+	recipient := args.address(0)
+	id := args.uint64(1)
+	if err := args.Err(); err != nil {
+		return nil, err
+	}
+
+	token, ok := l.nfts[id]
+	if !ok {
+		return nil, fmt.Errorf("missing NFT %d", id)
+	}
+	if token.owner != normalizeAddress(sender) {
+		return nil, fmt.Errorf("NFT %d is not owned by %s", id, sender)
+	}
+
+	token.owner = recipient
+	return nil, nil
+}
+
+func (l *ledger) burn(args *arguments) ([]event, error) {
+	id := args.uint64(0)
+	if err := args.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, ok := l.nfts[id]; !ok {
+		return nil, fmt.Errorf("missing NFT %d", id)
+	}
+
+	delete(l.nfts, id)
+	return nil, nil
+}
+
+func (l *ledger) createGame(args *arguments, creator string) ([]event, error) {
+	wager := args.ufix64(0)
+	root := args.bytes(1)
+	payload := args.uint64(2)
+	if err := args.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := l.withdraw(creator, wager); err != nil {
+		return nil, err
+	}
+
+	l.nextGameId++
+	g := &game{
+		id:             l.nextGameId,
+		payload:        payload,
+		wager:          wager,
+		state:          gameWaiting,
+		playerA:        creator,
+		roots:          map[string][]byte{creator: root},
+		turn:           1,
+		pendingGuesses: map[string]*coordinate{},
+		hits:           map[string]uint{},
+	}
+	l.games[g.id] = g
+
+	return []event{{
+		topic:       topicGameCreated,
+		orderingKey: gameOrderingKey(g.id),
+		payload: flowevent.GameCreated{
+			GameId:         g.id,
+			CreatorId:      l.playerId(creator),
+			CreatorAddress: creator,
+			Stake:          wager,
+			Payload:        payload,
+		},
+	}}, nil
+}
+
+func (l *ledger) joinGame(args *arguments, challenger string) ([]event, error) {
+	gameId := args.uint64(0)
+	wager := args.ufix64(1)
+	root := args.bytes(2)
+	if err := args.Err(); err != nil {
+		return nil, err
+	}
+
+	g, ok := l.games[gameId]
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("missing game %d", gameId)
+	case g.state != gameWaiting:
+		return nil, fmt.Errorf("game %d is not waiting for a challenger", gameId)
+	case g.playerA == challenger:
+		return nil, fmt.Errorf("cannot join own game %d", gameId)
+	case g.wager != wager:
+		return nil, fmt.Errorf("wager %s does not match the game wager %s", wager, g.wager)
+	}
+
+	if err := l.withdraw(challenger, wager); err != nil {
+		return nil, err
+	}
+
+	g.playerB = challenger
+	g.roots[challenger] = root
+	g.state = gamePlaying
+	l.playerId(challenger)
+
+	startTime := uint64(time.Now().Unix())
+	gameState := uint8(g.state)
+	return []event{{
+		topic:       topicChallengerJoined,
+		orderingKey: gameOrderingKey(g.id),
+		payload: flowevent.ChallengerJoined{
+			GameId:            g.id,
+			PlayerB:           g.playerB,
+			Turn:              uint8(g.turn),
+			StartTime:         &startTime,
+			Wager:             &g.wager,
+			PlayerA:           &g.playerA,
+			PlayerHitCount:    map[string]uint8{g.playerA: 0, g.playerB: 0},
+			GameState:         &gameState,
+			PlayerAMerkleRoot: g.roots[g.playerA],
+			PlayerBMerkleRoot: g.roots[g.playerB],
+		},
+	}}, nil
+}
+
+// move reveals whether the last guess of the opponent hit a block of the
+// mover, proven against the merkle root of the mover's board, and places the
+// guess of the mover
+func (l *ledger) move(args *arguments, mover string) ([]event, error) {
+	gameId := args.uint64(0)
+	guess := coordinate{x: args.uint64(1), y: args.uint64(2)}
+	proof := args.bytesArray(3)
+	blockPresent := args.optionalBool(4)
+	opponentGuessX := args.optionalUInt64(5)
+	opponentGuessY := args.optionalUInt64(6)
+	nonce := args.optionalUInt64(7)
+	if err := args.Err(); err != nil {
+		return nil, err
+	}
+
+	g, ok := l.games[gameId]
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("missing game %d", gameId)
+	case g.state != gamePlaying:
+		return nil, fmt.Errorf("game %d is not in progress", gameId)
+	case mover != g.player(g.turn):
+		return nil, fmt.Errorf("not the turn of %s in game %d", mover, gameId)
+	case guess.x >= boardSize || guess.y >= boardSize:
+		return nil, fmt.Errorf("guess (%d, %d) is outside the board", guess.x, guess.y)
+	}
+
+	opponent := g.opponent(mover)
+	hit := false
+	if pending := g.pendingGuesses[opponent]; pending != nil {
+		if blockPresent == nil || opponentGuessX == nil || opponentGuessY == nil || nonce == nil {
+			return nil, errors.New("the result of the opponent's guess must be revealed")
+		}
+		if *opponentGuessX != pending.x || *opponentGuessY != pending.y {
+			return nil, fmt.Errorf("revealed (%d, %d) instead of the opponent's guess (%d, %d)", *opponentGuessX, *opponentGuessY, pending.x, pending.y)
+		}
+
+		leaf := blockchain.CreateMerkleTreeNode(int32(pending.x), int32(pending.y), *blockPresent, strconv.FormatUint(*nonce, 10))
+		if !blockchain.VerifyMerkleProof(leaf.Field, proof, g.roots[mover]) {
+			return nil, errors.New("invalid merkle proof")
+		}
+		hit = *blockPresent
+	}
+
+	if hit {
+		g.hits[opponent]++
+	}
+	g.pendingGuesses[opponent] = nil
+	g.pendingGuesses[mover] = &guess
+	g.turn++
+
+	events := []event{{
+		topic:       topicMoveDone,
+		orderingKey: gameOrderingKey(g.id),
+		payload: flowevent.Moved{
+			GameId:        g.id,
+			PlayerId:      l.playerId(mover),
+			PlayerAddress: mover,
+			Turn:          g.turn,
+			X:             uint(guess.x),
+			Y:             uint(guess.y),
+		},
+	}}
+
+	if g.hits[opponent] >= l.hitsToWin {
+		over, err := l.finish(g, opponent)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, over)
+	}
+
+	return events, nil
+}
+
+func (l *ledger) resign(args *arguments, player string) ([]event, error) {
+	gameId := args.uint64(0)
+	if err := args.Err(); err != nil {
+		return nil, err
+	}
+
+	g, ok := l.games[gameId]
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("missing game %d", gameId)
+	case g.state != gamePlaying:
+		return nil, fmt.Errorf("game %d is not in progress", gameId)
+	case player != g.playerA && player != g.playerB:
+		return nil, fmt.Errorf("%s is not a player of game %d", player, gameId)
+	}
+
+	over, err := l.finish(g, g.opponent(player))
+	if err != nil {
+		return nil, err
+	}
+	return []event{over}, nil
+}
+
+func (l *ledger) finish(g *game, winner string) (event, error) {
+	pot, err := g.wager.Add(g.wager)
+	if err != nil {
+		return event{}, err
+	}
+	if err := l.deposit(winner, pot); err != nil {
+		return event{}, err
+	}
+	g.state = gameFinished
+	g.winner = winner
+
+	return event{
+		topic:       topicGameOver,
+		orderingKey: gameOrderingKey(g.id),
+		payload: flowevent.GameOver{
+			GameId:  g.id,
+			PlayerA: g.playerA,
+			PlayerB: g.playerB,
+			Winner:  winner,
+			PlayerHitCount: map[string]uint{
+				g.playerA: g.hits[g.playerA],
+				g.playerB: g.hits[g.playerB],
+			},
+		},
+	}, nil
+}
+
+func (l *ledger) withdraw(address string, amount money.Amount) error {
+	account, ok := l.accounts[address]
+	if !ok {
+		return fmt.Errorf("missing account %s", address)
+	}
+	if account.balance.Units() < amount.Units() {
+		return fmt.Errorf("balance %s of %s is lower than %s", account.balance, address, amount)
+	}
+
+	account.balance = account.balance.Sub(amount)
+	return nil
+}
+
+func (l *ledger) deposit(address string, amount money.Amount) error {
+	account, ok := l.accounts[address]
+	if !ok {
+		return fmt.Errorf("missing account %s", address)
+	}
+
+	balance, err := account.balance.Add(amount)
+	if err != nil {
+		return err
+	}
+	account.balance = balance
+	return nil
+}
+
+// playerId is the id the game contract assigns to a player on its first game
+func (l *ledger) playerId(address string) uint64 {
+	id, ok := l.playerIds[address]
+	if !ok {
+		id = uint64(len(l.playerIds) + 1)
+		l.playerIds[address] = id
+	}
+	return id
+}
+
+func (g *game) player(turn uint64) string {
+	if turn%2 == 1 {
+		return g.playerA
+	}
+	return g.playerB
+}
+
+func (g *game) opponent(player string) string {
+	if player == g.playerA {
+		return g.playerB
+	}
+	return g.playerA
+}
+
+// signer is the account whose resources the transaction uses, the admin
+// only pays the fees
+func signer(command blockchain.Command) string {
+	if len(command.Authorizers) == 0 {
+		return ""
+	}
+	return normalizeAddress(command.Authorizers[0].ResourceOwnerAddress)
+}
+
+func normalizeAddress(address string) string {
+	return cadence.Address(flow.HexToAddress(address)).String()
+}
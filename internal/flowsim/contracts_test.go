@@ -0,0 +1,371 @@
+package flowsim
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/blockchain"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/flowevent"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/model"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/money"
+	"github.com/txaty/go-merkletree"
+)
+
+var (
+	funding = money.Units(1_000_000_000)
+	wager   = money.Units(150_000_000)
+)
+
+// board is a player's grid with a block on every listed coordinate, the
+// nonces are derived from the coordinates
+type board struct {
+	points []model.GameGridPoint
+	tree   *merkletree.MerkleTree
+	leaves []merkletree.DataBlock
+}
+
+func newBoard(t *testing.T, blocks ...coordinate) *board {
+	t.Helper()
+
+	present := map[coordinate]bool{}
+	for _, block := range blocks {
+		present[block] = true
+	}
+
+	b := &board{}
+	for x := uint64(0); x < boardSize; x++ {
+		for y := uint64(0); y < boardSize; y++ {
+			b.points = append(b.points, model.GameGridPoint{
+				CoordinateX:  x,
+				CoordinateY:  y,
+				BlockPresent: present[coordinate{x: x, y: y}],
+				Nonce:        strconv.FormatUint(1000+x*boardSize+y, 10),
+			})
+		}
+	}
+
+	tree, leaves, err := blockchain.CreateMerkleTreeFromData(b.points)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.tree, b.leaves = tree, leaves
+	return b
+}
+
+// reveal is the part of a move proving the result of the opponent's guess
+// at c, claiming blockPresent
+func (b *board) reveal(t *testing.T, c coordinate, blockPresent bool) blockchain.GameMove {
+	t.Helper()
+
+	i := c.x*boardSize + c.y
+	proof, err := b.tree.Proof(b.leaves[i])
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce, _ := strconv.ParseUint(b.points[i].Nonce, 10, 64)
+
+	return blockchain.GameMove{
+		Proof:          proof.Siblings,
+		BlockPresent:   &blockPresent,
+		OpponentGuessX: &c.x,
+		OpponentGuessY: &c.y,
+		Nonce:          &nonce,
+	}
+}
+
+type testGame struct {
+	ledger  *ledger
+	id      uint64
+	playerA string
+	playerB string
+	boards  map[string]*board
+}
+
+// newTestGame starts a game between two funded players, both have a block
+// at (0, 0) only
+func newTestGame(t *testing.T, hitsToWin uint) *testGame {
+	t.Helper()
+
+	g := &testGame{ledger: newLedger(hitsToWin), boards: map[string]*board{}}
+	g.playerA = g.createAccount(t)
+	g.playerB = g.createAccount(t)
+	g.boards[g.playerA] = newBoard(t, coordinate{})
+	g.boards[g.playerB] = newBoard(t, coordinate{})
+
+	events := g.mustRun(t, g.playerA, blockchain.GameCreate{Wager: wager, MerkleRoot: g.boards[g.playerA].tree.Root, GameId: 1})
+	g.id = events[0].payload.(flowevent.GameCreated).GameId
+	g.mustRun(t, g.playerB, blockchain.GameJoin{FlowGameId: g.id, Wager: wager, MerkleRoot: g.boards[g.playerB].tree.Root})
+	return g
+}
+
+func (g *testGame) createAccount(t *testing.T) string {
+	t.Helper()
+
+	events := g.mustRun(t, "", blockchain.CreateUserAccount{PublicKey: "key", InitialFundingAmount: funding})
+	return events[0].payload.(flowevent.AccountCreated).Address
+}
+
+func (g *testGame) run(t *testing.T, signer string, payload blockchain.CommandPayload) ([]event, error) {
+	t.Helper()
+
+	command, err := blockchain.NewBlockchainCommand(payload, []blockchain.Authorizer{{ResourceOwnerAddress: signer}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g.ledger.execute(command)
+}
+
+func (g *testGame) mustRun(t *testing.T, signer string, payload blockchain.CommandPayload) []event {
+	t.Helper()
+
+	events, err := g.run(t, signer, payload)
+	if err != nil {
+		t.Fatalf("%s failed: %v", payload.CommandType(), err)
+	}
+	return events
+}
+
+// guess is a move of the player that reveals nothing, the first move of the
+// game
+func (g *testGame) guess(x, y uint64) blockchain.GameMove {
+	return blockchain.GameMove{FlowGameId: g.id, GuessX: x, GuessY: y}
+}
+
+func (g *testGame) revealingGuess(t *testing.T, player string, revealed coordinate, blockPresent bool, x, y uint64) blockchain.GameMove {
+	move := g.boards[player].reveal(t, revealed, blockPresent)
+	move.FlowGameId, move.GuessX, move.GuessY = g.id, x, y
+	return move
+}
+
+func TestJoinGame(t *testing.T) {
+	cases := []struct {
+		name    string
+		own     bool
+		joined  bool
+		wager   money.Amount
+		wantErr bool
+	}{
+		{name: "challenger", wager: wager},
+		{name: "own game", own: true, wager: wager, wantErr: true},
+		{name: "other wager", wager: money.Units(1), wantErr: true},
+		{name: "challenger joined already", joined: true, wager: wager, wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			g := &testGame{ledger: newLedger(1)}
+			owner := g.createAccount(t)
+			challenger := g.createAccount(t)
+
+			events := g.mustRun(t, owner, blockchain.GameCreate{Wager: wager, MerkleRoot: []byte{1}, GameId: 1})
+			gameId := events[0].payload.(flowevent.GameCreated).GameId
+			if c.joined {
+				g.mustRun(t, g.createAccount(t), blockchain.GameJoin{FlowGameId: gameId, Wager: wager, MerkleRoot: []byte{3}})
+			}
+
+			joiner := challenger
+			if c.own {
+				joiner = owner
+			}
+			_, err := g.run(t, joiner, blockchain.GameJoin{FlowGameId: gameId, Wager: c.wager, MerkleRoot: []byte{2}})
+			if (err != nil) != c.wantErr {
+				t.Fatalf("got error %v, want error %t", err, c.wantErr)
+			}
+
+			want := funding - wager
+			if c.wantErr && !c.own {
+				want = funding
+			}
+			if balance := g.ledger.balance(joiner); balance != want {
+				t.Errorf("balance of the joining player is %s, want %s", balance, want)
+			}
+		})
+	}
+}
+
+func TestMoveProof(t *testing.T) {
+	cases := []struct {
+		name string
+		// the move of player B answering the guess of player A at (0, 0)
+		move    func(t *testing.T, g *testGame) blockchain.GameMove
+		wantErr bool
+		wantHit bool
+	}{
+		{
+			name: "hit",
+			move: func(t *testing.T, g *testGame) blockchain.GameMove {
+				return g.revealingGuess(t, g.playerB, coordinate{}, true, 5, 5)
+			},
+			wantHit: true,
+		},
+		{
+			name: "claiming a miss on a block",
+			move: func(t *testing.T, g *testGame) blockchain.GameMove {
+				return g.revealingGuess(t, g.playerB, coordinate{}, false, 5, 5)
+			},
+			wantErr: true,
+		},
+		{
+			name: "proof of another coordinate",
+			move: func(t *testing.T, g *testGame) blockchain.GameMove {
+				move := g.revealingGuess(t, g.playerB, coordinate{}, true, 5, 5)
+				other := g.boards[g.playerB].reveal(t, coordinate{x: 1}, false)
+				move.Proof = other.Proof
+				return move
+			},
+			wantErr: true,
+		},
+		{
+			name: "board other than the committed one",
+			move: func(t *testing.T, g *testGame) blockchain.GameMove {
+				g.boards[g.playerB] = newBoard(t, coordinate{}, coordinate{x: 9, y: 9})
+				return g.revealingGuess(t, g.playerB, coordinate{}, true, 5, 5)
+			},
+			wantErr: true,
+		},
+		{
+			name: "revealing another coordinate",
+			move: func(t *testing.T, g *testGame) blockchain.GameMove {
+				return g.revealingGuess(t, g.playerB, coordinate{x: 1}, false, 5, 5)
+			},
+			wantErr: true,
+		},
+		{
+			name: "nothing revealed",
+			move: func(t *testing.T, g *testGame) blockchain.GameMove {
+				return g.guess(5, 5)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			g := newTestGame(t, 2)
+			g.mustRun(t, g.playerA, g.guess(0, 0))
+
+			_, err := g.run(t, g.playerB, c.move(t, g))
+			if (err != nil) != c.wantErr {
+				t.Fatalf("got error %v, want error %t", err, c.wantErr)
+			}
+
+			state := g.ledger.games[g.id]
+			if c.wantErr && state.turn != 2 {
+				t.Errorf("failed move changed the turn to %d", state.turn)
+			}
+			if hit := state.hits[g.playerA] == 1; hit != c.wantHit {
+				t.Errorf("player A hit %d blocks, want hit %t", state.hits[g.playerA], c.wantHit)
+			}
+		})
+	}
+}
+
+func TestMoveOrder(t *testing.T) {
+	cases := []struct {
+		name    string
+		mover   func(t *testing.T, g *testGame) string
+		x, y    uint64
+		wantErr bool
+	}{
+		{name: "player A first", mover: func(t *testing.T, g *testGame) string { return g.playerA }},
+		{name: "player B first", mover: func(t *testing.T, g *testGame) string { return g.playerB }, wantErr: true},
+		{name: "not a player", mover: func(t *testing.T, g *testGame) string { return g.createAccount(t) }, wantErr: true},
+		{name: "outside the board", mover: func(t *testing.T, g *testGame) string { return g.playerA }, x: boardSize, wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			g := newTestGame(t, 1)
+			_, err := g.run(t, c.mover(t, g), g.guess(c.x, c.y))
+			if (err != nil) != c.wantErr {
+				t.Errorf("got error %v, want error %t", err, c.wantErr)
+			}
+		})
+	}
+
+	t.Run("player A twice", func(t *testing.T) {
+		g := newTestGame(t, 1)
+		g.mustRun(t, g.playerA, g.guess(0, 0))
+		if _, err := g.run(t, g.playerA, g.guess(1, 1)); err == nil {
+			t.Error("player A moved on the turn of player B")
+		}
+	})
+
+	t.Run("before a challenger joined", func(t *testing.T) {
+		g := &testGame{ledger: newLedger(1)}
+		owner := g.createAccount(t)
+		events := g.mustRun(t, owner, blockchain.GameCreate{Wager: wager, MerkleRoot: []byte{1}, GameId: 1})
+		g.id = events[0].payload.(flowevent.GameCreated).GameId
+
+		if _, err := g.run(t, owner, g.guess(0, 0)); err == nil {
+			t.Error("moved in a game waiting for a challenger")
+		}
+	})
+}
+
+func TestGameCompletion(t *testing.T) {
+	cases := []struct {
+		name string
+		// plays until the game is over and returns its events
+		play       func(t *testing.T, g *testGame) []event
+		winner     func(g *testGame) string
+		wantEvents []string
+	}{
+		{
+			name: "last block hit",
+			play: func(t *testing.T, g *testGame) []event {
+				g.mustRun(t, g.playerA, g.guess(0, 0))
+				return g.mustRun(t, g.playerB, g.revealingGuess(t, g.playerB, coordinate{}, true, 5, 5))
+			},
+			winner:     func(g *testGame) string { return g.playerA },
+			wantEvents: []string{topicMoveDone, topicGameOver},
+		},
+		{
+			name: "resigned",
+			play: func(t *testing.T, g *testGame) []event {
+				return g.mustRun(t, g.playerA, blockchain.GameResign{FlowGameId: g.id})
+			},
+			winner:     func(g *testGame) string { return g.playerB },
+			wantEvents: []string{topicGameOver},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			g := newTestGame(t, 1)
+			events := c.play(t, g)
+
+			if len(events) != len(c.wantEvents) {
+				t.Fatalf("got %d events, want %v", len(events), c.wantEvents)
+			}
+			for i, topic := range c.wantEvents {
+				if events[i].topic != topic {
+					t.Errorf("event %d is on %s, want %s", i, events[i].topic, topic)
+				}
+			}
+
+			winner := c.winner(g)
+			over := events[len(events)-1].payload.(flowevent.GameOver)
+			if over.Winner != winner {
+				t.Errorf("winner is %s, want %s", over.Winner, winner)
+			}
+
+			state := g.ledger.gameState(g.id)
+			if state.Winner != winner {
+				t.Errorf("game state winner is %s, want %s", state.Winner, winner)
+			}
+
+			// the winner takes both stakes
+			if balance, want := g.ledger.balance(winner), funding+wager; balance != want {
+				t.Errorf("winner balance is %s, want %s", balance, want)
+			}
+			if balance, want := g.ledger.balance(g.ledger.games[g.id].opponent(winner)), funding-wager; balance != want {
+				t.Errorf("loser balance is %s, want %s", balance, want)
+			}
+
+			if _, err := g.run(t, g.playerB, blockchain.GameResign{FlowGameId: g.id}); err == nil {
+				t.Error("resigned a finished game")
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package flowsim
+
+import "fmt"
+
+// Topics the event service publishes Flow events to, one per event type.
+const (
+	topicAccountCreated   = "blockchain.flow.events.account-created"
+	topicGameCreated      = "blockchain.flow.events.game-created"
+	topicChallengerJoined = "blockchain.flow.events.challenger-joined"
+	topicMoveDone         = "blockchain.flow.events.move-done"
+	topicGameOver         = "blockchain.flow.events.game-over"
+	topicMinted           = "blockchain.flow.events.minted"
+
+	topicTransactionSubmitted = "blockchain.flow.transactions.submitted"
+	topicTransactionFailed    = "blockchain.flow.transactions.failed"
+)
+
+type transactionSubmitted struct {
+	CommandId     string `json:"commandId"`
+	TransactionId string `json:"transactionId"`
+}
+
+type transactionFailed struct {
+	CommandId     string `json:"commandId"`
+	TransactionId string `json:"transactionId"`
+	Error         string `json:"error"`
+}
+
+// event is an emitted contract event waiting to be published, game events
+// are ordered per game
+type event struct {
+	topic       string
+	orderingKey string
+	payload     any
+}
+
+func gameOrderingKey(gameId uint64) string {
+	return fmt.Sprintf("flow-game/%d", gameId)
+}
@@ -0,0 +1,179 @@
+package flowsim
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/blockchain"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/flowclient"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/inbox"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/pubsub"
+	"github.com/onflow/cadence"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// Network is the FLOW_NETWORK that runs the simulator inside the backend.
+const Network = "simulator"
+
+const (
+	defaultSubscriptionId = "blockchain.flow.commands-sub"
+	defaultHitsToWin      = 8
+)
+
+type Options struct {
+	// SubscriptionId is the subscription of the commands topic
+	SubscriptionId string
+	// HitsToWin is the number of block cells a player has to hit to win
+	HitsToWin uint
+}
+
+// OptionsFromConfig reads FLOWSIM_SUBSCRIPTION and FLOWSIM_HITS_TO_WIN.
+func OptionsFromConfig() Options {
+	options := Options{
+		SubscriptionId: viper.GetString("FLOWSIM_SUBSCRIPTION"),
+		HitsToWin:      viper.GetUint("FLOWSIM_HITS_TO_WIN"),
+	}
+	if options.SubscriptionId == "" {
+		options.SubscriptionId = defaultSubscriptionId
+	}
+	if options.HitsToWin == 0 {
+		options.HitsToWin = defaultHitsToWin
+	}
+	return options
+}
+
+// Simulator stands in for the transaction service and the Flow network. It
+// executes the commands the backend publishes against an in-memory emulation
+// of the BattleBlocks contracts and publishes the transaction statuses and
+// events the real pipeline would, so the backend runs without Flow.
+//
+// The state is lost when the process stops.
+type Simulator struct {
+	broker         pubsub.Broker
+	subscriptionId string
+
+	mu     sync.Mutex
+	ledger *ledger
+	// publications of executed commands, a redelivered command is published
+	// again instead of being executed twice
+	executed map[string][]pubsub.Publication
+}
+
+func New(broker pubsub.Broker, options Options) *Simulator {
+	// messages published before a memory subscription exists are dropped
+	if memoryBroker, ok := broker.(*pubsub.MemoryBroker); ok {
+		memoryBroker.Bind(blockchain.Command{}.GetEventTopicName(), options.SubscriptionId)
+	}
+
+	return &Simulator{
+		broker:         broker,
+		subscriptionId: options.SubscriptionId,
+		ledger:         newLedger(options.HitsToWin),
+		executed:       map[string][]pubsub.Publication{},
+	}
+}
+
+// Run consumes commands until the context is done.
+func (s *Simulator) Run(ctx context.Context) error {
+	log.Info().Msg(fmt.Sprintf("Flow simulator consuming %s", s.subscriptionId))
+	return s.broker.Subscribe(ctx, s.subscriptionId, s.handleCommand)
+}
+
+// FlowClient answers balance and game state scripts from the simulated
+// contracts.
+func (s *Simulator) FlowClient() *flowclient.Fake {
+	fake := flowclient.NewFake()
+	fake.OnFlowBalance(func(address cadence.Address) (cadence.UFix64, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		return s.ledger.balance(address.String()).UFix64(), nil
+	})
+	fake.OnGameState(func(flowGameId uint64) (*flowclient.GameState, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		return s.ledger.gameState(flowGameId), nil
+	})
+	return fake
+}
+
+func (s *Simulator) handleCommand(ctx context.Context, message *pubsub.Message) {
+	var command blockchain.Command
+	if err := json.Unmarshal(message.Data, &command); err != nil || command.Id == "" {
+		log.Warn().Err(err).Msg(fmt.Sprintf("Flow simulator dropped invalid command message %s", message.ID))
+		message.Ack()
+		return
+	}
+
+	for _, publication := range s.execute(command) {
+		if err := s.broker.Publish(ctx, publication); err != nil {
+			log.Warn().Err(err).Msg(fmt.Sprintf("Flow simulator cannot publish to %s for command %s", publication.Topic, command.Id))
+			message.Nack()
+			return
+		}
+	}
+
+	message.Ack()
+}
+
+func (s *Simulator) execute(command blockchain.Command) []pubsub.Publication {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if publications, ok := s.executed[command.Id]; ok {
+		return publications
+	}
+
+	transactionId := transactionIdOf(command)
+	attributes := map[string]string{
+		blockchain.CommandIdAttribute:     command.Id,
+		blockchain.TransactionIdAttribute: transactionId,
+	}
+
+	publications := []pubsub.Publication{{
+		Topic:      topicTransactionSubmitted,
+		Data:       pubsub.EncodeMessage(transactionSubmitted{CommandId: command.Id, TransactionId: transactionId}),
+		Attributes: attributes,
+	}}
+
+	events, err := s.ledger.execute(command)
+	if err != nil {
+		log.Info().Err(err).Msg(fmt.Sprintf("Flow simulator failed %s command %s", command.Type, command.Id))
+		publications = append(publications, pubsub.Publication{
+			Topic:      topicTransactionFailed,
+			Data:       pubsub.EncodeMessage(transactionFailed{CommandId: command.Id, TransactionId: transactionId, Error: err.Error()}),
+			Attributes: attributes,
+		})
+	}
+
+	for i, e := range events {
+		eventAttributes := map[string]string{inbox.EventIndexAttribute: strconv.Itoa(i)}
+		for k, v := range attributes {
+			eventAttributes[k] = v
+		}
+
+		publications = append(publications, pubsub.Publication{
+			Topic:       e.topic,
+			Data:        pubsub.EncodeMessage(e.payload),
+			Attributes:  eventAttributes,
+			OrderingKey: e.orderingKey,
+		})
+	}
+
+	s.executed[command.Id] = publications
+	return publications
+}
+
+// transactionIdOf derives a stable id so republished events keep their
+// event keys
+func transactionIdOf(command blockchain.Command) string {
+	hash := sha256.Sum256([]byte(command.Id))
+	return hex.EncodeToString(hash[:])
+}
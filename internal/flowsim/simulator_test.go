@@ -0,0 +1,57 @@
+package flowsim
+
+import (
+	"testing"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/blockchain"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/pubsub"
+)
+
+func newTestSimulator() *Simulator {
+	return New(pubsub.NewMemoryBroker(), Options{SubscriptionId: defaultSubscriptionId, HitsToWin: 1})
+}
+
+func topics(publications []pubsub.Publication) []string {
+	var topics []string
+	for _, publication := range publications {
+		topics = append(topics, publication.Topic)
+	}
+	return topics
+}
+
+func TestRedeliveredCommandIsNotExecutedAgain(t *testing.T) {
+	s := newTestSimulator()
+	command, err := blockchain.NewBlockchainCommand(blockchain.CreateUserAccount{PublicKey: "key", InitialFundingAmount: funding}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := s.execute(command)
+	second := s.execute(command)
+
+	if len(s.ledger.accounts) != 1 {
+		t.Errorf("created %d accounts, want 1", len(s.ledger.accounts))
+	}
+	if len(first) != len(second) {
+		t.Fatalf("published %v, then %v", topics(first), topics(second))
+	}
+	for i := range first {
+		if first[i].Topic != second[i].Topic || string(first[i].Data) != string(second[i].Data) {
+			t.Errorf("publication %d changed from %s to %s", i, first[i].Data, second[i].Data)
+		}
+	}
+}
+
+func TestFailedCommandPublishesTransactionFailed(t *testing.T) {
+	s := newTestSimulator()
+	command, err := blockchain.NewBlockchainCommand(blockchain.NftBurn{Id: 42}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := topics(s.execute(command))
+	want := []string{topicTransactionSubmitted, topicTransactionFailed}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("published %v, want %v", got, want)
+	}
+}
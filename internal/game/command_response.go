@@ -0,0 +1,5 @@
+package game
+
+type CommandResponse struct {
+	CommandId string `json:"commandId"`
+}
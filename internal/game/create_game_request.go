@@ -1,8 +1,11 @@
 package game
 
-import "github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/model"
+import (
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/model"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/money"
+)
 
 type CreateGameRequest struct {
-	Stake      float32           `json:"stake"`
+	Stake      money.Amount      `json:"stake"`
 	Placements []model.Placement `json:"placements"`
 }
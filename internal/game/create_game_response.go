@@ -0,0 +1,8 @@
+package game
+
+import "github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/model"
+
+type CreateGameResponse struct {
+	model.Game
+	CommandId string `json:"commandId"`
+}
@@ -1,235 +1,209 @@
 package game
 
 import (
-	"bytes"
 	"context"
-	"encoding/binary"
 	"fmt"
 	"time"
 
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/ledger"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/blockchain"
-	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/pubsub"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/boardsecret"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/flowevent"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/inbox"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/money"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/notification"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/ws"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/wsevent"
 
-	gcppubsub "cloud.google.com/go/pubsub"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/model"
-	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/utils"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/pubsub"
 	"github.com/rs/zerolog/log"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-type Moved struct {
-	GameId        uint64 `json:"gameID"`
-	PlayerId      uint64 `json:"gamePlayerID"`
-	PlayerAddress string `json:"playerAddress"`
-	Turn          uint64 `json:"turn"`
-	X             uint   `json:"coordinateX"`
-	Y             uint   `json:"coordinateY"`
-}
-
-type ChallengerJoined struct {
-	GameId    uint64 `json:"gameId"`
-	StartTime uint64 `json:"startTime"`
-	// Wager             float64          `json:"wager"`
-	PlayerA *string `json:"playerA"`
-	PlayerB *string `json:"playerB"`
-	// Winner            *string           `json:"winner"`
-	// PlayerHitCount    map[string]uint8 `json:"playerHitCount"`
-	// GameState         uint8            `json:"gameState"`
-	Turn uint8 `json:"turn"`
-	// PlayerAMerkleRoot []uint8          `json:"playerAMerkleRoot"`
-	// PlayerBMerkleRoot []uint8          `json:"playerBMerkleRoot"`
-}
-
-type GameOver struct {
-	GameId         uint64          `json:"gameID"`
-	PlayerA        string          `json:"playerA"`
-	PlayerB        string          `json:"playerB"`
-	Winner         string          `json:"winner"`
-	PlayerHitCount map[string]uint `json:"playerHitCount"`
-}
-
-type GameCreated struct {
-	GameId         uint64 `json:"gameID"`
-	CreatorId      uint64 `json:"creatorID"`
-	CreatorAddress string `json:"creatorAddress"`
-	Stake          uint64 `json:"wager"`
-	Payload        uint64 `json:"payload"`
-}
-
 type gameContractBridge struct {
+	broker          pubsub.Broker
 	db              *gorm.DB
 	notificationHub *ws.WebSocketNotificationHub
+	notifier        *notification.Notifier
+	boardSecrets    *boardsecret.Vault
+	ledger          *ledger.LedgerService
 }
 
-func (b *gameContractBridge) sendJoinGame(stake float32, rootMerkel []byte, gameId uint64, userAuthorizer blockchain.Authorizer) {
-	commandType := "GAME_JOIN"
-	uint8Merkle := byteArrayToUint(rootMerkel)
-	payload := []any{
-		gameId,
-		stake,
-		uint8Merkle,
+func (b *gameContractBridge) sendJoinGame(tx *gorm.DB, stake money.Amount, rootMerkel []byte, game model.Game, userAuthorizer blockchain.Authorizer) (string, error) {
+	payload := blockchain.GameJoin{
+		FlowGameId: *game.FlowId,
+		Wager:      stake,
+		MerkleRoot: rootMerkel,
 	}
 	authorizers := []blockchain.Authorizer{userAuthorizer, blockchain.GetAdminAuthorizer()}
-	cmd := blockchain.NewBlockchainCommand(commandType, payload, authorizers)
-	pubsub.Publish(cmd)
+	return b.dispatch(tx, payload, authorizers, game.Id)
 }
 
-func (b *gameContractBridge) sendCreateGameTx(stake float32, rootMerkel []byte, gameId uint64, userAuthorizer blockchain.Authorizer) {
-	commandType := "GAME_CREATE"
-
-	uint8Merkle := byteArrayToUint(rootMerkel)
-
-	payload := []any{
-		stake,
-		uint8Merkle,
-		gameId,
+func (b *gameContractBridge) sendCreateGameTx(tx *gorm.DB, stake money.Amount, rootMerkel []byte, gameId uint64, userAuthorizer blockchain.Authorizer) (string, error) {
+	payload := blockchain.GameCreate{
+		Wager:      stake,
+		MerkleRoot: rootMerkel,
+		GameId:     gameId,
 	}
 	authorizers := []blockchain.Authorizer{userAuthorizer, blockchain.GetAdminAuthorizer()}
-	cmd := blockchain.NewBlockchainCommand(commandType, payload, authorizers)
-	pubsub.Publish(cmd)
+	return b.dispatch(tx, payload, authorizers, gameId)
 }
 
-func (b *gameContractBridge) sendMove(
-	gameId uint64,
-	guessX uint64,
-	guessY uint64,
-	proof [][]uint8,
-	blockPresent *bool,
-	opponentGuessX *uint64,
-	opponentGuessY *uint64,
-	nonce *uint64,
-	userAuthorizer blockchain.Authorizer,
-) {
-	var uint64Proof [][]uint64
-	if proof != nil {
-		uint64Proof = twoDimensionalbyteArrayToTwoDimensionalUint64Array(proof)
-	}
-
-	commandType := "GAME_MOVE"
-	payload := []any{
-		gameId,
-		guessX,
-		guessY,
-		uint64Proof,
-		blockPresent,
-		opponentGuessX,
-		opponentGuessY,
-		nonce,
-	}
-
-	log.Info().Interface("payload", payload).Msg("Senging Move Transaction to TX Service:")
+func (b *gameContractBridge) sendMove(game model.Game, move blockchain.GameMove, userAuthorizer blockchain.Authorizer) (string, error) {
+	move.FlowGameId = *game.FlowId
+
+	log.Info().Interface("payload", move).Msg("Senging Move Transaction to TX Service:")
+
+	authorizers := []blockchain.Authorizer{userAuthorizer, blockchain.GetAdminAuthorizer()}
+	return b.dispatch(b.db, move, authorizers, game.Id)
+}
 
+func (b *gameContractBridge) sendResign(game model.Game, userAuthorizer blockchain.Authorizer) (string, error) {
+	payload := blockchain.GameResign{FlowGameId: *game.FlowId}
 	authorizers := []blockchain.Authorizer{userAuthorizer, blockchain.GetAdminAuthorizer()}
-	cmd := blockchain.NewBlockchainCommand(commandType, payload, authorizers)
-	pubsub.Publish(cmd)
+	return b.dispatch(b.db, payload, authorizers, game.Id)
 }
 
-func (b *gameContractBridge) handleMoved(_ context.Context, message *gcppubsub.Message) {
-	log.Info().Msg("Received message payload " + string(message.Data))
-	messagePayload, err := utils.JsonDecodeByteStream[Moved](message.Data)
+func (b *gameContractBridge) dispatch(tx *gorm.DB, payload blockchain.CommandPayload, authorizers []blockchain.Authorizer, gameId uint64) (string, error) {
+	cmd, err := blockchain.NewBlockchainCommand(payload, authorizers)
+	if err != nil {
+		return "", err
+	}
+
+	cmd = cmd.WithReference(gameTopic(gameId))
+	return cmd.Id, blockchain.Dispatch(tx, cmd)
+}
+
+func (b *gameContractBridge) handleMoved(ctx context.Context, event *inbox.Event) error {
+	messagePayload, err := flowevent.DecodeAs[flowevent.Moved](event.Message.Data)
 	if err != nil {
 		log.Warn().Err(err).Msg("Error while parsing Moved message")
-		return
+		return inbox.Permanent(err)
 	}
 
-	er := b.db.Transaction(func(tx *gorm.DB) error {
-		game, err := b.findGameByFlowID(messagePayload.GameId)
-		result := tx.
-			Model(&model.Game{}).
-			Where("id = ?", game.Id).
-			Updates(map[string]any{
-				"turn": messagePayload.Turn,
-			})
+	tx := event.Tx
+	game, err := lockGameByFlowID(tx, messagePayload.GameId)
+	if err != nil {
+		return err
+	}
 
-		if result.Error != nil {
-			log.Warn().Err(result.Error).Msg("Error while handling Moved")
-			return result.Error
-		}
+	switch {
+	case game.Turn == nil:
+		return outOfOrder("move of game %d before the challenger joined", game.Id)
+	case messagePayload.Turn <= *game.Turn:
+		log.Info().Msg(fmt.Sprintf("Skipped stale move to turn %d of game %d at turn %d", messagePayload.Turn, game.Id, *game.Turn))
+		return nil
+	case messagePayload.Turn > *game.Turn+1:
+		return outOfOrder("move to turn %d of game %d at turn %d", messagePayload.Turn, game.Id, *game.Turn)
+	}
 
-		if err != nil {
-			log.Warn().Err(err).Msg("Error while sending ChallengerJoined ws message")
-			return result.Error
-		}
+	result := tx.
+		Model(&model.Game{}).
+		Where("id = ?", game.Id).
+		Updates(map[string]any{
+			"turn": messagePayload.Turn,
+		})
+
+	if result.Error != nil {
+		log.Warn().Err(result.Error).Msg("Error while handling Moved")
+		return result.Error
+	}
 
-		var user model.User
-		f := tx.Raw(`SELECT bu.* FROM battleblocks_user bu
-			LEFT JOIN custodial_wallet cw ON bu.custodial_wallet_id = cw.id
-			WHERE cw.address = ?`, messagePayload.PlayerAddress).First(&user)
+	var user model.User
+	f := tx.Raw(`SELECT bu.* FROM battleblocks_user bu
+		LEFT JOIN custodial_wallet cw ON bu.custodial_wallet_id = cw.id
+		WHERE cw.address = ?`, messagePayload.PlayerAddress).First(&user)
 
-		if f.Error != nil {
-			log.Warn().Err(err).Msg("Error while handling moved message")
-			return f.Error
-		}
+	if f.Error != nil {
+		log.Warn().Err(f.Error).Msg("Error while handling moved message")
+		return f.Error
+	}
 
-		mh := model.MoveHistory{
-			UserId:      user.Id,
-			GameId:      game.Id,
-			Coordinatex: messagePayload.X,
-			Coordinatey: messagePayload.Y,
-			PlayedAt:    time.Now().UTC().UnixMilli(),
-		}
+	mh := model.MoveHistory{
+		UserId:      user.Id,
+		GameId:      game.Id,
+		Coordinatex: messagePayload.X,
+		Coordinatey: messagePayload.Y,
+		PlayedAt:    time.Now().UTC().UnixMilli(),
+	}
 
-		result = tx.Table("move_history").Create(&mh)
+	result = tx.Table("move_history").Create(&mh)
 
-		if result.Error != nil {
-			log.Warn().Err(result.Error).Msg("Error while handling Moved")
-			return result.Error
-		}
+	if result.Error != nil {
+		log.Warn().Err(result.Error).Msg("Error while handling Moved")
+		return result.Error
+	}
 
-		var isHit bool
-		result = tx.
-			Raw(`
-			SELECT EXISTS(
-			SELECT 1 
-			FROM game_grid_point 
-			WHERE game_id = ?
-			AND user_id = ?
-			AND coordinate_x = ? 
-			AND coordinate_y = ? 
-			AND block_present = true);
-			`, game.Id, user.Id, messagePayload.X, messagePayload.Y).
-			Scan(&isHit)
-
-		if result.Error != nil {
-			log.Warn().Err(result.Error).Msg("Cannot fetch isHit for player move")
-			// should have proper ws error signal implemented
-			// but not necessary for this poc
-			isHit = false
-		}
+	err = recordGameEvent(tx, game.Id, model.GameEventMoved, messagePayload.Turn, event)
+	if err != nil {
+		log.Warn().Err(err).Msg("Error while recording Moved")
+		return err
+	}
 
-		wsEvent := map[string]any{
-			"type": "MOVE_DONE",
-			"payload": map[string]any{
-				"gameId": messagePayload.GameId,
-				"userId": user.Id,
-				"turn":   messagePayload.Turn,
-				"isHit":  isHit,
-				"x":      messagePayload.X,
-				"y":      messagePayload.Y,
-			},
-		}
-		b.notificationHub.Publish(fmt.Sprintf("game/%d", game.Id), wsEvent)
-		return nil
-	})
-	if er != nil {
-		log.Warn().Err(err).Msg("Cannot handle move event")
-		return
+	err = blockchain.CorrelateEvent(tx, event.Message.Attributes, blockchain.CommandGameMove, gameTopic(game.Id))
+	if err != nil {
+		log.Warn().Err(err).Msg("Error while correlating Moved with its command")
+		return err
+	}
+
+	var isHit bool
+	point, err := b.boardSecrets.LoadPoint(ctx, tx, game.Id, user.Id, uint64(messagePayload.X), uint64(messagePayload.Y))
+	if err != nil {
+		log.Warn().Err(err).Msg("Cannot fetch isHit for player move")
+		// should have proper ws error signal implemented
+		// but not necessary for this poc
+	} else {
+		isHit = point.BlockPresent
+	}
+
+	moveDone := wsevent.MoveDone{
+		GameId: game.Id,
+		UserId: user.Id,
+		Turn:   messagePayload.Turn,
+		IsHit:  isHit,
+		X:      messagePayload.X,
+		Y:      messagePayload.Y,
+	}
+
+	err = b.notifier.Replace(event, opponentOf(game, user.Id), model.NotificationOpponentMoved, gameTopic(game.Id), moveDone)
+	if err != nil {
+		log.Warn().Err(err).Msg("Error while notifying the opponent of Moved")
+		return err
 	}
-	message.Ack()
+
+	event.AfterCommit(func() {
+		b.notificationHub.Publish(gameTopic(game.Id), moveDone)
+	})
+	return nil
 }
 
-func (b *gameContractBridge) handleGameCreated(_ context.Context, message *gcppubsub.Message) {
-	log.Info().Msg("Received message payload " + string(message.Data))
-	messagePayload, err := utils.JsonDecodeByteStream[GameCreated](message.Data)
+func (b *gameContractBridge) handleGameCreated(_ context.Context, event *inbox.Event) error {
+	messagePayload, err := flowevent.DecodeAs[flowevent.GameCreated](event.Message.Data)
 	if err != nil {
 		log.Warn().Err(err).Msg("Error while parsing GameCreated message")
-		return
+		return inbox.Permanent(err)
 	}
 
-	result := b.db.
-		Model(&model.Game{}).
+	var game model.Game
+	result := event.Tx.
+		Clauses(clause.Locking{Strength: "UPDATE"}).
 		Where("id = ?", messagePayload.Payload).
+		First(&game)
+
+	if result.Error != nil {
+		log.Warn().Err(result.Error).Msg("Error while fetching game of GameCreated")
+		return result.Error
+	}
+	if game.FlowId != nil {
+		log.Info().Msg(fmt.Sprintf("Skipped GameCreated of game %d, already created with flow id %d", game.Id, *game.FlowId))
+		return nil
+	}
+
+	result = event.Tx.
+		Model(&model.Game{}).
+		Where("id = ?", game.Id).
 		Updates(map[string]any{
 			"flow_id":     messagePayload.GameId,
 			"game_status": "CREATED",
@@ -237,101 +211,148 @@ func (b *gameContractBridge) handleGameCreated(_ context.Context, message *gcppu
 
 	if result.Error != nil {
 		log.Warn().Err(result.Error).Msg("Error while handling GameCreated")
-		return
+		return result.Error
 	}
 
-	message.Ack()
+	err = recordGameEvent(event.Tx, game.Id, model.GameEventCreated, 0, event)
+	if err != nil {
+		log.Warn().Err(err).Msg("Error while recording GameCreated")
+		return err
+	}
 
-	wsEvent := map[string]any{
-		"type": "GAME_CREATED",
-		"payload": map[string]any{
-			"gameId":    messagePayload.GameId,
-			"creatorId": messagePayload.CreatorId,
-			"stake":     messagePayload.Stake,
-		},
+	err = blockchain.CorrelateEvent(event.Tx, event.Message.Attributes, blockchain.CommandGameCreate, gameTopic(messagePayload.Payload))
+	if err != nil {
+		log.Warn().Err(err).Msg("Error while correlating GameCreated with its command")
+		return err
 	}
-	b.notificationHub.Publish(fmt.Sprintf("game/%d", messagePayload.Payload), wsEvent)
+
+	event.AfterCommit(func() {
+		gameCreated := wsevent.GameCreated{
+			GameId:     game.Id,
+			FlowGameId: messagePayload.GameId,
+			OwnerId:    game.OwnerId,
+			Stake:      messagePayload.Stake,
+		}
+		b.notificationHub.Publish(gameTopic(game.Id), gameCreated)
+		b.notificationHub.Publish(ws.LobbyTopic, gameCreated)
+	})
+	return nil
 }
 
-func (b *gameContractBridge) handleChallengerJoined(_ context.Context, m *gcppubsub.Message) {
-	log.Info().Msg("Received message payload " + string(m.Data))
-	messagePayload, err := utils.JsonDecodeByteStream[ChallengerJoined](m.Data)
+func (b *gameContractBridge) handleChallengerJoined(_ context.Context, event *inbox.Event) error {
+	messagePayload, err := flowevent.DecodeAs[flowevent.ChallengerJoined](event.Message.Data)
 	if err != nil {
 		log.Warn().Err(err).Msg("Error while parsing ChallengedJoined message")
-		return
-	}
-	if messagePayload.PlayerB != nil {
-		b.db.Transaction(func(tx *gorm.DB) error {
-			var user model.User
-			f := tx.Raw(`SELECT bu.* FROM battleblocks_user bu
-				LEFT JOIN custodial_wallet cw ON bu.custodial_wallet_id = cw.id
-				WHERE cw.address = ?`, messagePayload.PlayerB).First(&user)
-
-			if f.Error != nil {
-				log.Warn().Err(err).Msg("Error while handling ChallengerJoined message")
-				return f.Error
-			}
-
-			f = tx.
-				Model(&model.Game{}).
-				Where("flow_id = ?", messagePayload.GameId).
-				Updates(map[string]any{
-					"challenger_id": user.Id,
-					"game_status":   "PLAYING",
-					"turn":          messagePayload.Turn,
-				})
-
-			if f.Error != nil {
-				log.Warn().Err(err).Msg("Error while handling ChallengerJoined message")
-				return f.Error
-			}
-
-			game, err := b.findGameByFlowID(messagePayload.GameId)
-			if err != nil {
-				log.Warn().Err(err).Msg("Error while sending ChallengerJoined ws message")
-				return err
-			}
-			wsEvent := map[string]any{
-				"type": "CHALLENGER_JOINED",
-				"payload": map[string]any{
-					"challengerName": user.Username,
-					"turn":           messagePayload.Turn,
-					"gameStatus":     "PLAYING",
-				},
-			}
-
-			b.notificationHub.Publish(fmt.Sprintf("game/%d", game.Id), wsEvent)
-
-			return nil
+		return inbox.Permanent(err)
+	}
+
+	tx := event.Tx
+	game, err := lockGameByFlowID(tx, messagePayload.GameId)
+	if err != nil {
+		return err
+	}
+	if game.Turn != nil {
+		log.Info().Msg(fmt.Sprintf("Skipped ChallengerJoined of game %d, already at turn %d", game.Id, *game.Turn))
+		return nil
+	}
+
+	var user model.User
+	f := tx.Raw(`SELECT bu.* FROM battleblocks_user bu
+		LEFT JOIN custodial_wallet cw ON bu.custodial_wallet_id = cw.id
+		WHERE cw.address = ?`, messagePayload.PlayerB).First(&user)
+
+	if f.Error != nil {
+		log.Warn().Err(f.Error).Msg("Error while handling ChallengerJoined message")
+		return f.Error
+	}
+
+	f = tx.
+		Model(&model.Game{}).
+		Where("id = ?", game.Id).
+		Updates(map[string]any{
+			"challenger_id": user.Id,
+			"game_status":   "PLAYING",
+			"turn":          messagePayload.Turn,
 		})
 
+	if f.Error != nil {
+		log.Warn().Err(f.Error).Msg("Error while handling ChallengerJoined message")
+		return f.Error
 	}
-	m.Ack()
 
-}
+	err = recordGameEvent(tx, game.Id, model.GameEventChallengerJoined, uint64(messagePayload.Turn), event)
+	if err != nil {
+		log.Warn().Err(err).Msg("Error while recording ChallengerJoined")
+		return err
+	}
 
-func (b *gameContractBridge) handleGameOver(_ context.Context, message *gcppubsub.Message) {
+	err = blockchain.CorrelateEvent(tx, event.Message.Attributes, blockchain.CommandGameJoin, gameTopic(game.Id))
+	if err != nil {
+		log.Warn().Err(err).Msg("Error while correlating ChallengerJoined with its command")
+		return err
+	}
+
+	challengerJoined := wsevent.ChallengerJoined{
+		GameId:         game.Id,
+		OwnerId:        game.OwnerId,
+		ChallengerId:   user.Id,
+		ChallengerName: user.Username,
+		Turn:           uint64(messagePayload.Turn),
+		GameStatus:     string(model.GamePlaying),
+	}
+
+	err = b.notifier.Notify(event, game.OwnerId, model.NotificationGameJoined, gameTopic(game.Id), challengerJoined)
+	if err != nil {
+		log.Warn().Err(err).Msg("Error while notifying the owner of ChallengerJoined")
+		return err
+	}
+
+	event.AfterCommit(func() {
+		b.notificationHub.Publish(gameTopic(game.Id), challengerJoined)
+		b.notificationHub.Publish(ws.LobbyTopic, challengerJoined)
+	})
+	return nil
+}
 
-	log.Info().Msg("Received message payload " + string(message.Data))
-	messagePayload, err := utils.JsonDecodeByteStream[GameOver](message.Data)
+func (b *gameContractBridge) handleGameOver(_ context.Context, event *inbox.Event) error {
+	messagePayload, err := flowevent.DecodeAs[flowevent.GameOver](event.Message.Data)
 	if err != nil {
 		log.Warn().Err(err).Msg("Error while parsing GameOver message")
-		return
+		return inbox.Permanent(err)
+	}
+
+	tx := event.Tx
+	game, err := lockGameByFlowID(tx, messagePayload.GameId)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case game.GameStatus == model.GameFinished:
+		log.Info().Msg(fmt.Sprintf("Skipped GameOver of already finished game %d", game.Id))
+		return nil
+	case game.Turn == nil:
+		return outOfOrder("game %d is over before the challenger joined", game.Id)
+	}
+
+	err = awaitFinalMove(tx, game.Id, event.Message.Attributes)
+	if err != nil {
+		return err
 	}
 
 	var user model.User
-	f := b.db.Raw(`SELECT bu.* FROM battleblocks_user bu
+	f := tx.Raw(`SELECT bu.* FROM battleblocks_user bu
 		LEFT JOIN custodial_wallet cw ON bu.custodial_wallet_id = cw.id
 		WHERE cw.address = ?`, messagePayload.Winner).First(&user)
 
 	if f.Error != nil {
 		log.Warn().Err(f.Error).Msg("Error while fetching winner account")
-		return
+		return f.Error
 	}
 
-	result := b.db.
+	result := tx.
 		Model(&model.Game{}).
-		Where("flow_id = ?", messagePayload.GameId).
+		Where("id = ?", game.Id).
 		Updates(map[string]any{
 			"winner_id":   user.Id,
 			"game_status": "FINISHED",
@@ -339,72 +360,61 @@ func (b *gameContractBridge) handleGameOver(_ context.Context, message *gcppubsu
 
 	if result.Error != nil {
 		log.Warn().Err(result.Error).Msg("Error while handling GameOver")
-		return
+		return result.Error
 	}
 
-	game, err := b.findGameByFlowID(messagePayload.GameId)
+	err = recordGameEvent(tx, game.Id, model.GameEventOver, *game.Turn, event)
 	if err != nil {
-		log.Warn().Err(err).Msg("Error while sending ChallengerJoined ws message")
-		return
+		log.Warn().Err(err).Msg("Error while recording GameOver")
+		return err
 	}
 
-	message.Ack()
-
+	err = b.ledger.SettleGame(tx, game.Id)
 	if err != nil {
-		log.Warn().Err(result.Error).Msg("Error while sending ChallengerJoined ws message")
-		return
+		log.Warn().Err(err).Msg("Error while settling stakes of finished game")
+		return err
 	}
 
-	wsEvent := map[string]any{
-		"type": "GAME_OVER",
-		"payload": map[string]any{
-			"gameId":   game.Id,
-			"winnerId": messagePayload.Winner,
-		},
+	gameOver := wsevent.GameOver{
+		GameId:        game.Id,
+		WinnerId:      user.Id,
+		WinnerAddress: messagePayload.Winner,
+		Stake:         game.Stake,
 	}
-	b.notificationHub.Publish(fmt.Sprintf("game/%d", game.Id), wsEvent)
-}
 
-func (b *gameContractBridge) findGameByFlowID(flowID uint64) (model.Game, error) {
-	var game model.Game
-	result := b.db.Model(&model.Game{}).
-		Where("flow_id = ?", flowID).
-		First(&game)
+	err = b.notifier.Notify(event, user.Id, model.NotificationGameWon, gameTopic(game.Id), gameOver)
+	if err != nil {
+		log.Warn().Err(err).Msg("Error while notifying the winner of GameOver")
+		return err
+	}
 
-	if result.Error != nil {
-		log.Warn().Err(result.Error).Msg("Error while fetching game by flow ID")
-		return game, result.Error
+	err = b.notifier.Notify(event, opponentOf(game, user.Id), model.NotificationGameLost, gameTopic(game.Id), gameOver)
+	if err != nil {
+		log.Warn().Err(err).Msg("Error while notifying the loser of GameOver")
+		return err
 	}
 
-	return game, nil
+	event.AfterCommit(func() {
+		b.notificationHub.Publish(gameTopic(game.Id), gameOver)
+	})
+	return nil
 }
 
-func byteArrayToUint(data []byte) []uint64 {
-	// create buffer from byte array
-	buffer := bytes.NewReader(data)
-
-	// read bytes from buffer as uint64
-	uint8Data := make([]uint64, len(data))
-	for i := 0; i < len(uint8Data); i++ {
-		var num uint8
-		binary.Read(buffer, binary.BigEndian, &num)
-		uint8Data[i] = uint64(num)
+// opponentOf is the other player of a game the challenger joined
+func opponentOf(game model.Game, userId uint64) uint64 {
+	if game.OwnerId == userId && game.ChallengerId != nil {
+		return *game.ChallengerId
 	}
+	return game.OwnerId
+}
 
-	return uint8Data
+func gameTopic(gameId uint64) string {
+	return fmt.Sprintf("game/%d", gameId)
 }
 
-func twoDimensionalbyteArrayToTwoDimensionalUint64Array(data [][]byte) [][]uint64 {
-	log.Info().Interface("data", data).Msg("Converting Byte Array to 2D UInt64 Array:")
-	uint64Data := make([][]uint64, len(data))
-	for i, row := range data {
-		uint64Data[i] = make([]uint64, len(row))
-		buffer := bytes.NewReader(row)
-		for j := 0; j < len(row); j++ {
-			var num uint8
-			binary.Read(buffer, binary.BigEndian, &num)
-			uint64Data[i][j] = uint64(num)
-		}
-	}
-	return uint64Data
+func (b *gameContractBridge) subscribe() {
+	inbox.Subscribe(b.broker, b.db, "blockchain.flow.events.move-done-sub", b.handleMoved)
+	inbox.Subscribe(b.broker, b.db, "blockchain.flow.events.game-created-sub", b.handleGameCreated)
+	inbox.Subscribe(b.broker, b.db, "blockchain.flow.events.challenger-joined-sub", b.handleChallengerJoined)
+	inbox.Subscribe(b.broker, b.db, "blockchain.flow.events.game-over-sub", b.handleGameOver)
 }
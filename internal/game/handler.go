@@ -1,6 +1,11 @@
 package game
 
 import (
+	"context"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/ledger"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/boardsecret"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/notification"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/pubsub"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/ws"
 	"net/http"
@@ -17,14 +22,26 @@ type gameHandler struct {
 	gameService *gameService
 }
 
-func RegisterRoutes(rg *gin.RouterGroup, db *gorm.DB) {
+func RegisterRoutes(rg *gin.RouterGroup, db *gorm.DB, broker pubsub.Broker, boardSecrets *boardsecret.Vault, ledger *ledger.LedgerService) {
+	notificationHub := ws.NewNotificationHub()
 	handler := gameHandler{
 		gameService: &gameService{
-			db: db,
+			db:              db,
+			notificationHub: notificationHub,
 			gameContractBridge: &gameContractBridge{
+				broker:          broker,
 				db:              db,
-				notificationHub: ws.NewNotificationHub(),
+				notificationHub: notificationHub,
+				notifier:        notification.NewNotifier(notificationHub),
+				boardSecrets:    boardSecrets,
+				ledger:          ledger,
 			},
+			boardSecrets: boardSecrets,
+			ledger:       ledger,
+			stakeLimits:  stakeLimitsFromConfig(),
+
+			disconnectGracePeriod: disconnectGracePeriodFromConfig(),
+			turnTimeout:           turnTimeoutFromConfig(),
 		},
 	}
 
@@ -32,31 +49,19 @@ func RegisterRoutes(rg *gin.RouterGroup, db *gorm.DB) {
 	routes.GET("", middleware.VerifyAuthToken, handler.getGames)
 	routes.GET("/:id", middleware.VerifyAuthToken, handler.getGame)
 	routes.GET("/:id/placement", middleware.VerifyAuthToken, handler.getPlacements)
+	routes.GET("/:id/presence", middleware.VerifyAuthToken, handler.getPresence)
 	routes.POST("", middleware.VerifyAuthToken, handler.createGame)
 	routes.POST("/:id/join", middleware.VerifyAuthToken, handler.joinGame)
 
 	routes.GET("/:id/moves", middleware.VerifyAuthToken, handler.getMoves)
 	routes.POST("/:id/moves", middleware.VerifyAuthToken, handler.playMove)
+	routes.POST("/:id/resign", middleware.VerifyAuthToken, handler.resign)
+	routes.POST("/:id/emote", middleware.VerifyAuthToken, handler.emote)
 
-	go pubsub.Subscribe(pubsub.SubscriptionHandler{
-		SubscriptionId: "blockchain.flow.events.move-done-sub",
-		Handler:        handler.gameService.gameContractBridge.handleMoved,
-	})
-
-	go pubsub.Subscribe(pubsub.SubscriptionHandler{
-		SubscriptionId: "blockchain.flow.events.game-created-sub",
-		Handler:        handler.gameService.gameContractBridge.handleGameCreated,
-	})
-
-	go pubsub.Subscribe(pubsub.SubscriptionHandler{
-		SubscriptionId: "blockchain.flow.events.challenger-joined-sub",
-		Handler:        handler.gameService.gameContractBridge.handleChallengerJoined,
-	})
-
-	go pubsub.Subscribe(pubsub.SubscriptionHandler{
-		SubscriptionId: "blockchain.flow.events.game-over-sub",
-		Handler:        handler.gameService.gameContractBridge.handleGameOver,
-	})
+	handler.registerCommands(ws.NewCommandRouter())
+	handler.gameService.gameContractBridge.subscribe()
+	handler.gameService.watchPresence()
+	go handler.gameService.watchTurns(context.Background())
 }
 
 func (gh *gameHandler) getMoves(c *gin.Context) {
@@ -111,12 +116,53 @@ func (gh *gameHandler) playMove(c *gin.Context) {
 
 	userEmail := utils.GetUserEmail(c)
 
-	er := gh.gameService.playMove(gameId, userEmail, body)
+	command, er := gh.gameService.playMove(gameId, userEmail, body)
 	if er != nil {
 		c.JSON(er.Problem.Status, er.Problem)
 		return
 	}
 
+	c.JSON(http.StatusAccepted, command)
+}
+
+func (gh *gameHandler) resign(c *gin.Context) {
+	gameId, parseErr := strconv.ParseUint(c.Param("id"), 0, 64)
+	if parseErr != nil {
+		c.JSON(http.StatusBadRequest, reject.RequestParamsProblem())
+		return
+	}
+
+	command, er := gh.gameService.resign(gameId, utils.GetUserEmail(c))
+	if er != nil {
+		c.JSON(er.Problem.Status, er.Problem)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, command)
+}
+
+type EmoteRequest struct {
+	Emote string `json:"emote"`
+}
+
+func (gh *gameHandler) emote(c *gin.Context) {
+	gameId, parseErr := strconv.ParseUint(c.Param("id"), 0, 64)
+	if parseErr != nil {
+		c.JSON(http.StatusBadRequest, reject.RequestParamsProblem())
+		return
+	}
+
+	body := EmoteRequest{}
+	if err := c.BindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, reject.BodyParseProblem())
+		return
+	}
+
+	if er := gh.gameService.emote(gameId, utils.GetUserEmail(c), body); er != nil {
+		c.JSON(er.Problem.Status, er.Problem)
+		return
+	}
+
 	c.Status(http.StatusNoContent)
 }
 
@@ -134,6 +180,23 @@ func (gh *gameHandler) getGame(c *gin.Context) {
 
 	c.JSON(http.StatusOK, game)
 }
+
+func (gh *gameHandler) getPresence(c *gin.Context) {
+	gameId, parseErr := strconv.ParseUint(c.Param("id"), 0, 64)
+	if parseErr != nil {
+		c.JSON(http.StatusBadRequest, reject.RequestParamsProblem())
+		return
+	}
+
+	presence, err := gh.gameService.getPresence(gameId)
+	if err != nil {
+		c.JSON(err.Problem.Status, err.Problem)
+		return
+	}
+
+	c.JSON(http.StatusOK, presence)
+}
+
 func (gh *gameHandler) getGames(c *gin.Context) {
 	page, err := utils.NewPageRequest(c)
 	if err != nil {
@@ -191,11 +254,14 @@ func (gh *gameHandler) joinGame(c *gin.Context) {
 	}
 
 	userEmail := utils.GetUserEmail(c)
-	err := gh.gameService.joinGame(body, gameId, userEmail)
+	command, err := gh.gameService.joinGame(body, gameId, userEmail)
 
 	if err != nil {
 		c.JSON(err.Problem.Status, err.Problem)
+		return
 	}
+
+	c.JSON(http.StatusAccepted, command)
 }
 
 func checkNextPageToken(currPage utils.PageRequest, gameCount int64) *int64 {
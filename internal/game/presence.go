@@ -0,0 +1,118 @@
+package game
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/model"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/reject"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/ws"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/wsevent"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+const defaultDisconnectGracePeriod = time.Minute
+
+type PlayerPresence struct {
+	UserId    uint64 `json:"userId"`
+	Connected bool   `json:"connected"`
+}
+
+// disconnectGracePeriodFromConfig reads GAME_DISCONNECT_GRACE_PERIOD, how
+// long a player who left a game keeps the turn, the turn times out then even
+// when the turn timeout is not reached
+func disconnectGracePeriodFromConfig() time.Duration {
+	gracePeriod := viper.GetDuration("GAME_DISCONNECT_GRACE_PERIOD")
+	if gracePeriod <= 0 {
+		return defaultDisconnectGracePeriod
+	}
+	return gracePeriod
+}
+
+// watchPresence tells the players of a game that is played when their
+// opponent leaves the game topic on every instance or comes back to it.
+// Spectators and the games that are not played are left out.
+func (gs *gameService) watchPresence() {
+	gs.notificationHub.WatchPresence(func(change ws.PresenceChange) {
+		if !strings.HasPrefix(change.Topic, "game/") {
+			return
+		}
+		gameId, err := strconv.ParseUint(strings.TrimPrefix(change.Topic, "game/"), 10, 64)
+		if err != nil {
+			return
+		}
+
+		var game model.Game
+		result := gs.db.Where("id = ?", gameId).First(&game)
+		if result.Error != nil {
+			if !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+				log.Warn().Err(result.Error).Msg("Error while loading game of presence change")
+			}
+			return
+		}
+
+		if game.GameStatus != model.GamePlaying || !isPlayer(game, change.UserId) {
+			return
+		}
+
+		if change.Connected {
+			gs.notificationHub.Publish(change.Topic, wsevent.OpponentReconnected{GameId: game.Id, UserId: change.UserId})
+			return
+		}
+		gs.notificationHub.Publish(change.Topic, wsevent.OpponentDisconnected{
+			GameId:     game.Id,
+			UserId:     change.UserId,
+			GraceUntil: time.Now().Add(gs.disconnectGracePeriod).UTC().UnixMilli(),
+		})
+	})
+}
+
+// getPresence returns whether the players of the game are connected to it
+// on any instance
+func (gs *gameService) getPresence(gameId uint64) ([]PlayerPresence, *reject.ProblemWithTrace) {
+	var game model.Game
+	result := gs.db.Where("id = ?", gameId).First(&game)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, &reject.ProblemWithTrace{
+			Problem: reject.NotFoundProblem(),
+			Cause:   result.Error,
+		}
+	}
+	if result.Error != nil {
+		return nil, &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(result.Error),
+			Cause:   result.Error,
+		}
+	}
+
+	players := []uint64{game.OwnerId}
+	if game.ChallengerId != nil {
+		players = append(players, *game.ChallengerId)
+	}
+
+	connected, err := gs.notificationHub.Presence(context.Background(), gameTopic(game.Id), players)
+	if err != nil {
+		return nil, &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(err),
+			Cause:   err,
+		}
+	}
+
+	presence := make([]PlayerPresence, 0, len(players))
+	for _, userId := range players {
+		presence = append(presence, PlayerPresence{
+			UserId:    userId,
+			Connected: connected[userId].Connected,
+		})
+	}
+	return presence, nil
+}
+
+func isPlayer(game model.Game, userId uint64) bool {
+	return game.OwnerId == userId || (game.ChallengerId != nil && *game.ChallengerId == userId)
+}
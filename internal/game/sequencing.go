@@ -0,0 +1,93 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/blockchain"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/inbox"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Game events are consumed from one subscription per event type, so the
+// events of a game can arrive in any order. They are applied in on-chain
+// order: the game row is locked while an event is applied, events that
+// arrive too early are rejected with errOutOfOrder and redelivered after a
+// backoff, stale events are skipped. Out of order events are deferred, they
+// are retried far longer than failing ones before they are dead-lettered.
+var errOutOfOrder = errors.New("game event out of order")
+
+func outOfOrder(format string, args ...any) error {
+	return inbox.Deferred(fmt.Errorf("%w: %s", errOutOfOrder, fmt.Sprintf(format, args...)))
+}
+
+// lockGameByFlowID locks the game until the transaction ends, a game that
+// does not exist yet is out of order as its GameCreated event is pending
+func lockGameByFlowID(tx *gorm.DB, flowID uint64) (model.Game, error) {
+	var game model.Game
+	result := tx.
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("flow_id = ?", flowID).
+		First(&game)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return game, outOfOrder("game with flow id %d is not created yet", flowID)
+	}
+	return game, result.Error
+}
+
+func recordGameEvent(tx *gorm.DB, gameId uint64, eventType model.GameEventType, turn uint64, event *inbox.Event) error {
+	var transactionId *string
+	if id := event.Message.Attributes[blockchain.TransactionIdAttribute]; id != "" {
+		transactionId = &id
+	}
+
+	return tx.Create(&model.GameEvent{
+		GameId:        gameId,
+		EventType:     eventType,
+		Turn:          turn,
+		TransactionId: transactionId,
+		EventKey:      inbox.EventKey(event.Message),
+		AppliedAt:     time.Now().UTC().UnixMilli(),
+	}).Error
+}
+
+// awaitFinalMove rejects a GameOver emitted by a move transaction until the
+// Moved event of that transaction is applied
+func awaitFinalMove(tx *gorm.DB, gameId uint64, attributes map[string]string) error {
+	transactionId := attributes[blockchain.TransactionIdAttribute]
+	if transactionId == "" {
+		return nil
+	}
+
+	query := tx.Model(&model.BlockchainCommand{})
+	if commandId := attributes[blockchain.CommandIdAttribute]; commandId != "" {
+		query = query.Where("id = ?", commandId)
+	} else {
+		query = query.Where("flow_transaction_id = ?", transactionId)
+	}
+
+	var command model.BlockchainCommand
+	result := query.Limit(1).Find(&command)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 || command.Type != blockchain.CommandGameMove {
+		return nil
+	}
+
+	var moves int64
+	result = tx.Model(&model.GameEvent{}).
+		Where("game_id = ? AND event_type = ? AND transaction_id = ?", gameId, model.GameEventMoved, transactionId).
+		Count(&moves)
+	if result.Error != nil {
+		return result.Error
+	}
+	if moves == 0 {
+		return outOfOrder("final move of game %d is not applied yet", gameId)
+	}
+	return nil
+}
@@ -6,19 +6,18 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
-	"github.com/spf13/viper"
 	// merkletree "github.com/wealdtech/go-merkletree"
 
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/ledger"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/blockchain"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/boardsecret"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/model"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/reject"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/utils"
-	"github.com/onflow/cadence"
-	"github.com/onflow/flow-go-sdk"
-	"github.com/onflow/flow-go-sdk/access/grpc"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/ws"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/wsevent"
 	"github.com/rs/zerolog/log"
 	// keccak "github.com/wealdtech/go-merkletree/keccak256"
 	"gorm.io/gorm"
@@ -26,11 +25,27 @@ import (
 
 const (
 	databaseError = "error.data.access"
+	notPlayer     = "error.game.not-player"
+	notPlaying    = "error.game.not-playing"
+	notOpen       = "error.game.not-open"
+	invalidEmote  = "error.game.invalid-emote"
+
+	maxEmoteLength = 32
 )
 
+var errGameNotOpen = errors.New("game is not open to join")
+
 type gameService struct {
 	db                 *gorm.DB
 	gameContractBridge *gameContractBridge
+	notificationHub    *ws.WebSocketNotificationHub
+	boardSecrets       *boardsecret.Vault
+	ledger             *ledger.LedgerService
+	stakeLimits        stakeLimits
+	// a player who left a game has the grace period for a move, if it ends
+	// before the turn timeout
+	disconnectGracePeriod time.Duration
+	turnTimeout           time.Duration
 }
 
 type GameResponse struct {
@@ -126,7 +141,8 @@ func (gs *gameService) getGames(page utils.PageRequest, userEmail string) ([]Gam
 	return games, &gamesSize, nil
 }
 
-func (gs *gameService) joinGame(joinGame JoinGameRequest, gameId uint64, userEmail string) *reject.ProblemWithTrace {
+func (gs *gameService) joinGame(joinGame JoinGameRequest, gameId uint64, userEmail string) (*CommandResponse, *reject.ProblemWithTrace) {
+	var commandId string
 	err := gs.db.Transaction(func(tx *gorm.DB) error {
 		var userId string
 		f := tx.Raw("SELECT u.id FROM battleblocks_user u WHERE email = ?", userEmail).First(&userId)
@@ -135,28 +151,34 @@ func (gs *gameService) joinGame(joinGame JoinGameRequest, gameId uint64, userEma
 		}
 
 		var game model.Game
-		f = tx.Raw("SELECT * FROM game u WHERE id = ?", gameId).First(&game)
+		f = tx.Raw("SELECT * FROM game u WHERE id = ? FOR UPDATE", gameId).First(&game)
 		if f.Error != nil {
 			return f.Error
 		}
 
+		owner, _ := strconv.ParseUint(userId, 10, 64)
+		open, err := gs.isOpen(tx, game, owner)
+		if err != nil {
+			return err
+		}
+		if !open {
+			return errGameNotOpen
+		}
+
 		wallet := gs.getCustodialWallet(userEmail)
 		if wallet == nil {
 			return errors.New("wallet does not exist")
 		}
 
-		balance, err := checkBalance(*wallet.Address)
+		balance, err := gs.ledger.OnChainBalance(context.Background(), *wallet.Address)
 		if err != nil {
 			return err
 		}
 
-		bf, err := strconv.ParseFloat(balance, 32)
+		err = gs.ledger.Reserve(tx, owner, game.Id, game.Stake, balance)
 		if err != nil {
 			return err
 		}
-		if float32(bf) < (float32(game.Stake) + 1) {
-			return errors.New("user not allowed to create game with indicated stake")
-		}
 
 		blockIds := []uint64{}
 		for _, placement := range joinGame.Placements {
@@ -182,17 +204,16 @@ func (gs *gameService) joinGame(joinGame JoinGameRequest, gameId uint64, userEma
 			return err
 		}
 
-		owner, _ := strconv.ParseUint(userId, 10, 64)
 		var points []*model.GameGridPoint
 		for _, singlePoint := range mtreeData {
 			sp, _ := singlePoint.Serialize()
 			points = append(points, pointFromData(string(sp), gameId, owner))
 		}
 
-		f = tx.Table("game_grid_point").Create(points)
-		if f.Error != nil {
+		err = gs.boardSecrets.Store(context.Background(), tx, gameId, owner, points)
+		if err != nil {
 			log.Warn().Msg("cannot create game grid points")
-			return f.Error
+			return err
 		}
 
 		userAuthorizer := blockchain.Authorizer{
@@ -217,22 +238,49 @@ func (gs *gameService) joinGame(joinGame JoinGameRequest, gameId uint64, userEma
 			return f.Error
 		}
 
-		gs.gameContractBridge.sendJoinGame(float32(game.Stake), merkle.Root, *game.FlowId, userAuthorizer)
-		return nil
-
+		commandId, err = gs.gameContractBridge.sendJoinGame(tx, game.Stake, merkle.Root, game, userAuthorizer)
+		return err
 	})
 
-	if err != nil {
-		return &reject.ProblemWithTrace{
-			Problem: reject.UnexpectedProblem(err),
-			Cause:   err,
+	if errors.Is(err, errGameNotOpen) {
+		return nil, &reject.ProblemWithTrace{
+			Problem: reject.NewProblem().
+				WithTitle("Game is not open to join").
+				WithStatus(http.StatusConflict).
+				WithCode(notOpen).
+				Build(),
+			Cause: err,
 		}
 	}
-	return nil
+	if err != nil {
+		return nil, stakeProblem(err)
+	}
+	return &CommandResponse{CommandId: commandId}, nil
 }
 
-func (gs *gameService) createGame(createGame CreateGameRequest, userEmail string) (*model.Game, *reject.ProblemWithTrace) {
+// isOpen tells whether the user may join the game, it has no challenger and
+// nobody else has a join pending. The game row has to be locked.
+func (gs *gameService) isOpen(tx *gorm.DB, game model.Game, userId uint64) (bool, error) {
+	if game.GameStatus != model.GameCreated || game.ChallengerId != nil || game.OwnerId == userId {
+		return false, nil
+	}
+
+	var pending int64
+	result := tx.Raw(`SELECT count(*) FROM stake_ledger_entry r
+		WHERE r.game_id = ? AND r.user_id <> ? AND r.entry_type = 'RESERVE'
+		AND NOT EXISTS (SELECT 1 FROM stake_ledger_entry c
+			WHERE c.user_id = r.user_id AND c.game_id = r.game_id AND c.entry_type <> 'RESERVE')`,
+		game.Id, game.OwnerId).Scan(&pending)
+	return pending == 0, result.Error
+}
+
+func (gs *gameService) createGame(createGame CreateGameRequest, userEmail string) (*CreateGameResponse, *reject.ProblemWithTrace) {
+	if problem := gs.stakeLimits.validate(createGame.Stake); problem != nil {
+		return nil, problem
+	}
+
 	var createdGame *model.Game
+	var commandId string
 	err := gs.db.Transaction(func(tx *gorm.DB) error {
 		var userId string
 		f := tx.Raw("SELECT u.id FROM battleblocks_user u WHERE email = ?", userEmail).First(&userId)
@@ -251,19 +299,11 @@ func (gs *gameService) createGame(createGame CreateGameRequest, userEmail string
 			return errors.New("error fetching address of current user")
 		}
 
-		balance, err := checkBalance(*wallet.Address)
+		balance, err := gs.ledger.OnChainBalance(context.Background(), *wallet.Address)
 		if err != nil {
 			return err
 		}
 
-		bf, err := strconv.ParseFloat(balance, 32)
-		if err != nil {
-			return err
-		}
-		if float32(bf) < (createGame.Stake + 1) {
-			return errors.New("user not allowed to create game with indicated stake")
-		}
-
 		blockIds := []uint64{}
 		for _, placement := range createGame.Placements {
 			blockIds = append(blockIds, placement.BlockId)
@@ -291,7 +331,7 @@ func (gs *gameService) createGame(createGame CreateGameRequest, userEmail string
 		createdGame = &model.Game{
 			OwnerId:     owner,
 			GameStatus:  model.GamePreparing,
-			Stake:       uint64(createGame.Stake),
+			Stake:       createGame.Stake,
 			TimeCreated: time.Now().UTC().UnixMilli(),
 		}
 		f = tx.Table("game").Create(&createdGame)
@@ -300,6 +340,11 @@ func (gs *gameService) createGame(createGame CreateGameRequest, userEmail string
 			return f.Error
 		}
 
+		err = gs.ledger.Reserve(tx, owner, createdGame.Id, createGame.Stake, balance)
+		if err != nil {
+			return err
+		}
+
 		userAuthorizer := blockchain.Authorizer{
 			KmsResourceId:        wallet.ResourceId,
 			ResourceOwnerAddress: *wallet.Address,
@@ -327,25 +372,21 @@ func (gs *gameService) createGame(createGame CreateGameRequest, userEmail string
 			points = append(points, pointFromData(string(sp), createdGame.Id, owner))
 		}
 
-		f = tx.Table("game_grid_point").Create(&points)
-		if f.Error != nil {
+		err = gs.boardSecrets.Store(context.Background(), tx, createdGame.Id, owner, points)
+		if err != nil {
 			log.Warn().Msg("cannot create game grid points")
-			return f.Error
+			return err
 		}
 
-		gs.gameContractBridge.sendCreateGameTx(createGame.Stake, merkle.Root, createdGame.Id, userAuthorizer)
-
-		return nil
+		commandId, err = gs.gameContractBridge.sendCreateGameTx(tx, createGame.Stake, merkle.Root, createdGame.Id, userAuthorizer)
+		return err
 	})
 
 	if err != nil {
-		return nil, &reject.ProblemWithTrace{
-			Problem: reject.UnexpectedProblem(err),
-			Cause:   err,
-		}
+		return nil, stakeProblem(err)
 	}
 
-	return createdGame, nil
+	return &CreateGameResponse{Game: *createdGame, CommandId: commandId}, nil
 }
 
 type PlacementsView struct {
@@ -388,20 +429,22 @@ func (gs *gameService) getMoves(gameID uint64, userEmail string) ([]MoveHistoryW
 		}
 	}
 
+	points, err := gs.boardSecrets.LoadGame(context.Background(), gs.db, gameID)
+	if err != nil {
+		log.Warn().Err(err).Msg("Cannot fetch isHit for player move")
+		// should have proper ws error signal implemented
+		// but not necessary for this poc
+	}
+
 	for i := range moves {
 		var isHit bool
-		rslt := gs.db.Table("game_grid_point").
-			Where("game_id = ? AND coordinate_x = ? AND coordinate_y = ? AND block_present = true",
-				moves[i].GameID, moves[i].Coordinatex, moves[i].Coordinatey).
-			Select("EXISTS(SELECT 1 FROM game_grid_point WHERE game_id = ? AND coordinate_x = ? AND coordinate_y = ? AND block_present = true)",
-				moves[i].GameID, moves[i].Coordinatex, moves[i].Coordinatey).
-			Scan(&isHit)
-
-		if rslt.Error != nil {
-			log.Warn().Err(result.Error).Msg("Cannot fetch isHit for player move")
-			// should have proper ws error signal implemented
-			// but not necessary for this poc
-			isHit = false
+		for _, point := range points {
+			if point.BlockPresent &&
+				point.CoordinateX == uint64(moves[i].Coordinatex) &&
+				point.CoordinateY == uint64(moves[i].Coordinatey) {
+				isHit = true
+				break
+			}
 		}
 
 		moves[i].IsHit = isHit
@@ -431,56 +474,35 @@ func (gs *gameService) getGame(gameId uint64) (*GameResponse, *reject.ProblemWit
 	return &game, nil
 }
 
-func (gs *gameService) playMove(gameId uint64, userEmail string, request PlayMoveRequest) *reject.ProblemWithTrace {
-	var currentUserData []model.GameGridPoint
-
-	result := gs.db.
-		Table("game_grid_point").
-		Where("game_id = ? AND user_id = (SELECT bu.id FROM battleblocks_user bu WHERE email = ?)", gameId, userEmail).
-		Find(&currentUserData)
-
-	if result.Error != nil {
-		return &reject.ProblemWithTrace{
-			Problem: reject.UnexpectedProblem(result.Error),
-			Cause:   result.Error,
-		}
+func (gs *gameService) playMove(gameId uint64, userEmail string, request PlayMoveRequest) (*CommandResponse, *reject.ProblemWithTrace) {
+	game, userId, problem := gs.playerGame(gameId, userEmail)
+	if problem != nil {
+		return nil, problem
 	}
 
-	mtree, _, err := blockchain.CreateMerkleTreeFromData(currentUserData)
+	if problem := inProgress(game); problem != nil {
+		return nil, problem
+	}
 
+	currentUserData, err := gs.boardSecrets.Load(context.Background(), gs.db, gameId, userId)
 	if err != nil {
-		return &reject.ProblemWithTrace{
-			Problem: reject.UnexpectedProblem(result.Error),
-			Cause:   result.Error,
+		return nil, &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(err),
+			Cause:   err,
 		}
 	}
-	var user model.User
-	result = gs.db.
-		Model(&model.User{}).
-		Where("email = ?", userEmail).
-		Find(&user)
 
-	if result.Error != nil {
-		return &reject.ProblemWithTrace{
-			Problem: reject.UnexpectedProblem(result.Error),
-			Cause:   result.Error,
-		}
-	}
+	mtree, _, err := blockchain.CreateMerkleTreeFromData(currentUserData)
 
-	var game model.Game
-	result = gs.db.
-		Model(&model.Game{}).
-		Where("id = ?", gameId).
-		Find(&game)
-	if result.Error != nil {
-		return &reject.ProblemWithTrace{
-			Problem: reject.UnexpectedProblem(result.Error),
-			Cause:   result.Error,
+	if err != nil {
+		return nil, &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(err),
+			Cause:   err,
 		}
 	}
 
 	opponent := *game.ChallengerId
-	if *game.ChallengerId == user.Id {
+	if *game.ChallengerId == userId {
 		opponent = game.OwnerId
 	}
 
@@ -489,7 +511,7 @@ func (gs *gameService) playMove(gameId uint64, userEmail string, request PlayMov
 		cw := gs.getCustodialWallet(userEmail)
 		if cw == nil {
 			walletNotExistsErr := fmt.Errorf("custodial wallet not found while making move, user email %s", userEmail)
-			return &reject.ProblemWithTrace{
+			return nil, &reject.ProblemWithTrace{
 				Problem: reject.UnexpectedProblem(walletNotExistsErr),
 				Cause:   walletNotExistsErr,
 			}
@@ -497,30 +519,31 @@ func (gs *gameService) playMove(gameId uint64, userEmail string, request PlayMov
 
 		userAuthorizer := blockchain.Authorizer{KmsResourceId: cw.ResourceId, ResourceOwnerAddress: *cw.Address}
 
-		fProof := [][]uint8{{}}
+		move := blockchain.GameMove{
+			GuessX: request.X,
+			GuessY: request.Y,
+			Proof:  [][]byte{{}},
+		}
 
-		gs.gameContractBridge.sendMove(*game.FlowId, request.X, request.Y, fProof,
-			nil, nil, nil, nil, userAuthorizer)
-		return nil
+		commandId, err := gs.gameContractBridge.sendMove(*game, move, userAuthorizer)
+		if err != nil {
+			return nil, &reject.ProblemWithTrace{
+				Problem: reject.UnexpectedProblem(err),
+				Cause:   err,
+			}
+		}
+		return &CommandResponse{CommandId: commandId}, nil
 
 	}
-	opponentProofData, proofDataLoadErr := gs.getLastOpponentMoveProofData(gameId, opponent, user.Id)
+	opponentProofData, proofDataLoadErr := gs.getLastOpponentMoveProofData(gameId, opponent, userId)
 
 	if proofDataLoadErr != nil {
-		return &reject.ProblemWithTrace{
+		return nil, &reject.ProblemWithTrace{
 			Problem: reject.UnexpectedProblem(proofDataLoadErr),
 			Cause:   proofDataLoadErr,
 		}
 	}
 
-	if proofDataLoadErr != nil {
-		log.Info().Msg("Could not fetch last opponent data")
-		return &reject.ProblemWithTrace{
-			Problem: reject.UnexpectedProblem(result.Error),
-			Cause:   result.Error,
-		}
-	}
-
 	proofNode := blockchain.CreateMerkleTreeNode(
 		int32(opponentProofData.CoordinateX),
 		int32(opponentProofData.CoordinateY),
@@ -529,7 +552,7 @@ func (gs *gameService) playMove(gameId uint64, userEmail string, request PlayMov
 
 	proof, err := mtree.Proof(proofNode)
 	if err != nil {
-		return &reject.ProblemWithTrace{
+		return nil, &reject.ProblemWithTrace{
 			Problem: reject.UnexpectedProblem(err),
 			Cause:   err,
 		}
@@ -538,7 +561,7 @@ func (gs *gameService) playMove(gameId uint64, userEmail string, request PlayMov
 	cw := gs.getCustodialWallet(userEmail)
 	if cw == nil {
 		walletNotExistsErr := fmt.Errorf("custodial wallet not found while making move, user email %s", userEmail)
-		return &reject.ProblemWithTrace{
+		return nil, &reject.ProblemWithTrace{
 			Problem: reject.UnexpectedProblem(walletNotExistsErr),
 			Cause:   walletNotExistsErr,
 		}
@@ -552,22 +575,141 @@ func (gs *gameService) playMove(gameId uint64, userEmail string, request PlayMov
 
 	// verifyProof, err := merkletree.VerifyProofUsing([]byte(proofNode), proof, mtree.Root(), keccak.New(), nil)
 
-	log.Error().Interface("nonce", nonceNumber).Msg("Nonce number")
+	move := blockchain.GameMove{
+		GuessX:         request.X,
+		GuessY:         request.Y,
+		Proof:          proof.Siblings,
+		BlockPresent:   &opponentProofData.BlockPresent,
+		OpponentGuessX: &opponentProofData.CoordinateX,
+		OpponentGuessY: &opponentProofData.CoordinateY,
+		Nonce:          &nonceNumber,
+	}
+
+	commandId, err := gs.gameContractBridge.sendMove(*game, move, userAuthorizer)
+	if err != nil {
+		return nil, &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(err),
+			Cause:   err,
+		}
+	}
+
+	return &CommandResponse{CommandId: commandId}, nil
+}
+
+// resign gives up a game in progress, the opponent wins once the GameOver
+// event of the transaction arrives
+func (gs *gameService) resign(gameId uint64, userEmail string) (*CommandResponse, *reject.ProblemWithTrace) {
+	game, _, problem := gs.playerGame(gameId, userEmail)
+	if problem != nil {
+		return nil, problem
+	}
+
+	if problem := inProgress(game); problem != nil {
+		return nil, problem
+	}
+
+	cw := gs.getCustodialWallet(userEmail)
+	if cw == nil {
+		walletNotExistsErr := fmt.Errorf("custodial wallet not found while resigning, user email %s", userEmail)
+		return nil, &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(walletNotExistsErr),
+			Cause:   walletNotExistsErr,
+		}
+	}
+
+	userAuthorizer := blockchain.Authorizer{KmsResourceId: cw.ResourceId, ResourceOwnerAddress: *cw.Address}
 
-	// log.Error().Interface("verify root", verify).Msg("VERIFY ROOT DEBUG:")
+	commandId, err := gs.gameContractBridge.sendResign(*game, userAuthorizer)
+	if err != nil {
+		return nil, &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(err),
+			Cause:   err,
+		}
+	}
 
-	// log.Error().Interface("verify proof", verifyProof).Msg("VERIFY PROOF DEBUG:")
+	return &CommandResponse{CommandId: commandId}, nil
+}
 
-	log.Error().Interface("proof", proof).Msg("LOG PROOF:")
+// inProgress rejects moves and resigning before the game started on-chain
+// and after it ended
+func inProgress(game *model.Game) *reject.ProblemWithTrace {
+	if game.GameStatus != model.GamePlaying || game.FlowId == nil || game.ChallengerId == nil {
+		return &reject.ProblemWithTrace{
+			Problem: reject.NewProblem().
+				WithTitle("Game is not in progress").
+				WithStatus(http.StatusConflict).
+				WithCode(notPlaying).
+				Build(),
+		}
+	}
+	return nil
+}
 
-	// log.Error().Interface("proof hashes", proof.).Msg("LOG PROOF:")
+// emote shows the emote to everyone listening to the game, emotes are not
+// stored
+func (gs *gameService) emote(gameId uint64, userEmail string, request EmoteRequest) *reject.ProblemWithTrace {
+	if request.Emote == "" || len(request.Emote) > maxEmoteLength {
+		return &reject.ProblemWithTrace{
+			Problem: reject.NewProblem().
+				WithTitle("Invalid emote").
+				WithStatus(http.StatusBadRequest).
+				WithCode(invalidEmote).
+				Build(),
+		}
+	}
 
-	gs.gameContractBridge.sendMove(*game.FlowId, request.X, request.Y, proof.Siblings,
-		&opponentProofData.BlockPresent, &opponentProofData.CoordinateX, &opponentProofData.CoordinateY, &nonceNumber, userAuthorizer)
+	game, userId, problem := gs.playerGame(gameId, userEmail)
+	if problem != nil {
+		return problem
+	}
 
+	gs.notificationHub.Publish(gameTopic(game.Id), wsevent.Emote{
+		GameId: game.Id,
+		UserId: userId,
+		Emote:  request.Emote,
+	})
 	return nil
 }
 
+// playerGame loads a game of the user and the id of the user
+func (gs *gameService) playerGame(gameId uint64, userEmail string) (*model.Game, uint64, *reject.ProblemWithTrace) {
+	var game model.Game
+	result := gs.db.Where("id = ?", gameId).First(&game)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, 0, &reject.ProblemWithTrace{
+			Problem: reject.NotFoundProblem(),
+			Cause:   result.Error,
+		}
+	}
+	if result.Error != nil {
+		return nil, 0, &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(result.Error),
+			Cause:   result.Error,
+		}
+	}
+
+	var userId uint64
+	result = gs.db.Raw("SELECT u.id FROM battleblocks_user u WHERE email = ?", userEmail).Scan(&userId)
+	if result.Error != nil {
+		return nil, 0, &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(result.Error),
+			Cause:   result.Error,
+		}
+	}
+
+	if result.RowsAffected == 0 || !isPlayer(game, userId) {
+		return nil, 0, &reject.ProblemWithTrace{
+			Problem: reject.NewProblem().
+				WithTitle("Not a player of the game").
+				WithStatus(http.StatusForbidden).
+				WithCode(notPlayer).
+				Build(),
+		}
+	}
+
+	return &game, userId, nil
+}
+
 func (gs *gameService) isFirstMove(gameId uint64) bool {
 	var moves []model.MoveHistory
 	gs.db.Raw("SELECT * FROM move_history mh WHERE mh.game_id = ?", gameId).Scan(&moves)
@@ -586,16 +728,11 @@ func (gs *gameService) getLastOpponentMoveProofData(gameId uint64, opponentId ui
 		return nil, result.Error
 	}
 
-	var proofData model.GameGridPoint
-	result = gs.db.Raw(`
-		SELECT ggp.coordinate_x as coordinatex, ggp.coordinate_y as coordinatey,ggp.* FROM game_grid_point ggp where ggp.coordinate_x = ? AND coordinate_y = ? AND ggp.game_id = ? AND ggp.user_id = ?
-		`, mh.Coordinatex, mh.Coordinatey, gameId, currUserId).First(&proofData)
-
-	if result.Error != nil {
-		return nil, result.Error
+	proofData, err := gs.boardSecrets.LoadPoint(context.Background(), gs.db, gameId, currUserId, uint64(mh.Coordinatex), uint64(mh.Coordinatey))
+	if err != nil {
+		return nil, err
 	}
 
-	log.Error().Interface("proof data", proofData).Msg("PROOF DATA:")
 	// result := gs.db.Raw(`
 	// select game_grid_point.* from game_grid_point
 	// LEFT JOIN move_history mh ON game_grid_point.game_id = mh.game_id
@@ -609,7 +746,7 @@ func (gs *gameService) getLastOpponentMoveProofData(gameId uint64, opponentId ui
 	// return nil, result.Error
 	// }
 
-	return &proofData, nil
+	return proofData, nil
 }
 
 func (gs *gameService) getCustodialWallet(userEmail string) *model.CustodialWallet {
@@ -626,55 +763,6 @@ func (gs *gameService) getCustodialWallet(userEmail string) *model.CustodialWall
 	return &custodialWallet
 }
 
-func checkBalance(address string) (string, error) {
-	txCode := `
-		import FungibleToken from 0xFUNGIBLE_TOKEN_ADDRESS
-		import FlowToken from 0xFLOW_TOKEN_ADDRESS
-
-		pub fun main(account: Address): UFix64 {
-
-		let vaultRef = getAccount(account)
-		.getCapability(/public/flowTokenBalance)
-		.borrow<&FlowToken.Vault{FungibleToken.Balance}>()
-		?? panic("Could not borrow Balance reference to the Vault")
-
-		return vaultRef.balance
-		}
-		`
-
-	addressTemplates := map[string]string{
-		"0xFLOW_TOKEN_ADDRESS":     viper.Get("FLOW_TOKEN_ADDRESS").(string),
-		"0xFUNGIBLE_TOKEN_ADDRESS": viper.Get("FUNGIBLE_TOKEN_ADDRESS").(string),
-	}
-
-	for k, v := range addressTemplates {
-		txCode = strings.ReplaceAll(txCode, k, v)
-	}
-
-	c, err := grpc.NewClient(grpc.TestnetHost)
-	if err != nil {
-		return "", err
-	}
-	var adr [8]byte
-	copy(adr[:], address)
-
-	flowAddress := flow.HexToAddress(address)
-
-	cadenceAddress := cadence.BytesToAddress(flowAddress.Bytes())
-
-	args := []cadence.Value{cadence.Address(cadenceAddress)}
-
-	balance, err := c.ExecuteScriptAtLatestBlock(context.Background(), []byte(
-		txCode,
-	), args)
-
-	if err != nil {
-		return "", err
-	}
-
-	return balance.String(), nil
-}
-
 func pointFromData(singlePoint string, gameId uint64, userId uint64) *model.GameGridPoint {
 	p := singlePoint[:1]
 	x := singlePoint[1:2]
@@ -696,3 +784,17 @@ func pointFromData(singlePoint string, gameId uint64, userId uint64) *model.Game
 		Nonce:        nonce,
 	}
 }
+
+func stakeProblem(err error) *reject.ProblemWithTrace {
+	if errors.Is(err, ledger.ErrInsufficientBalance) {
+		return &reject.ProblemWithTrace{
+			Problem: ledger.InsufficientBalanceProblem(),
+			Cause:   err,
+		}
+	}
+
+	return &reject.ProblemWithTrace{
+		Problem: reject.UnexpectedProblem(err),
+		Cause:   err,
+	}
+}
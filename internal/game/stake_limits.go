@@ -0,0 +1,63 @@
+package game
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/money"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/reject"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	invalidStake = "error.game.invalid-stake"
+
+	defaultMinStake = "0.1"
+	defaultMaxStake = "1000.0"
+)
+
+type stakeLimits struct {
+	min money.Amount
+	max money.Amount
+}
+
+func stakeLimitsFromConfig() stakeLimits {
+	return stakeLimits{
+		min: stakeLimit("GAME_MIN_STAKE", defaultMinStake),
+		max: stakeLimit("GAME_MAX_STAKE", defaultMaxStake),
+	}
+}
+
+func (l stakeLimits) validate(stake money.Amount) *reject.ProblemWithTrace {
+	if stake >= l.min && stake <= l.max {
+		return nil
+	}
+
+	err := fmt.Errorf("stake %s outside of allowed range [%s, %s]", stake, l.min, l.max)
+	return &reject.ProblemWithTrace{
+		Problem: reject.NewProblem().
+			WithTitle("Stake outside of allowed range").
+			WithStatus(http.StatusBadRequest).
+			WithCode(invalidStake).
+			WithParam("min", l.min.String()).
+			WithParam("max", l.max.String()).
+			Build(),
+		Cause: err,
+	}
+}
+
+func stakeLimit(configKey string, fallback string) money.Amount {
+	configured := viper.GetString(configKey)
+	if configured == "" {
+		return money.MustParse(fallback)
+	}
+
+	limit, err := money.Parse(configured)
+	if err != nil {
+		log.Warn().Err(err).Msg(fmt.Sprintf("Invalid %s, using %s", configKey, fallback))
+		return money.MustParse(fallback)
+	}
+
+	return limit
+}
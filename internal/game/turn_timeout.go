@@ -0,0 +1,129 @@
+package game
+
+import (
+	"context"
+	"time"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/model"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/ws"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/wsevent"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultTurnTimeout = 5 * time.Minute
+	turnCheckInterval  = 15 * time.Second
+)
+
+// playedTurn is the turn a game is at and when it started, with the
+// ChallengerJoined event or the last Moved event
+type playedTurn struct {
+	GameId       uint64
+	OwnerId      uint64
+	ChallengerId uint64
+	Turn         uint64
+	StartedAt    int64
+}
+
+// turnTimeoutFromConfig reads GAME_TURN_TIMEOUT, how long a player who is
+// connected has for a move
+func turnTimeoutFromConfig() time.Duration {
+	turnTimeout := viper.GetDuration("GAME_TURN_TIMEOUT")
+	if turnTimeout <= 0 {
+		return defaultTurnTimeout
+	}
+	return turnTimeout
+}
+
+// watchTurns times out the turns of the games that are played until the
+// context is done. The contract has no forfeit, a turn that timed out is
+// announced to the game once, whichever instance sees it first.
+func (gs *gameService) watchTurns(ctx context.Context) {
+	ticker := time.NewTicker(turnCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := gs.timeOutTurns(ctx, time.Now()); err != nil {
+			log.Warn().Err(err).Msg("Error while checking turn timeouts")
+		}
+	}
+}
+
+func (gs *gameService) timeOutTurns(ctx context.Context, now time.Time) error {
+	var turns []playedTurn
+	result := gs.db.WithContext(ctx).Raw(`SELECT g.id AS game_id, g.owner_id, g.challenger_id, g.turn, max(e.applied_at) AS started_at
+		FROM game g JOIN game_event e ON e.game_id = g.id AND e.event_type IN ?
+		WHERE g.game_status = ? AND g.turn IS NOT NULL AND g.challenger_id IS NOT NULL
+		AND NOT EXISTS (SELECT 1 FROM turn_timeout t WHERE t.game_id = g.id AND t.turn = g.turn)
+		GROUP BY g.id`,
+		[]model.GameEventType{model.GameEventChallengerJoined, model.GameEventMoved}, model.GamePlaying).
+		Scan(&turns)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	for _, turn := range turns {
+		mover := turn.mover()
+		presence, err := gs.notificationHub.Presence(ctx, gameTopic(turn.GameId), []uint64{mover})
+		if err != nil {
+			return err
+		}
+
+		deadline := turnDeadline(time.UnixMilli(turn.StartedAt), presence[mover], gs.turnTimeout, gs.disconnectGracePeriod)
+		if now.Before(deadline) {
+			continue
+		}
+
+		result := gs.db.WithContext(ctx).Exec(`INSERT INTO turn_timeout (game_id, turn, user_id, timed_out_at) VALUES (?, ?, ?, ?)
+			ON CONFLICT (game_id, turn) DO NOTHING`, turn.GameId, turn.Turn, mover, now.UTC().UnixMilli())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		gs.notificationHub.Publish(gameTopic(turn.GameId), wsevent.TurnTimedOut{
+			GameId:   turn.GameId,
+			UserId:   mover,
+			Turn:     turn.Turn,
+			Deadline: deadline.UTC().UnixMilli(),
+		})
+	}
+	return nil
+}
+
+// turnDeadline is when the turn times out. A player who left the game keeps
+// the turn for the grace period from leaving, or from the start of the turn
+// when the player left before, if that ends before the turn timeout.
+func turnDeadline(startedAt time.Time, presence ws.Presence, turnTimeout time.Duration, gracePeriod time.Duration) time.Time {
+	deadline := startedAt.Add(turnTimeout)
+	if presence.Connected || presence.LeftAt.IsZero() {
+		return deadline
+	}
+
+	leftAt := presence.LeftAt
+	if leftAt.Before(startedAt) {
+		leftAt = startedAt
+	}
+	if graceUntil := leftAt.Add(gracePeriod); graceUntil.Before(deadline) {
+		return graceUntil
+	}
+	return deadline
+}
+
+// mover is the player of the turn, the owner created the game and moves on
+// odd turns
+func (t playedTurn) mover() uint64 {
+	if t.Turn%2 == 1 {
+		return t.OwnerId
+	}
+	return t.ChallengerId
+}
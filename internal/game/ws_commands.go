@@ -0,0 +1,85 @@
+package game
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/reject"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/ws"
+)
+
+// Commands players send over the websocket of a game, they act on the game
+// of the topic
+const (
+	CommandPlayMove = "PLAY_MOVE"
+	CommandResign   = "RESIGN"
+	CommandEmote    = "EMOTE"
+	CommandGetState = "GET_STATE"
+)
+
+func (gh *gameHandler) registerCommands(router *ws.CommandRouter) {
+	router.Handle(CommandPlayMove, gh.playMoveCommand)
+	router.Handle(CommandResign, gh.resignCommand)
+	router.Handle(CommandEmote, gh.emoteCommand)
+	router.Handle(CommandGetState, gh.getStateCommand)
+}
+
+func (gh *gameHandler) playMoveCommand(command ws.Command) (any, *reject.ProblemWithTrace) {
+	gameId, problem := commandGameId(command)
+	if problem != nil {
+		return nil, problem
+	}
+
+	body := PlayMoveRequest{}
+	if err := json.Unmarshal(command.Payload, &body); err != nil {
+		return nil, &reject.ProblemWithTrace{Problem: reject.BodyParseProblem(), Cause: err}
+	}
+
+	return gh.gameService.playMove(gameId, command.UserEmail, body)
+}
+
+func (gh *gameHandler) resignCommand(command ws.Command) (any, *reject.ProblemWithTrace) {
+	gameId, problem := commandGameId(command)
+	if problem != nil {
+		return nil, problem
+	}
+
+	return gh.gameService.resign(gameId, command.UserEmail)
+}
+
+func (gh *gameHandler) emoteCommand(command ws.Command) (any, *reject.ProblemWithTrace) {
+	gameId, problem := commandGameId(command)
+	if problem != nil {
+		return nil, problem
+	}
+
+	body := EmoteRequest{}
+	if err := json.Unmarshal(command.Payload, &body); err != nil {
+		return nil, &reject.ProblemWithTrace{Problem: reject.BodyParseProblem(), Cause: err}
+	}
+
+	return nil, gh.gameService.emote(gameId, command.UserEmail, body)
+}
+
+func (gh *gameHandler) getStateCommand(command ws.Command) (any, *reject.ProblemWithTrace) {
+	gameId, problem := commandGameId(command)
+	if problem != nil {
+		return nil, problem
+	}
+
+	return gh.gameService.getGame(gameId)
+}
+
+// commandGameId is the id of the game whose topic the command was sent on
+func commandGameId(command ws.Command) (uint64, *reject.ProblemWithTrace) {
+	if !strings.HasPrefix(command.Topic, "game/") {
+		return 0, &reject.ProblemWithTrace{Problem: reject.RequestParamsProblem()}
+	}
+
+	gameId, err := strconv.ParseUint(strings.TrimPrefix(command.Topic, "game/"), 10, 64)
+	if err != nil {
+		return 0, &reject.ProblemWithTrace{Problem: reject.RequestParamsProblem(), Cause: err}
+	}
+	return gameId, nil
+}
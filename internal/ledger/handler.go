@@ -0,0 +1,57 @@
+package ledger
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/middleware"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/model"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/utils"
+)
+
+type ledgerHandler struct {
+	ledger *LedgerService
+}
+
+func RegisterRoutes(rg *gin.RouterGroup, ledger *LedgerService) {
+	handler := ledgerHandler{ledger: ledger}
+
+	routes := rg.Group("/ledger")
+	routes.GET("", middleware.VerifyAuthToken, handler.getHistory)
+	routes.GET("/balance", middleware.VerifyAuthToken, handler.getBalance)
+}
+
+func (h ledgerHandler) getHistory(c *gin.Context) {
+	page, err := utils.NewPageRequest(c)
+	if err != nil {
+		c.JSON(err.Problem.Status, err.Problem)
+		return
+	}
+
+	entries, count, err := h.ledger.getHistory(page, utils.GetUserEmail(c))
+	if err != nil {
+		c.JSON(err.Problem.Status, err.Problem)
+		return
+	}
+
+	response := utils.NewPageResponse[model.StakeLedgerEntry]().
+		WithItems(entries).
+		WithItemCount(count)
+
+	if int(count) > (page.Token+1)*page.Size {
+		response.WithNextPageToken(int64(page.Token + 1))
+	}
+
+	c.JSON(http.StatusOK, response.Build())
+}
+
+func (h ledgerHandler) getBalance(c *gin.Context) {
+	balance, err := h.ledger.GetBalance(context.Background(), utils.GetUserEmail(c))
+	if err != nil {
+		c.JSON(err.Problem.Status, err.Problem)
+		return
+	}
+
+	c.JSON(http.StatusOK, balance)
+}
@@ -0,0 +1,192 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/flowclient"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/model"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/money"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/reject"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/utils"
+	"gorm.io/gorm"
+)
+
+const insufficientBalance = "error.ledger.insufficient-balance"
+
+// kept available on top of the stake to pay for transaction fees
+var feeReserve = money.MustParse("1.0")
+
+var ErrInsufficientBalance = errors.New("available balance does not cover the stake")
+
+// LedgerService keeps track of stakes committed to open games. A stake is
+// reserved when a game is created or joined and released (game cancelled) or
+// settled (game over) exactly once per user and game.
+type LedgerService struct {
+	Db         *gorm.DB
+	FlowClient flowclient.Client
+}
+
+type Balance struct {
+	OnChain   money.Amount `json:"onChain"`
+	Reserved  money.Amount `json:"reserved"`
+	Available money.Amount `json:"available"`
+}
+
+// Reserve commits the stake of the user to the game. It must run inside the
+// transaction that creates or joins the game, the user row is locked so
+// concurrent reservations of the same user are serialized.
+func (s *LedgerService) Reserve(tx *gorm.DB, userId uint64, gameId uint64, amount money.Amount, onChainBalance money.Amount) error {
+	result := tx.Exec("SELECT id FROM battleblocks_user WHERE id = ? FOR UPDATE", userId)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	reserved, err := s.reserved(tx, userId)
+	if err != nil {
+		return err
+	}
+
+	required, err := amount.Add(feeReserve)
+	if err != nil {
+		return err
+	}
+
+	if onChainBalance.Sub(reserved) < required {
+		return ErrInsufficientBalance
+	}
+
+	return tx.Create(&model.StakeLedgerEntry{
+		UserId:    userId,
+		GameId:    gameId,
+		EntryType: model.LedgerReserve,
+		Amount:    amount,
+		CreatedAt: time.Now().UTC().UnixMilli(),
+	}).Error
+}
+
+// ReleaseGame returns the reserved stakes of every player of the game.
+func (s *LedgerService) ReleaseGame(tx *gorm.DB, gameId uint64) error {
+	return s.close(tx, model.LedgerRelease, "r.game_id = ?", gameId)
+}
+
+// ReleaseChallenger returns the reserved stake of the user whose join failed.
+func (s *LedgerService) ReleaseChallenger(tx *gorm.DB, gameId uint64, userId uint64) error {
+	return s.close(tx, model.LedgerRelease, "r.game_id = ? AND r.user_id = ?", gameId, userId)
+}
+
+// SettleGame closes the reservations of a finished game, the stakes have been
+// paid out on-chain.
+func (s *LedgerService) SettleGame(tx *gorm.DB, gameId uint64) error {
+	return s.close(tx, model.LedgerSettle, "r.game_id = ?", gameId)
+}
+
+func (s *LedgerService) GetBalance(ctx context.Context, userEmail string) (*Balance, *reject.ProblemWithTrace) {
+	var wallet model.CustodialWallet
+	result := s.Db.Raw(`SELECT cw.* FROM battleblocks_user bu
+		JOIN custodial_wallet cw ON bu.custodial_wallet_id = cw.id
+		WHERE bu.email = ?`, userEmail).
+		First(&wallet)
+
+	if result.Error != nil || wallet.Address == nil {
+		err := errors.New("custodial wallet of user not found")
+		return nil, &reject.ProblemWithTrace{
+			Problem: reject.NotFoundProblem(),
+			Cause:   err,
+		}
+	}
+
+	onChain, err := s.OnChainBalance(ctx, *wallet.Address)
+	if err != nil {
+		return nil, &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(err),
+			Cause:   err,
+		}
+	}
+
+	var userId uint64
+	s.Db.Raw("SELECT id FROM battleblocks_user WHERE email = ?", userEmail).Scan(&userId)
+
+	reserved, err := s.reserved(s.Db, userId)
+	if err != nil {
+		return nil, &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(err),
+			Cause:   err,
+		}
+	}
+
+	return &Balance{
+		OnChain:   onChain,
+		Reserved:  reserved,
+		Available: onChain.Sub(reserved),
+	}, nil
+}
+
+func (s *LedgerService) OnChainBalance(ctx context.Context, address string) (money.Amount, error) {
+	balance, err := flowclient.GetFlowBalance(ctx, s.FlowClient, address)
+	if err != nil {
+		return 0, err
+	}
+
+	return money.Parse(balance)
+}
+
+func (s *LedgerService) getHistory(page utils.PageRequest, userEmail string) ([]model.StakeLedgerEntry, int64, *reject.ProblemWithTrace) {
+	entries := []model.StakeLedgerEntry{}
+	var count int64
+
+	userFilter := "user_id = (SELECT id FROM battleblocks_user WHERE email = ?)"
+	result := s.Db.Model(&model.StakeLedgerEntry{}).Where(userFilter, userEmail).Count(&count)
+	if result.Error != nil {
+		return nil, 0, &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(result.Error),
+			Cause:   result.Error,
+		}
+	}
+
+	result = s.Db.
+		Where(userFilter, userEmail).
+		Order("created_at DESC, id DESC").
+		Limit(page.Size).
+		Offset(page.Offset).
+		Find(&entries)
+
+	if result.Error != nil {
+		return nil, 0, &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(result.Error),
+			Cause:   result.Error,
+		}
+	}
+
+	return entries, count, nil
+}
+
+func (s *LedgerService) reserved(tx *gorm.DB, userId uint64) (money.Amount, error) {
+	var reserved money.Amount
+	result := tx.Raw(`SELECT COALESCE(SUM(CASE WHEN entry_type = 'RESERVE' THEN amount ELSE -amount END), 0)
+		FROM stake_ledger_entry WHERE user_id = ?`, userId).Scan(&reserved)
+
+	return reserved, result.Error
+}
+
+// books a closing entry for every open reservation matching the filter
+func (s *LedgerService) close(tx *gorm.DB, entryType model.LedgerEntryType, filter string, args ...any) error {
+	values := append([]any{entryType, time.Now().UTC().UnixMilli()}, args...)
+
+	return tx.Exec(`INSERT INTO stake_ledger_entry (user_id, game_id, entry_type, amount, created_at)
+		SELECT r.user_id, r.game_id, ?, r.amount, ? FROM stake_ledger_entry r
+		WHERE r.entry_type = 'RESERVE' AND `+filter+`
+		AND NOT EXISTS (SELECT 1 FROM stake_ledger_entry c
+			WHERE c.user_id = r.user_id AND c.game_id = r.game_id AND c.entry_type <> 'RESERVE')`,
+		values...).Error
+}
+
+func InsufficientBalanceProblem() reject.Problem {
+	return reject.NewProblem().
+		WithTitle("Available balance does not cover the stake").
+		WithStatus(http.StatusUnprocessableEntity).
+		WithCode(insufficientBalance).
+		Build()
+}
@@ -0,0 +1,93 @@
+package notification
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/middleware"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/notification"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/reject"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/utils"
+	"gorm.io/gorm"
+)
+
+type notificationHandler struct {
+	notifications *notificationService
+}
+
+func RegisterRoutes(rg *gin.RouterGroup, db *gorm.DB) {
+	handler := notificationHandler{
+		notifications: &notificationService{db: db},
+	}
+
+	routes := rg.Group("/notification", middleware.VerifyAuthToken)
+	routes.GET("", handler.getNotifications)
+	routes.POST("/read", handler.markAllRead)
+	routes.POST("/:id/read", handler.markRead)
+	routes.DELETE("/:id", handler.delete)
+}
+
+func (h notificationHandler) getNotifications(c *gin.Context) {
+	page, err := utils.NewPageRequest(c)
+	if err != nil {
+		c.JSON(err.Problem.Status, err.Problem)
+		return
+	}
+
+	unreadOnly := c.Query("unread") == "true"
+	notifications, count, err := h.notifications.findAll(page, utils.GetUserEmail(c), unreadOnly)
+	if err != nil {
+		c.JSON(err.Problem.Status, err.Problem)
+		return
+	}
+
+	response := utils.NewPageResponse[notification.View]().
+		WithItems(notifications).
+		WithItemCount(count)
+
+	if int(count) > (page.Token+1)*page.Size {
+		response.WithNextPageToken(int64(page.Token + 1))
+	}
+
+	c.JSON(http.StatusOK, response.Build())
+}
+
+func (h notificationHandler) markAllRead(c *gin.Context) {
+	if err := h.notifications.markRead(utils.GetUserEmail(c), nil); err != nil {
+		c.JSON(err.Problem.Status, err.Problem)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h notificationHandler) markRead(c *gin.Context) {
+	id, parseErr := strconv.ParseUint(c.Param("id"), 0, 64)
+	if parseErr != nil {
+		c.JSON(http.StatusBadRequest, reject.RequestParamsProblem())
+		return
+	}
+
+	if err := h.notifications.markRead(utils.GetUserEmail(c), &id); err != nil {
+		c.JSON(err.Problem.Status, err.Problem)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h notificationHandler) delete(c *gin.Context) {
+	id, parseErr := strconv.ParseUint(c.Param("id"), 0, 64)
+	if parseErr != nil {
+		c.JSON(http.StatusBadRequest, reject.RequestParamsProblem())
+		return
+	}
+
+	if err := h.notifications.delete(utils.GetUserEmail(c), id); err != nil {
+		c.JSON(err.Problem.Status, err.Problem)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,139 @@
+package notification
+
+import (
+	"time"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/model"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/notification"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/reject"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/utils"
+	"gorm.io/gorm"
+)
+
+type notificationService struct {
+	db *gorm.DB
+}
+
+func (s *notificationService) findAll(page utils.PageRequest, userEmail string, unreadOnly bool) ([]notification.View, int64, *reject.ProblemWithTrace) {
+	userId, problem := s.userId(userEmail)
+	if problem != nil {
+		return nil, 0, problem
+	}
+
+	query := s.db.Model(&model.Notification{}).Where("user_id = ?", userId)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var count int64
+	result := query.Count(&count)
+	if result.Error != nil {
+		return nil, 0, &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(result.Error),
+			Cause:   result.Error,
+		}
+	}
+
+	var notifications []model.Notification
+	result = query.
+		Order("created_at DESC, id DESC").
+		Limit(page.Size).
+		Offset(page.Offset).
+		Find(&notifications)
+
+	if result.Error != nil {
+		return nil, 0, &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(result.Error),
+			Cause:   result.Error,
+		}
+	}
+
+	views := make([]notification.View, 0, len(notifications))
+	for _, n := range notifications {
+		views = append(views, notification.ToView(n))
+	}
+
+	return views, count, nil
+}
+
+// markRead marks a notification of the user as read, all of them when id is nil
+func (s *notificationService) markRead(userEmail string, id *uint64) *reject.ProblemWithTrace {
+	userId, problem := s.userId(userEmail)
+	if problem != nil {
+		return problem
+	}
+
+	query := s.db.Model(&model.Notification{}).Where("user_id = ? AND read_at IS NULL", userId)
+	if id != nil {
+		query = query.Where("id = ?", *id)
+	}
+
+	result := query.Update("read_at", time.Now().UTC().UnixMilli())
+	if result.Error != nil {
+		return &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(result.Error),
+			Cause:   result.Error,
+		}
+	}
+
+	if id != nil && result.RowsAffected == 0 {
+		return s.exists(userId, *id)
+	}
+	return nil
+}
+
+func (s *notificationService) delete(userEmail string, id uint64) *reject.ProblemWithTrace {
+	userId, problem := s.userId(userEmail)
+	if problem != nil {
+		return problem
+	}
+
+	result := s.db.Where("id = ? AND user_id = ?", id, userId).Delete(&model.Notification{})
+	if result.Error != nil {
+		return &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(result.Error),
+			Cause:   result.Error,
+		}
+	}
+	if result.RowsAffected == 0 {
+		return &reject.ProblemWithTrace{
+			Problem: reject.NotFoundProblem(),
+		}
+	}
+	return nil
+}
+
+// exists tells a notification that was read already from a missing one
+func (s *notificationService) exists(userId uint64, id uint64) *reject.ProblemWithTrace {
+	var count int64
+	result := s.db.Model(&model.Notification{}).Where("id = ? AND user_id = ?", id, userId).Count(&count)
+	if result.Error != nil {
+		return &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(result.Error),
+			Cause:   result.Error,
+		}
+	}
+	if count == 0 {
+		return &reject.ProblemWithTrace{
+			Problem: reject.NotFoundProblem(),
+		}
+	}
+	return nil
+}
+
+func (s *notificationService) userId(userEmail string) (uint64, *reject.ProblemWithTrace) {
+	var userId uint64
+	result := s.db.Raw("SELECT u.id FROM battleblocks_user u WHERE email = ?", userEmail).Scan(&userId)
+	if result.Error != nil {
+		return 0, &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(result.Error),
+			Cause:   result.Error,
+		}
+	}
+	if result.RowsAffected == 0 {
+		return 0, &reject.ProblemWithTrace{
+			Problem: reject.NotFoundProblem(),
+		}
+	}
+	return userId, nil
+}
@@ -1,6 +1,16 @@
 package blockchain
 
-import "github.com/google/uuid"
+import (
+	"encoding/json"
+
+	"github.com/google/uuid"
+	jsoncdc "github.com/onflow/cadence/encoding/json"
+)
+
+// CommandSchemaVersion is bumped whenever the arguments of a command change.
+// Version 1 used untyped positional payloads, version 2 sends every argument
+// as a JSON-CDC encoded Cadence value.
+const CommandSchemaVersion = 2
 
 type Authorizer struct {
 	KmsResourceId        string `json:"kmsResourceId"`
@@ -8,21 +18,59 @@ type Authorizer struct {
 }
 
 type Command struct {
-	Id          string       `json:"id"`
-	Type        string       `json:"type"`
-	Payload     []any        `json:"payload"`
-	Authorizers []Authorizer `json:"authorizers"`
+	Id          string            `json:"id"`
+	Type        string            `json:"type"`
+	Version     int               `json:"version"`
+	Payload     []json.RawMessage `json:"payload"`
+	Authorizers []Authorizer      `json:"authorizers"`
+	Reference   string            `json:"-"`
 }
 
 func (bc Command) GetEventTopicName() string {
 	return "blockchain.flow.commands"
 }
 
-func NewBlockchainCommand(commandType string, payload []any, authorizers []Authorizer) Command {
+// OrderingKey keeps the commands acting on the same entity in order.
+func (bc Command) OrderingKey() string {
+	return bc.Reference
+}
+
+// WithReference ties the command to the entity it acts on (e.g. "game/12") so
+// events that do not carry the command id can still be correlated with it.
+func (bc Command) WithReference(reference string) Command {
+	bc.Reference = reference
+	return bc
+}
+
+func NewBlockchainCommand(payload CommandPayload, authorizers []Authorizer) (Command, error) {
+	arguments, err := EncodeArguments(payload)
+	if err != nil {
+		return Command{}, err
+	}
+
 	return Command{
 		Id:          uuid.New().String(),
-		Type:        commandType,
-		Payload:     payload,
+		Type:        payload.CommandType(),
+		Version:     CommandSchemaVersion,
+		Payload:     arguments,
 		Authorizers: authorizers,
+	}, nil
+}
+
+func EncodeArguments(payload CommandPayload) ([]json.RawMessage, error) {
+	values, err := payload.Arguments()
+	if err != nil {
+		return nil, err
 	}
+
+	arguments := make([]json.RawMessage, len(values))
+	for i, value := range values {
+		encoded, err := jsoncdc.Encode(value)
+		if err != nil {
+			return nil, err
+		}
+		arguments[i] = encoded
+	}
+
+	return arguments, nil
 }
@@ -0,0 +1,275 @@
+package blockchain
+
+import (
+	"sort"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/money"
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+)
+
+const (
+	CommandGameCreate        = "GAME_CREATE"
+	CommandGameJoin          = "GAME_JOIN"
+	CommandGameMove          = "GAME_MOVE"
+	CommandGameResign        = "GAME_RESIGN"
+	CommandNftMint           = "NFT_MINT"
+	CommandNftTransfer       = "NFT_TRANSFER"
+	CommandNftTransferAdmin  = "NFT_TRANSFER_ADMIN"
+	CommandNftBurn           = "NFT_BURN"
+	CommandCreateUserAccount = "CREATE_USER_ACCOUNT"
+)
+
+// CommandPayload is the typed payload of a command. Arguments returns the
+// transaction arguments in the order the Cadence transaction declares them.
+type CommandPayload interface {
+	CommandType() string
+	Arguments() ([]cadence.Value, error)
+}
+
+var (
+	_ CommandPayload = GameCreate{}
+	_ CommandPayload = GameJoin{}
+	_ CommandPayload = GameMove{}
+	_ CommandPayload = GameResign{}
+	_ CommandPayload = NftMint{}
+	_ CommandPayload = NftTransfer{}
+	_ CommandPayload = NftTransferAdmin{}
+	_ CommandPayload = NftBurn{}
+	_ CommandPayload = CreateUserAccount{}
+)
+
+// GameCreate: (wager: UFix64, rootHash: [UInt8], payload: UInt64)
+type GameCreate struct {
+	Wager      money.Amount
+	MerkleRoot []byte
+	GameId     uint64
+}
+
+func (p GameCreate) CommandType() string {
+	return CommandGameCreate
+}
+
+func (p GameCreate) Arguments() ([]cadence.Value, error) {
+	return []cadence.Value{
+		p.Wager.UFix64(),
+		bytesArgument(p.MerkleRoot),
+		cadence.NewUInt64(p.GameId),
+	}, nil
+}
+
+// GameJoin: (gameID: UInt64, wager: UFix64, rootHash: [UInt8])
+type GameJoin struct {
+	FlowGameId uint64
+	Wager      money.Amount
+	MerkleRoot []byte
+}
+
+func (p GameJoin) CommandType() string {
+	return CommandGameJoin
+}
+
+func (p GameJoin) Arguments() ([]cadence.Value, error) {
+	return []cadence.Value{
+		cadence.NewUInt64(p.FlowGameId),
+		p.Wager.UFix64(),
+		bytesArgument(p.MerkleRoot),
+	}, nil
+}
+
+// GameMove: (gameID: UInt64, guessX: UInt64, guessY: UInt64, proof: [[UInt8]],
+// blockPresent: Bool?, opponentGuessX: UInt64?, opponentGuessY: UInt64?, nonce: UInt64?)
+//
+// The opponent fields reveal the result of the opponent's previous guess and
+// are nil on the first move of a game.
+type GameMove struct {
+	FlowGameId     uint64
+	GuessX         uint64
+	GuessY         uint64
+	Proof          [][]byte
+	BlockPresent   *bool
+	OpponentGuessX *uint64
+	OpponentGuessY *uint64
+	Nonce          *uint64
+}
+
+func (p GameMove) CommandType() string {
+	return CommandGameMove
+}
+
+func (p GameMove) Arguments() ([]cadence.Value, error) {
+	proof := make([]cadence.Value, len(p.Proof))
+	for i, sibling := range p.Proof {
+		proof[i] = bytesArgument(sibling)
+	}
+
+	var blockPresent cadence.Value
+	if p.BlockPresent != nil {
+		blockPresent = cadence.NewBool(*p.BlockPresent)
+	}
+
+	return []cadence.Value{
+		cadence.NewUInt64(p.FlowGameId),
+		cadence.NewUInt64(p.GuessX),
+		cadence.NewUInt64(p.GuessY),
+		cadence.NewArray(proof),
+		cadence.NewOptional(blockPresent),
+		optionalUInt64(p.OpponentGuessX),
+		optionalUInt64(p.OpponentGuessY),
+		optionalUInt64(p.Nonce),
+	}, nil
+}
+
+// GameResign: (gameID: UInt64)
+//
+// The opponent of the signer wins the game.
+type GameResign struct {
+	FlowGameId uint64
+}
+
+func (p GameResign) CommandType() string {
+	return CommandGameResign
+}
+
+func (p GameResign) Arguments() ([]cadence.Value, error) {
+	return []cadence.Value{cadence.NewUInt64(p.FlowGameId)}, nil
+}
+
+// NftMint: (recipient: Address, name: String, metadata: {String: String})
+type NftMint struct {
+	RecipientAddress string
+	Name             string
+	BlockType        string
+	Rarity           string
+	ColorHex         string
+}
+
+func (p NftMint) CommandType() string {
+	return CommandNftMint
+}
+
+func (p NftMint) Arguments() ([]cadence.Value, error) {
+	name, err := cadence.NewString(p.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := stringDictionary(map[string]string{
+		"type":     p.BlockType,
+		"rarity":   p.Rarity,
+		"colorHex": p.ColorHex,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return []cadence.Value{
+		addressArgument(p.RecipientAddress),
+		name,
+		metadata,
+	}, nil
+}
+
+// NftTransfer: (recipient: Address, withdrawID: UInt64)
+type NftTransfer struct {
+	RecipientAddress string
+	WithdrawId       uint64
+}
+
+func (p NftTransfer) CommandType() string {
+	return CommandNftTransfer
+}
+
+func (p NftTransfer) Arguments() ([]cadence.Value, error) {
+	return []cadence.Value{
+		addressArgument(p.RecipientAddress),
+		cadence.NewUInt64(p.WithdrawId),
+	}, nil
+}
+
+// NftTransferAdmin: (recipient: Address, withdrawID: UInt64)
+type NftTransferAdmin struct {
+	RecipientAddress string
+	WithdrawId       uint64
+}
+
+func (p NftTransferAdmin) CommandType() string {
+	return CommandNftTransferAdmin
+}
+
+func (p NftTransferAdmin) Arguments() ([]cadence.Value, error) {
+	return NftTransfer(p).Arguments()
+}
+
+// NftBurn: (id: UInt64)
+type NftBurn struct {
+	Id uint64
+}
+
+func (p NftBurn) CommandType() string {
+	return CommandNftBurn
+}
+
+func (p NftBurn) Arguments() ([]cadence.Value, error) {
+	return []cadence.Value{cadence.NewUInt64(p.Id)}, nil
+}
+
+// CreateUserAccount: (publicKey: String, initialFundingAmount: UFix64)
+type CreateUserAccount struct {
+	PublicKey            string
+	InitialFundingAmount money.Amount
+}
+
+func (p CreateUserAccount) CommandType() string {
+	return CommandCreateUserAccount
+}
+
+func (p CreateUserAccount) Arguments() ([]cadence.Value, error) {
+	publicKey, err := cadence.NewString(p.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return []cadence.Value{publicKey, p.InitialFundingAmount.UFix64()}, nil
+}
+
+func bytesArgument(data []byte) cadence.Array {
+	values := make([]cadence.Value, len(data))
+	for i, b := range data {
+		values[i] = cadence.NewUInt8(b)
+	}
+	return cadence.NewArray(values)
+}
+
+func optionalUInt64(value *uint64) cadence.Optional {
+	if value == nil {
+		return cadence.NewOptional(nil)
+	}
+	return cadence.NewOptional(cadence.NewUInt64(*value))
+}
+
+func addressArgument(address string) cadence.Address {
+	return cadence.BytesToAddress(flow.HexToAddress(address).Bytes())
+}
+
+func stringDictionary(entries map[string]string) (cadence.Dictionary, error) {
+	keys := make([]string, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]cadence.KeyValuePair, 0, len(entries))
+	for _, k := range keys {
+		key, err := cadence.NewString(k)
+		if err != nil {
+			return cadence.Dictionary{}, err
+		}
+		value, err := cadence.NewString(entries[k])
+		if err != nil {
+			return cadence.Dictionary{}, err
+		}
+		pairs = append(pairs, cadence.KeyValuePair{Key: key, Value: value})
+	}
+	return cadence.NewDictionary(pairs), nil
+}
@@ -0,0 +1,101 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/money"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/pubsub"
+)
+
+// The golden files are the arguments the transaction service decodes, a
+// change to them is a change of the command schema and needs a new
+// CommandSchemaVersion. Regenerate them with go test -update.
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+func TestArgumentsGolden(t *testing.T) {
+	blockPresent := true
+	opponentX, opponentY, nonce := uint64(3), uint64(4), uint64(987654321)
+
+	cases := []struct {
+		name    string
+		payload CommandPayload
+	}{
+		{"game_create", GameCreate{Wager: money.Units(150_000_000), MerkleRoot: []byte{0xde, 0xad, 0xbe, 0xef}, GameId: 12}},
+		{"game_join", GameJoin{FlowGameId: 7, Wager: money.Units(150_000_000), MerkleRoot: []byte{0x01, 0x02}}},
+		{"game_move_first", GameMove{FlowGameId: 7, GuessX: 1, GuessY: 2}},
+		{"game_move_reveal", GameMove{
+			FlowGameId:     7,
+			GuessX:         1,
+			GuessY:         2,
+			Proof:          [][]byte{{0x0a, 0x0b}, {0x0c}},
+			BlockPresent:   &blockPresent,
+			OpponentGuessX: &opponentX,
+			OpponentGuessY: &opponentY,
+			Nonce:          &nonce,
+		}},
+		{"nft_mint", NftMint{RecipientAddress: "0xf8d6e0586b0a20c7", Name: "Red block", BlockType: "SQUARE", Rarity: "COMMON", ColorHex: "#ff0000"}},
+		{"nft_transfer", NftTransfer{RecipientAddress: "0xf8d6e0586b0a20c7", WithdrawId: 42}},
+		{"nft_transfer_admin", NftTransferAdmin{RecipientAddress: "0x01cf0e2f2f715450", WithdrawId: 42}},
+		{"nft_burn", NftBurn{Id: 42}},
+		{"create_user_account", CreateUserAccount{PublicKey: "a1b2c3", InitialFundingAmount: money.Units(1_000_000)}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			arguments, err := EncodeArguments(c.payload)
+			if err != nil {
+				t.Fatalf("encoding arguments: %v", err)
+			}
+
+			var got bytes.Buffer
+			for _, argument := range arguments {
+				got.Write(bytes.TrimSpace(argument))
+				got.WriteByte('\n')
+			}
+
+			golden := filepath.Join("testdata", "arguments", c.name+".jsonl")
+			if *update {
+				if err := os.WriteFile(golden, got.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("reading golden file: %v", err)
+			}
+			if got.String() != string(want) {
+				t.Errorf("arguments of %s changed\ngot:\n%s\nwant:\n%s", c.payload.CommandType(), got.String(), want)
+			}
+		})
+	}
+}
+
+func TestPublishedCommandHasSchemaVersion(t *testing.T) {
+	command, err := NewBlockchainCommand(NftBurn{Id: 42}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var published map[string]json.RawMessage
+	if err := json.Unmarshal(pubsub.EncodeMessage(command), &published); err != nil {
+		t.Fatal(err)
+	}
+
+	version, ok := published["version"]
+	if !ok {
+		t.Fatal("published command has no version")
+	}
+	if strings.TrimSpace(string(version)) != "2" || CommandSchemaVersion != 2 {
+		t.Errorf("version = %s, CommandSchemaVersion = %d", version, CommandSchemaVersion)
+	}
+	if string(published["type"]) != `"NFT_BURN"` {
+		t.Errorf("type = %s", published["type"])
+	}
+}
@@ -0,0 +1,22 @@
+package blockchain
+
+import (
+	"bytes"
+
+	keccak "github.com/wealdtech/go-merkletree/keccak256"
+)
+
+// VerifyMerkleProof checks a board leaf against a merkle root the way the
+// game contract does: the leaf is hashed with keccak256 and combined with
+// every sibling, each pair sorted before hashing, so no path is needed.
+func VerifyMerkleProof(leaf []byte, siblings [][]byte, root []byte) bool {
+	hash := keccak.New().Hash(leaf)
+	for _, sibling := range siblings {
+		if bytes.Compare(hash, sibling) < 0 {
+			hash = keccak.New().Hash(append(append([]byte{}, hash...), sibling...))
+		} else {
+			hash = keccak.New().Hash(append(append([]byte{}, sibling...), hash...))
+		}
+	}
+	return bytes.Equal(hash, root)
+}
@@ -0,0 +1,125 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/model"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/outbox"
+	"gorm.io/gorm"
+)
+
+const (
+	CommandIdAttribute     = "commandId"
+	TransactionIdAttribute = "transactionId"
+)
+
+var finishedCommandStatuses = []model.CommandStatus{model.CommandSealed, model.CommandFailed}
+
+// Dispatch records the command in the command registry and enqueues it for
+// the transaction service, both within the given transaction.
+func Dispatch(tx *gorm.DB, cmd Command) error {
+	payload, err := json.Marshal(cmd.Payload)
+	if err != nil {
+		return err
+	}
+
+	authorizers, err := json.Marshal(cmd.Authorizers)
+	if err != nil {
+		return err
+	}
+
+	var reference *string
+	if cmd.Reference != "" {
+		reference = &cmd.Reference
+	}
+
+	now := time.Now().UTC().UnixMilli()
+	result := tx.Create(&model.BlockchainCommand{
+		Id:          cmd.Id,
+		Type:        cmd.Type,
+		Reference:   reference,
+		Payload:     string(payload),
+		Authorizers: string(authorizers),
+		Status:      model.CommandPending,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return outbox.Enqueue(tx, cmd)
+}
+
+func MarkSubmitted(tx *gorm.DB, commandId string, transactionId string) error {
+	return tx.
+		Model(&model.BlockchainCommand{}).
+		Where("id = ? AND status = ?", commandId, model.CommandPending).
+		Updates(map[string]any{
+			"status":              model.CommandSubmitted,
+			"flow_transaction_id": transactionId,
+			"updated_at":          time.Now().UTC().UnixMilli(),
+		}).Error
+}
+
+// MarkFailed returns the command it marked as failed, nil when the command
+// had already finished, so a late or duplicate failure is ignored.
+func MarkFailed(tx *gorm.DB, commandId string, transactionId string, reason string) (*model.BlockchainCommand, error) {
+	updates := map[string]any{
+		"status":     model.CommandFailed,
+		"error":      reason,
+		"updated_at": time.Now().UTC().UnixMilli(),
+	}
+	if transactionId != "" {
+		updates["flow_transaction_id"] = transactionId
+	}
+
+	result := tx.
+		Model(&model.BlockchainCommand{}).
+		Where("id = ? AND status NOT IN ?", commandId, finishedCommandStatuses).
+		Updates(updates)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+
+	var command model.BlockchainCommand
+	result = tx.Where("id = ?", commandId).First(&command)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return &command, nil
+}
+
+// CorrelateEvent marks the command that produced an event as sealed. The
+// command is looked up by the command id or Flow transaction id message
+// attributes and, when those are missing, by the latest unfinished command of
+// the given type acting on reference.
+func CorrelateEvent(tx *gorm.DB, attributes map[string]string, commandType string, reference string) error {
+	updates := map[string]any{
+		"status":     model.CommandSealed,
+		"updated_at": time.Now().UTC().UnixMilli(),
+	}
+
+	transactionId := attributes[TransactionIdAttribute]
+	if transactionId != "" {
+		updates["flow_transaction_id"] = transactionId
+	}
+
+	query := tx.Model(&model.BlockchainCommand{})
+	switch {
+	case attributes[CommandIdAttribute] != "":
+		query = query.Where("id = ?", attributes[CommandIdAttribute])
+	case transactionId != "":
+		query = query.Where("flow_transaction_id = ?", transactionId)
+	case reference != "":
+		query = query.Where(`id = (SELECT id FROM blockchain_command
+			WHERE type = ? AND reference = ? AND status NOT IN ?
+			ORDER BY created_at DESC LIMIT 1)`, commandType, reference, finishedCommandStatuses)
+	default:
+		return nil
+	}
+
+	return query.Updates(updates).Error
+}
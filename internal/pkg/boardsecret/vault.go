@@ -0,0 +1,302 @@
+package boardsecret
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/envelope"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/model"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+const rotationBatchSize = 100
+
+// Vault keeps the hidden part of a board (block presence and nonce of every
+// grid point) encrypted at rest. Every (game, user) board gets its own data
+// key which is stored wrapped by the configured KeyProvider.
+type Vault struct {
+	keys envelope.KeyProvider
+}
+
+type gridSecret struct {
+	BlockPresent bool   `json:"p"`
+	Nonce        string `json:"n"`
+}
+
+func NewVault(keys envelope.KeyProvider) *Vault {
+	return &Vault{keys: keys}
+}
+
+func (v *Vault) Store(ctx context.Context, tx *gorm.DB, gameId uint64, userId uint64, points []*model.GameGridPoint) error {
+	if err := v.seal(ctx, tx, gameId, userId, points); err != nil {
+		return err
+	}
+
+	return tx.Create(&points).Error
+}
+
+// EncryptPlaintext moves the boards stored before encryption, with the block
+// presence and nonce in plaintext columns, into the encrypted secret. Every
+// board is encrypted in its own transaction, running it again continues
+// with the boards left.
+func (v *Vault) EncryptPlaintext(ctx context.Context, db *gorm.DB) (int, error) {
+	encrypted := 0
+	for {
+		var boards []struct {
+			GameId uint64
+			UserId uint64
+		}
+		result := db.Raw(`SELECT DISTINCT game_id, user_id FROM game_grid_point
+			WHERE secret IS NULL LIMIT ?`, rotationBatchSize).Scan(&boards)
+
+		if result.Error != nil {
+			return encrypted, result.Error
+		}
+		if len(boards) == 0 {
+			return encrypted, nil
+		}
+
+		for _, board := range boards {
+			err := db.Transaction(func(tx *gorm.DB) error {
+				return v.encryptBoard(ctx, tx, board.GameId, board.UserId)
+			})
+			if err != nil {
+				return encrypted, fmt.Errorf("cannot encrypt board of game %d user %d: %w", board.GameId, board.UserId, err)
+			}
+			encrypted++
+		}
+
+		log.Info().Msg(fmt.Sprintf("Encrypted %d boards", encrypted))
+	}
+}
+
+func (v *Vault) encryptBoard(ctx context.Context, tx *gorm.DB, gameId uint64, userId uint64) error {
+	// the plaintext columns are not part of the model
+	var rows []struct {
+		CoordinateX  uint64
+		CoordinateY  uint64
+		BlockPresent bool
+		Nonce        string
+	}
+	result := tx.Raw(`SELECT coordinate_x, coordinate_y, block_present, nonce FROM game_grid_point
+		WHERE game_id = ? AND user_id = ? AND secret IS NULL FOR UPDATE`, gameId, userId).Scan(&rows)
+
+	if result.Error != nil || len(rows) == 0 {
+		return result.Error
+	}
+
+	points := make([]*model.GameGridPoint, len(rows))
+	for i, row := range rows {
+		points[i] = &model.GameGridPoint{
+			GameId:       gameId,
+			UserId:       userId,
+			CoordinateX:  row.CoordinateX,
+			CoordinateY:  row.CoordinateY,
+			BlockPresent: row.BlockPresent,
+			Nonce:        row.Nonce,
+		}
+	}
+
+	if err := v.seal(ctx, tx, gameId, userId, points); err != nil {
+		return err
+	}
+
+	for _, point := range points {
+		result = tx.
+			Model(&model.GameGridPoint{}).
+			Where("game_id = ? AND user_id = ? AND coordinate_x = ? AND coordinate_y = ?",
+				point.GameId, point.UserId, point.CoordinateX, point.CoordinateY).
+			Update("secret", point.Secret)
+
+		if result.Error != nil {
+			return result.Error
+		}
+	}
+
+	return nil
+}
+
+// seal encrypts the secrets of the points of a board with a new data key
+func (v *Vault) seal(ctx context.Context, tx *gorm.DB, gameId uint64, userId uint64, points []*model.GameGridPoint) error {
+	dataKey, err := envelope.GenerateDataKey()
+	if err != nil {
+		return err
+	}
+
+	keyId, wrappedKey, err := v.keys.WrapKey(ctx, dataKey)
+	if err != nil {
+		return err
+	}
+
+	result := tx.Create(&model.BoardKey{
+		GameId:     gameId,
+		UserId:     userId,
+		KeyId:      keyId,
+		WrappedKey: wrappedKey,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	for _, point := range points {
+		plaintext, _ := json.Marshal(gridSecret{BlockPresent: point.BlockPresent, Nonce: point.Nonce})
+		point.Secret, err = envelope.Seal(dataKey, plaintext, additionalData(point))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (v *Vault) Load(ctx context.Context, db *gorm.DB, gameId uint64, userId uint64) ([]model.GameGridPoint, error) {
+	var points []model.GameGridPoint
+	result := db.
+		Where("game_id = ? AND user_id = ?", gameId, userId).
+		Order("coordinate_x, coordinate_y").
+		Find(&points)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return points, v.open(ctx, db, gameId, points)
+}
+
+func (v *Vault) LoadGame(ctx context.Context, db *gorm.DB, gameId uint64) ([]model.GameGridPoint, error) {
+	var points []model.GameGridPoint
+	result := db.
+		Where("game_id = ?", gameId).
+		Order("user_id, coordinate_x, coordinate_y").
+		Find(&points)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return points, v.open(ctx, db, gameId, points)
+}
+
+func (v *Vault) LoadPoint(ctx context.Context, db *gorm.DB, gameId uint64, userId uint64, x uint64, y uint64) (*model.GameGridPoint, error) {
+	var point model.GameGridPoint
+	result := db.
+		Where("game_id = ? AND user_id = ? AND coordinate_x = ? AND coordinate_y = ?", gameId, userId, x, y).
+		First(&point)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	points := []model.GameGridPoint{point}
+	if err := v.open(ctx, db, gameId, points); err != nil {
+		return nil, err
+	}
+
+	return &points[0], nil
+}
+
+// Rotate rewraps every board data key that is not wrapped with the current
+// primary key. The encrypted grid points stay untouched.
+func (v *Vault) Rotate(ctx context.Context, db *gorm.DB) (int, error) {
+	primaryKeyId, err := v.keys.PrimaryKeyId(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	rotated := 0
+	for {
+		var boardKeys []model.BoardKey
+		result := db.
+			Where("key_id <> ?", primaryKeyId).
+			Limit(rotationBatchSize).
+			Find(&boardKeys)
+
+		if result.Error != nil {
+			return rotated, result.Error
+		}
+		if len(boardKeys) == 0 {
+			return rotated, nil
+		}
+
+		for _, boardKey := range boardKeys {
+			dataKey, err := v.keys.UnwrapKey(ctx, boardKey.KeyId, boardKey.WrappedKey)
+			if err != nil {
+				return rotated, fmt.Errorf("cannot unwrap key of game %d user %d: %w", boardKey.GameId, boardKey.UserId, err)
+			}
+
+			keyId, wrappedKey, err := v.keys.WrapKey(ctx, dataKey)
+			if err != nil {
+				return rotated, err
+			}
+			if keyId != primaryKeyId {
+				return rotated, fmt.Errorf("key provider wrapped with %s instead of primary key %s", keyId, primaryKeyId)
+			}
+
+			result = db.
+				Model(&model.BoardKey{}).
+				Where("game_id = ? AND user_id = ?", boardKey.GameId, boardKey.UserId).
+				Updates(map[string]any{
+					"key_id":      keyId,
+					"wrapped_key": wrappedKey,
+				})
+
+			if result.Error != nil {
+				return rotated, result.Error
+			}
+			rotated++
+		}
+
+		log.Info().Msg(fmt.Sprintf("Rotated %d board keys", rotated))
+	}
+}
+
+func (v *Vault) open(ctx context.Context, db *gorm.DB, gameId uint64, points []model.GameGridPoint) error {
+	dataKeys := map[uint64][]byte{}
+
+	for i := range points {
+		dataKey, ok := dataKeys[points[i].UserId]
+		if !ok {
+			var err error
+			dataKey, err = v.dataKey(ctx, db, gameId, points[i].UserId)
+			if err != nil {
+				return err
+			}
+			dataKeys[points[i].UserId] = dataKey
+		}
+
+		plaintext, err := envelope.Open(dataKey, points[i].Secret, additionalData(&points[i]))
+		if err != nil {
+			return fmt.Errorf("cannot decrypt grid point of game %d user %d: %w", gameId, points[i].UserId, err)
+		}
+
+		var secret gridSecret
+		if err := json.Unmarshal(plaintext, &secret); err != nil {
+			return err
+		}
+
+		points[i].BlockPresent = secret.BlockPresent
+		points[i].Nonce = secret.Nonce
+	}
+
+	return nil
+}
+
+func (v *Vault) dataKey(ctx context.Context, db *gorm.DB, gameId uint64, userId uint64) ([]byte, error) {
+	var boardKey model.BoardKey
+	result := db.
+		Where("game_id = ? AND user_id = ?", gameId, userId).
+		First(&boardKey)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return v.keys.UnwrapKey(ctx, boardKey.KeyId, boardKey.WrappedKey)
+}
+
+// binds the ciphertext to its grid point so secrets cannot be swapped between rows
+func additionalData(point *model.GameGridPoint) []byte {
+	return []byte(fmt.Sprintf("%d/%d/%d/%d", point.GameId, point.UserId, point.CoordinateX, point.CoordinateY))
+}
@@ -0,0 +1,30 @@
+package envelope
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/viper"
+)
+
+// KeyProvider wraps and unwraps data encryption keys with a key encryption
+// key it owns. The returned key id identifies the key (version) that was used
+// so the data key can be unwrapped after the primary key has been rotated.
+type KeyProvider interface {
+	PrimaryKeyId(ctx context.Context) (string, error)
+	WrapKey(ctx context.Context, dataKey []byte) (keyId string, wrapped []byte, err error)
+	UnwrapKey(ctx context.Context, keyId string, wrapped []byte) ([]byte, error)
+}
+
+func NewKeyProviderFromConfig(ctx context.Context) (KeyProvider, error) {
+	providerType := viper.GetString("BOARD_KEY_PROVIDER")
+
+	switch providerType {
+	case "", "local":
+		return NewLocalKeyProvider(viper.GetString("BOARD_KEY_FILE"))
+	case "kms":
+		return NewKmsKeyProvider(ctx, viper.GetString("BOARD_KMS_KEY_NAME"))
+	default:
+		return nil, fmt.Errorf("unknown board key provider %s", providerType)
+	}
+}
@@ -0,0 +1,70 @@
+package envelope
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	kms "cloud.google.com/go/kms/apiv1"
+	"cloud.google.com/go/kms/apiv1/kmspb"
+)
+
+// KmsKeyProvider wraps data keys with a symmetric Google Cloud KMS key. Key
+// versions are managed (and rotated) by KMS; the key id returned when wrapping
+// is the crypto key version that KMS used.
+type KmsKeyProvider struct {
+	client  *kms.KeyManagementClient
+	keyName string
+}
+
+func NewKmsKeyProvider(ctx context.Context, keyName string) (*KmsKeyProvider, error) {
+	if keyName == "" {
+		return nil, errors.New("kms key provider missing key name")
+	}
+
+	client, err := kms.NewKeyManagementClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KmsKeyProvider{client: client, keyName: keyName}, nil
+}
+
+func (p *KmsKeyProvider) PrimaryKeyId(ctx context.Context) (string, error) {
+	key, err := p.client.GetCryptoKey(ctx, &kmspb.GetCryptoKeyRequest{Name: p.keyName})
+	if err != nil {
+		return "", err
+	}
+	if key.Primary == nil {
+		return "", errors.New("kms key has no primary version")
+	}
+	return key.Primary.Name, nil
+}
+
+func (p *KmsKeyProvider) WrapKey(ctx context.Context, dataKey []byte) (string, []byte, error) {
+	response, err := p.client.Encrypt(ctx, &kmspb.EncryptRequest{
+		Name:      p.keyName,
+		Plaintext: dataKey,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return response.Name, response.Ciphertext, nil
+}
+
+func (p *KmsKeyProvider) UnwrapKey(ctx context.Context, keyId string, wrapped []byte) ([]byte, error) {
+	// KMS decrypts with the crypto key, the version is encoded in the ciphertext
+	keyName, _, _ := strings.Cut(keyId, "/cryptoKeyVersions/")
+	response, err := p.client.Decrypt(ctx, &kmspb.DecryptRequest{
+		Name:       keyName,
+		Ciphertext: wrapped,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response.Plaintext, nil
+}
+
+func (p *KmsKeyProvider) Close() error {
+	return p.client.Close()
+}
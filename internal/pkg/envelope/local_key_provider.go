@@ -0,0 +1,87 @@
+package envelope
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LocalKeyProvider reads key encryption keys from a file, one "keyId:base64key"
+// per line. The first key is the primary one used for wrapping, the remaining
+// keys are kept so data keys wrapped before a rotation can still be unwrapped.
+type LocalKeyProvider struct {
+	primaryKeyId string
+	keys         map[string][]byte
+}
+
+func NewLocalKeyProvider(path string) (*LocalKeyProvider, error) {
+	if path == "" {
+		return nil, errors.New("local key provider missing key file path")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	provider := &LocalKeyProvider{keys: map[string][]byte{}}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		keyId, encodedKey, found := strings.Cut(line, ":")
+		if !found {
+			return nil, fmt.Errorf("malformed line in key file %s", path)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encodedKey)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode key %s: %w", keyId, err)
+		}
+		if len(key) != dataKeySize {
+			return nil, fmt.Errorf("key %s must be %d bytes long", keyId, dataKeySize)
+		}
+
+		if provider.primaryKeyId == "" {
+			provider.primaryKeyId = keyId
+		}
+		provider.keys[keyId] = key
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if provider.primaryKeyId == "" {
+		return nil, fmt.Errorf("key file %s contains no keys", path)
+	}
+
+	return provider, nil
+}
+
+func (p *LocalKeyProvider) PrimaryKeyId(_ context.Context) (string, error) {
+	return p.primaryKeyId, nil
+}
+
+func (p *LocalKeyProvider) WrapKey(_ context.Context, dataKey []byte) (string, []byte, error) {
+	wrapped, err := Seal(p.keys[p.primaryKeyId], dataKey, []byte(p.primaryKeyId))
+	if err != nil {
+		return "", nil, err
+	}
+	return p.primaryKeyId, wrapped, nil
+}
+
+func (p *LocalKeyProvider) UnwrapKey(_ context.Context, keyId string, wrapped []byte) ([]byte, error) {
+	key, ok := p.keys[keyId]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %s", keyId)
+	}
+	return Open(key, wrapped, []byte(keyId))
+}
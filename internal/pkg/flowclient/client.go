@@ -0,0 +1,170 @@
+package flowclient
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jpillora/backoff"
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/onflow/flow-go-sdk/access/grpc"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+	grpcCodes "google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+)
+
+const (
+	NetworkEmulator = "emulator"
+	NetworkTestnet  = "testnet"
+	NetworkMainnet  = "mainnet"
+	NetworkFake     = "fake"
+
+	defaultTimeout    = 10 * time.Second
+	defaultMaxRetries = 3
+)
+
+// Client is the subset of the Flow access API the backend uses. Scripts and
+// event queries must go through it so tests and local development can swap in
+// the Fake instead of a real access node.
+type Client interface {
+	ExecuteScript(ctx context.Context, script []byte, arguments []cadence.Value) (cadence.Value, error)
+	GetLatestBlockHeight(ctx context.Context) (uint64, error)
+	GetEventsForHeightRange(ctx context.Context, eventType string, startHeight uint64, endHeight uint64) ([]flow.BlockEvents, error)
+	Close() error
+}
+
+type accessClient struct {
+	client     *grpc.Client
+	timeout    time.Duration
+	maxRetries int
+	backoff    *backoff.Backoff
+}
+
+func NewFromConfig() (Client, error) {
+	network := viper.GetString("FLOW_NETWORK")
+	if network == "" {
+		network = NetworkTestnet
+	}
+
+	if network == NetworkFake {
+		return NewFakeFromConfig(), nil
+	}
+
+	host := viper.GetString("FLOW_ACCESS_HOST")
+	if host == "" {
+		switch network {
+		case NetworkEmulator:
+			host = grpc.EmulatorHost
+		case NetworkTestnet:
+			host = grpc.TestnetHost
+		case NetworkMainnet:
+			host = grpc.MainnetHost
+		default:
+			return nil, fmt.Errorf("unknown flow network %s", network)
+		}
+	}
+
+	timeout := viper.GetDuration("FLOW_ACCESS_TIMEOUT")
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	maxRetries := defaultMaxRetries
+	if viper.IsSet("FLOW_ACCESS_MAX_RETRIES") {
+		maxRetries = viper.GetInt("FLOW_ACCESS_MAX_RETRIES")
+	}
+
+	return New(host, timeout, maxRetries)
+}
+
+func New(host string, timeout time.Duration, maxRetries int) (Client, error) {
+	client, err := grpc.NewClient(host)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().Msg(fmt.Sprintf("Flow access client connected to %s", host))
+
+	return &accessClient{
+		client:     client,
+		timeout:    timeout,
+		maxRetries: maxRetries,
+		backoff: &backoff.Backoff{
+			Min:    200 * time.Millisecond,
+			Max:    5 * time.Second,
+			Factor: 2,
+			Jitter: true,
+		},
+	}, nil
+}
+
+func (c *accessClient) ExecuteScript(ctx context.Context, script []byte, arguments []cadence.Value) (cadence.Value, error) {
+	var value cadence.Value
+	err := c.withRetries(ctx, func(callCtx context.Context) error {
+		var err error
+		value, err = c.client.ExecuteScriptAtLatestBlock(callCtx, script, arguments)
+		return err
+	})
+	return value, err
+}
+
+func (c *accessClient) GetLatestBlockHeight(ctx context.Context) (uint64, error) {
+	var height uint64
+	err := c.withRetries(ctx, func(callCtx context.Context) error {
+		header, err := c.client.GetLatestBlockHeader(callCtx, true)
+		if err != nil {
+			return err
+		}
+		height = header.Height
+		return nil
+	})
+	return height, err
+}
+
+func (c *accessClient) GetEventsForHeightRange(ctx context.Context, eventType string, startHeight uint64, endHeight uint64) ([]flow.BlockEvents, error) {
+	var events []flow.BlockEvents
+	err := c.withRetries(ctx, func(callCtx context.Context) error {
+		var err error
+		events, err = c.client.GetEventsForHeightRange(callCtx, eventType, startHeight, endHeight)
+		return err
+	})
+	return events, err
+}
+
+func (c *accessClient) Close() error {
+	return c.client.Close()
+}
+
+func (c *accessClient) withRetries(ctx context.Context, call func(callCtx context.Context) error) error {
+	var err error
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(c.backoff.ForAttempt(float64(attempt - 1))):
+			}
+		}
+
+		callCtx, cancel := context.WithTimeout(ctx, c.timeout)
+		err = call(callCtx)
+		cancel()
+
+		if err == nil || !isRetryable(err) {
+			return err
+		}
+		log.Warn().Err(err).Msg(fmt.Sprintf("Flow access call failed, attempt %d", attempt+1))
+	}
+	return err
+}
+
+func isRetryable(err error) bool {
+	switch grpcStatus.Code(err) {
+	case grpcCodes.Unavailable, grpcCodes.DeadlineExceeded, grpcCodes.ResourceExhausted, grpcCodes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
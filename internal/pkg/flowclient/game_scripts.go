@@ -0,0 +1,166 @@
+package flowclient
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/onflow/cadence"
+)
+
+// GameStatus mirrors the GameState enum of the BattleBlocksGame contract.
+type GameStatus uint8
+
+const (
+	GameWaitingForChallenger GameStatus = iota
+	GamePlaying
+	GameFinished
+)
+
+// GameState is the on-chain state of a game, addresses are empty until set.
+type GameState struct {
+	Status  GameStatus
+	Turn    uint64
+	PlayerA string
+	PlayerB string
+	Winner  string
+}
+
+const gameStateMarker = "BattleBlocksGame.getGameInfo"
+
+const gameStateScript = `
+	import BattleBlocksGame from 0xBATTLE_BLOCKS_GAME_ADDRESS
+
+	pub fun main(gameID: UInt64): {String: AnyStruct}? {
+		if let game = BattleBlocksGame.getGameInfo(gameID: gameID) {
+			return {
+				"gameState": game.gameState.rawValue,
+				"turn": game.turn,
+				"playerA": game.playerA,
+				"playerB": game.playerB,
+				"winner": game.winner
+			}
+		}
+		return nil
+	}
+	`
+
+// GetGameState returns the state of the game, nil when the contract does not
+// know the game.
+func GetGameState(ctx context.Context, client Client, flowGameId uint64) (*GameState, error) {
+	script := withAddresses(gameStateScript, map[string]string{
+		"0xBATTLE_BLOCKS_GAME_ADDRESS": "BATTLE_BLOCKS_GAME_ADDRESS",
+	})
+
+	value, err := client.ExecuteScript(ctx, []byte(script), []cadence.Value{cadence.NewUInt64(flowGameId)})
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeGameState(value)
+}
+
+func decodeGameState(value cadence.Value) (*GameState, error) {
+	if optional, ok := value.(cadence.Optional); ok {
+		value = optional.Value
+	}
+	if value == nil {
+		return nil, nil
+	}
+
+	dictionary, ok := value.(cadence.Dictionary)
+	if !ok {
+		return nil, fmt.Errorf("unexpected game state %s", value)
+	}
+
+	fields := map[string]cadence.Value{}
+	for _, pair := range dictionary.Pairs {
+		key, ok := pair.Key.(cadence.String)
+		if !ok {
+			return nil, fmt.Errorf("unexpected game state key %s", pair.Key)
+		}
+		fields[string(key)] = unwrapOptional(pair.Value)
+	}
+
+	status, err := uintField(fields, "gameState")
+	if err != nil {
+		return nil, err
+	}
+	turn, err := uintField(fields, "turn")
+	if err != nil {
+		return nil, err
+	}
+
+	return &GameState{
+		Status:  GameStatus(status),
+		Turn:    turn,
+		PlayerA: addressField(fields, "playerA"),
+		PlayerB: addressField(fields, "playerB"),
+		Winner:  addressField(fields, "winner"),
+	}, nil
+}
+
+// encodeGameState builds the value the game state script returns
+func encodeGameState(state *GameState) (cadence.Value, error) {
+	if state == nil {
+		return cadence.NewOptional(nil), nil
+	}
+
+	optionalAddress := func(address string) cadence.Value {
+		if address == "" {
+			return cadence.NewOptional(nil)
+		}
+		return cadence.NewOptional(AddressArgument(address))
+	}
+
+	entries := []struct {
+		key   string
+		value cadence.Value
+	}{
+		{"gameState", cadence.NewUInt8(uint8(state.Status))},
+		{"turn", cadence.NewUInt64(state.Turn)},
+		{"playerA", optionalAddress(state.PlayerA)},
+		{"playerB", optionalAddress(state.PlayerB)},
+		{"winner", optionalAddress(state.Winner)},
+	}
+
+	pairs := make([]cadence.KeyValuePair, len(entries))
+	for i, entry := range entries {
+		key, err := cadence.NewString(entry.key)
+		if err != nil {
+			return nil, err
+		}
+		pairs[i] = cadence.KeyValuePair{Key: key, Value: entry.value}
+	}
+	return cadence.NewOptional(cadence.NewDictionary(pairs)), nil
+}
+
+func unwrapOptional(value cadence.Value) cadence.Value {
+	for {
+		optional, ok := value.(cadence.Optional)
+		if !ok {
+			return value
+		}
+		value = optional.Value
+	}
+}
+
+func uintField(fields map[string]cadence.Value, name string) (uint64, error) {
+	switch v := fields[name].(type) {
+	case cadence.UInt8:
+		return uint64(v), nil
+	case cadence.UInt32:
+		return uint64(v), nil
+	case cadence.UInt64:
+		return uint64(v), nil
+	default:
+		return 0, fmt.Errorf("unexpected game state field %s: %v", name, fields[name])
+	}
+}
+
+func addressField(fields map[string]cadence.Value, name string) string {
+	address, ok := fields[name].(cadence.Address)
+	if !ok {
+		return ""
+	}
+	return address.String()
+}
@@ -0,0 +1,56 @@
+package flowclient
+
+import (
+	"context"
+	"strings"
+
+	"github.com/onflow/cadence"
+	"github.com/onflow/flow-go-sdk"
+	"github.com/spf13/viper"
+)
+
+const flowBalanceMarker = "/public/flowTokenBalance"
+
+const flowBalanceScript = `
+	import FungibleToken from 0xFUNGIBLE_TOKEN_ADDRESS
+	import FlowToken from 0xFLOW_TOKEN_ADDRESS
+
+	pub fun main(account: Address): UFix64 {
+
+	let vaultRef = getAccount(account)
+	.getCapability(/public/flowTokenBalance)
+	.borrow<&FlowToken.Vault{FungibleToken.Balance}>()
+	?? panic("Could not borrow Balance reference to the Vault")
+
+	return vaultRef.balance
+	}
+	`
+
+// GetFlowBalance returns the FLOW balance of the account as a UFix64 string.
+func GetFlowBalance(ctx context.Context, client Client, address string) (string, error) {
+	script := withAddresses(flowBalanceScript, map[string]string{
+		"0xFLOW_TOKEN_ADDRESS":     "FLOW_TOKEN_ADDRESS",
+		"0xFUNGIBLE_TOKEN_ADDRESS": "FUNGIBLE_TOKEN_ADDRESS",
+	})
+
+	args := []cadence.Value{AddressArgument(address)}
+
+	balance, err := client.ExecuteScript(ctx, []byte(script), args)
+	if err != nil {
+		return "", err
+	}
+
+	return balance.String(), nil
+}
+
+func AddressArgument(address string) cadence.Address {
+	return cadence.BytesToAddress(flow.HexToAddress(address).Bytes())
+}
+
+// replaces address placeholders with the configured contract addresses
+func withAddresses(script string, placeholders map[string]string) string {
+	for placeholder, configKey := range placeholders {
+		script = strings.ReplaceAll(script, placeholder, viper.GetString(configKey))
+	}
+	return script
+}
@@ -0,0 +1,112 @@
+package flowevent
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/onflow/cadence"
+	jsoncdc "github.com/onflow/cadence/encoding/json"
+)
+
+// Decode decodes a JSON-CDC encoded contract event into the struct of its
+// fully qualified type, e.g. *Moved for A.f8d6e0586b0a20c7.BattleBlocksGame.Moved
+func Decode(data []byte) (any, error) {
+	value, err := jsoncdc.Decode(nil, data)
+	if err != nil {
+		return nil, err
+	}
+
+	event, ok := value.(cadence.Event)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an event", value.Type().ID())
+	}
+	if event.EventType == nil {
+		return nil, fmt.Errorf("event without type")
+	}
+
+	eventType := event.EventType.ID()
+	for _, route := range Routes {
+		if route.EventType() != eventType {
+			continue
+		}
+
+		payload, err := Payload(event)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", eventType, err)
+		}
+
+		decoded := reflect.New(reflect.TypeOf(route.Payload))
+		if err := decodeFields(payload, decoded.Interface()); err != nil {
+			return nil, fmt.Errorf("%s: %w", eventType, err)
+		}
+		return decoded.Interface(), nil
+	}
+
+	return nil, fmt.Errorf("unknown event type %s", eventType)
+}
+
+// DecodeAs decodes a contract event either encoded as JSON-CDC, as read from
+// the chain, or as a JSON object of its fields, as published by the event
+// service.
+func DecodeAs[T any](data []byte) (*T, error) {
+	if !isJsonCdc(data) {
+		var event T
+		if err := decodeFields(data, &event); err != nil {
+			return nil, err
+		}
+		return &event, nil
+	}
+
+	decoded, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	event, ok := decoded.(*T)
+	if !ok {
+		return nil, fmt.Errorf("expected %T, got %T", event, decoded)
+	}
+	return event, nil
+}
+
+func isJsonCdc(data []byte) bool {
+	var value struct {
+		Type string `json:"type"`
+	}
+	return json.Unmarshal(data, &value) == nil && value.Type == "Event"
+}
+
+// decodeFields decodes a JSON object of event fields, every required field
+// of the event must be set, see events.go
+func decodeFields(data []byte, event any) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	eventType := reflect.TypeOf(event).Elem()
+	for i := 0; i < eventType.NumField(); i++ {
+		field := eventType.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" || optional(field.Type) {
+			continue
+		}
+
+		value, ok := fields[name]
+		if !ok || string(value) == "null" {
+			return fmt.Errorf("missing required field %s", name)
+		}
+	}
+
+	return json.Unmarshal(data, event)
+}
+
+func optional(fieldType reflect.Type) bool {
+	switch fieldType.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice:
+		return true
+	}
+	return false
+}
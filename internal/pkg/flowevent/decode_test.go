@@ -0,0 +1,104 @@
+package flowevent
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/money"
+	"github.com/spf13/viper"
+)
+
+func TestDecodeChallengerJoined(t *testing.T) {
+	viper.Set("BATTLE_BLOCKS_GAME_ADDRESS", "0xf8d6e0586b0a20c7")
+	defer viper.Set("BATTLE_BLOCKS_GAME_ADDRESS", nil)
+
+	wager := money.Units(150_000_000)
+	startTime := uint64(1700000000)
+	gameState := uint8(1)
+	playerA := "0x01cf0e2f2f715450"
+
+	cases := []struct {
+		file string
+		want ChallengerJoined
+	}{
+		{
+			// raw event, as read from the chain
+			file: "challenger_joined.jsoncdc.json",
+			want: ChallengerJoined{
+				GameId:            7,
+				PlayerB:           "0x179b6b1cb6755e31",
+				Turn:              1,
+				StartTime:         &startTime,
+				Wager:             &wager,
+				PlayerA:           &playerA,
+				PlayerHitCount:    map[string]uint8{"0x01cf0e2f2f715450": 0, "0x179b6b1cb6755e31": 0},
+				GameState:         &gameState,
+				PlayerAMerkleRoot: []byte{0xde, 0xad},
+				PlayerBMerkleRoot: []byte{0xbe, 0xef},
+			},
+		},
+		{
+			// flattened by the event service, without most fields
+			file: "challenger_joined.legacy.json",
+			want: ChallengerJoined{
+				GameId:    7,
+				PlayerB:   "0x179b6b1cb6755e31",
+				Turn:      1,
+				StartTime: &startTime,
+				PlayerA:   &playerA,
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", c.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			event, err := DecodeAs[ChallengerJoined](data)
+			if err != nil {
+				t.Fatalf("decoding: %v", err)
+			}
+			if !reflect.DeepEqual(*event, c.want) {
+				t.Errorf("decoded %+v, want %+v", *event, c.want)
+			}
+		})
+	}
+}
+
+func TestDecodeRequiresTheFieldsTheHandlerReads(t *testing.T) {
+	for _, field := range []string{"gameId", "playerB", "turn"} {
+		fields := map[string]string{
+			"gameId":  `"gameId": 7`,
+			"playerB": `"playerB": "0x179b6b1cb6755e31"`,
+			"turn":    `"turn": 1`,
+		}
+		fields[field] = `"` + field + `": null`
+
+		data := "{" + fields["gameId"] + "," + fields["playerB"] + "," + fields["turn"] + "}"
+		_, err := DecodeAs[ChallengerJoined]([]byte(data))
+		if err == nil || !strings.Contains(err.Error(), "missing required field "+field) {
+			t.Errorf("decoding without %s: %v", field, err)
+		}
+	}
+}
+
+func TestDecodeJsonCdcWithoutPlayerB(t *testing.T) {
+	viper.Set("BATTLE_BLOCKS_GAME_ADDRESS", "0xf8d6e0586b0a20c7")
+	defer viper.Set("BATTLE_BLOCKS_GAME_ADDRESS", nil)
+
+	data, err := os.ReadFile(filepath.Join("testdata", "challenger_joined.jsoncdc.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = []byte(strings.Replace(string(data), `{"type": "Address", "value": "0x179b6b1cb6755e31"}}`, `null}`, 1))
+
+	if _, err := DecodeAs[ChallengerJoined](data); err == nil || !strings.Contains(err.Error(), "missing required field playerB") {
+		t.Errorf("decoding without playerB: %v", err)
+	}
+}
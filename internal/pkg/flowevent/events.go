@@ -0,0 +1,68 @@
+package flowevent
+
+import (
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/money"
+)
+
+// The events of the BattleBlocks contracts. Field names follow the contract
+// fields. Fields that are pointers, maps or slices are optional, the others
+// are required, so only the fields the handlers rely on are required.
+
+type GameCreated struct {
+	GameId         uint64       `json:"gameID"`
+	CreatorId      uint64       `json:"creatorID"`
+	CreatorAddress string       `json:"creatorAddress"`
+	Stake          money.Amount `json:"wager"`
+	Payload        uint64       `json:"payload"`
+}
+
+// ChallengerJoined carries the whole game, the event service publishes the
+// fields the handler reads only
+type ChallengerJoined struct {
+	GameId            uint64           `json:"gameId"`
+	PlayerB           string           `json:"playerB"`
+	Turn              uint8            `json:"turn"`
+	StartTime         *uint64          `json:"startTime"`
+	Wager             *money.Amount    `json:"wager"`
+	PlayerA           *string          `json:"playerA"`
+	Winner            *string          `json:"winner"`
+	PlayerHitCount    map[string]uint8 `json:"playerHitCount"`
+	GameState         *uint8           `json:"gameState"`
+	PlayerAMerkleRoot []byte           `json:"playerAMerkleRoot"`
+	PlayerBMerkleRoot []byte           `json:"playerBMerkleRoot"`
+}
+
+// Moved carries the turn of the game after the move.
+type Moved struct {
+	GameId        uint64 `json:"gameID"`
+	PlayerId      uint64 `json:"gamePlayerID"`
+	PlayerAddress string `json:"playerAddress"`
+	Turn          uint64 `json:"turn"`
+	X             uint   `json:"coordinateX"`
+	Y             uint   `json:"coordinateY"`
+}
+
+type GameOver struct {
+	GameId         uint64          `json:"gameID"`
+	PlayerA        string          `json:"playerA"`
+	PlayerB        string          `json:"playerB"`
+	Winner         string          `json:"winner"`
+	PlayerHitCount map[string]uint `json:"playerHitCount"`
+}
+
+type Minted struct {
+	To   string `json:"to"`
+	Name string `json:"name"`
+	Id   uint64 `json:"id"`
+}
+
+type AccountCreated struct {
+	PublicKey string `json:"originatingPublicKey"`
+	Address   string `json:"address"`
+}
+
+type AccountDelegated struct {
+	PublicKey           string `json:"originatingPublicKey"`
+	CustodialAddress    string `json:"address"`
+	NonCustodialAddress string `json:"parent"`
+}
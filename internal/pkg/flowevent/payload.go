@@ -0,0 +1,111 @@
+package flowevent
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/blockchain"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/inbox"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/pubsub"
+	"github.com/onflow/cadence"
+	jsoncdc "github.com/onflow/cadence/encoding/json"
+	"github.com/onflow/flow-go-sdk"
+)
+
+// Message converts a Flow event into a message carrying its JSON-CDC payload.
+// The attributes identify the event, so a message that was already consumed
+// from the subscription is recognized as a duplicate.
+func Message(event flow.Event) (*pubsub.Message, error) {
+	payload, err := jsoncdc.Encode(event.Value)
+	if err != nil {
+		return nil, fmt.Errorf("event %d of transaction %s: %w", event.EventIndex, event.TransactionID, err)
+	}
+
+	attributes := map[string]string{
+		blockchain.TransactionIdAttribute: event.TransactionID.String(),
+		inbox.EventIndexAttribute:         strconv.Itoa(event.EventIndex),
+	}
+	id := fmt.Sprintf("backfill/%s/%d", event.TransactionID, event.EventIndex)
+
+	return pubsub.NewMessage(id, payload, attributes, nil, nil), nil
+}
+
+// Payload encodes the fields of the event as a JSON object keyed by field
+// name, the shape the bridges decode.
+func Payload(event cadence.Event) ([]byte, error) {
+	if event.EventType == nil {
+		return nil, fmt.Errorf("event without type")
+	}
+	if len(event.EventType.Fields) != len(event.Fields) {
+		return nil, fmt.Errorf("event %s has %d fields, its type %d", event.EventType.QualifiedIdentifier, len(event.Fields), len(event.EventType.Fields))
+	}
+
+	fields := make(map[string]any, len(event.Fields))
+	for i, field := range event.EventType.Fields {
+		value, err := plain(event.Fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Identifier, err)
+		}
+		fields[field.Identifier] = value
+	}
+
+	return json.Marshal(fields)
+}
+
+// plain converts a Cadence value to a value encoding/json encodes the way
+// the event service does. Fixed point numbers are kept as decimal strings.
+func plain(value cadence.Value) (any, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case cadence.Optional:
+		return plain(v.Value)
+	case cadence.Bool:
+		return bool(v), nil
+	case cadence.String:
+		return string(v), nil
+	case cadence.Address:
+		return v.String(), nil
+	case cadence.UInt8:
+		return uint8(v), nil
+	case cadence.UInt16:
+		return uint16(v), nil
+	case cadence.UInt32:
+		return uint32(v), nil
+	case cadence.UInt64:
+		return uint64(v), nil
+	case cadence.Int:
+		return json.Number(v.String()), nil
+	case cadence.UInt:
+		return json.Number(v.String()), nil
+	case cadence.UFix64, cadence.Fix64:
+		return v.String(), nil
+	case cadence.Array:
+		values := make([]any, len(v.Values))
+		for i, element := range v.Values {
+			converted, err := plain(element)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = converted
+		}
+		return values, nil
+	case cadence.Dictionary:
+		entries := make(map[string]any, len(v.Pairs))
+		for _, pair := range v.Pairs {
+			key, err := plain(pair.Key)
+			if err != nil {
+				return nil, err
+			}
+			converted, err := plain(pair.Value)
+			if err != nil {
+				return nil, err
+			}
+			entries[fmt.Sprint(key)] = converted
+		}
+		return entries, nil
+	default:
+		return nil, fmt.Errorf("unsupported value %s of type %T", value, value)
+	}
+}
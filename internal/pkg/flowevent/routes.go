@@ -0,0 +1,60 @@
+package flowevent
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Route ties a BattleBlocks contract event to the Go struct it decodes to and
+// the consumer (subscription id) that handles it.
+type Route struct {
+	// AddressKey is the config key of the address of the contract
+	AddressKey string
+	Contract   string
+	Event      string
+	Consumer   string
+	// Payload is the zero value of the struct the event decodes to
+	Payload any
+}
+
+var Routes = []Route{
+	{"BATTLE_BLOCKS_GAME_ADDRESS", "BattleBlocksGame", "GameCreated", "blockchain.flow.events.game-created-sub", GameCreated{}},
+	{"BATTLE_BLOCKS_GAME_ADDRESS", "BattleBlocksGame", "ChallengerJoined", "blockchain.flow.events.challenger-joined-sub", ChallengerJoined{}},
+	{"BATTLE_BLOCKS_GAME_ADDRESS", "BattleBlocksGame", "Moved", "blockchain.flow.events.move-done-sub", Moved{}},
+	{"BATTLE_BLOCKS_GAME_ADDRESS", "BattleBlocksGame", "GameOver", "blockchain.flow.events.game-over-sub", GameOver{}},
+	{"BATTLE_BLOCKS_NFT_ADDRESS", "BattleBlocksNFT", "Minted", "blockchain.flow.events.minted", Minted{}},
+	{"BATTLE_BLOCKS_ACCOUNTS_ADDRESS", "BattleBlocksAccounts", "AccountCreated", "blockchain.flow.events.account-created-sub", AccountCreated{}},
+	{"BATTLE_BLOCKS_ACCOUNTS_ADDRESS", "BattleBlocksAccounts", "AccountDelegated", "blockchain.flow.events.account-delegated-sub", AccountDelegated{}},
+}
+
+// EventType is the fully qualified event type, e.g.
+// A.f8d6e0586b0a20c7.BattleBlocksGame.Moved
+func (r Route) EventType() string {
+	address := strings.TrimPrefix(viper.GetString(r.AddressKey), "0x")
+	return fmt.Sprintf("A.%s.%s.%s", address, r.Contract, r.Event)
+}
+
+// RoutesFor returns the routes of the given event names, every route when
+// no name is given.
+func RoutesFor(events []string) ([]Route, error) {
+	if len(events) == 0 {
+		return Routes, nil
+	}
+
+	var routes []Route
+	for _, event := range events {
+		found := false
+		for _, route := range Routes {
+			if route.Event == event {
+				routes = append(routes, route)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown event %s", event)
+		}
+	}
+	return routes, nil
+}
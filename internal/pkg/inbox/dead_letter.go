@@ -0,0 +1,82 @@
+package inbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/model"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/pubsub"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotReplayable = errors.New("dead letter is not replayable")
+	ErrNoHandler     = errors.New("no handler registered for consumer")
+)
+
+func deadLetter(db *gorm.DB, consumer string, message *pubsub.Message, cause error, permanent bool, attempts int) error {
+	attributes, err := json.Marshal(message.Attributes)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().UnixMilli()
+	return db.Create(&model.DeadLetter{
+		Consumer:   consumer,
+		MessageId:  message.ID,
+		EventKey:   EventKey(message),
+		Payload:    message.Data,
+		Attributes: string(attributes),
+		Error:      cause.Error(),
+		Permanent:  permanent,
+		Attempts:   attempts,
+		Status:     model.DeadLetterPending,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}).Error
+}
+
+// Replay runs the handler of the consumer again with the dead-lettered event.
+// A failed replay keeps the event dead-lettered with the new error.
+func Replay(ctx context.Context, db *gorm.DB, deadLetter model.DeadLetter) error {
+	if deadLetter.Status != model.DeadLetterPending {
+		return ErrNotReplayable
+	}
+
+	handler, ok := handlerOf(deadLetter.Consumer)
+	if !ok {
+		return fmt.Errorf("%w %s", ErrNoHandler, deadLetter.Consumer)
+	}
+
+	attributes := map[string]string{}
+	if err := json.Unmarshal([]byte(deadLetter.Attributes), &attributes); err != nil {
+		return err
+	}
+	message := pubsub.NewMessage(deadLetter.MessageId, deadLetter.Payload, attributes, nil, nil)
+
+	replayErr := process(ctx, db, deadLetter.Consumer, handler, message)
+
+	updates := map[string]any{
+		"attempts":   deadLetter.Attempts + 1,
+		"updated_at": time.Now().UTC().UnixMilli(),
+	}
+	if replayErr != nil {
+		updates["error"] = replayErr.Error()
+		updates["permanent"] = IsPermanent(replayErr)
+	} else {
+		updates["status"] = model.DeadLetterReplayed
+	}
+
+	result := db.
+		Model(&model.DeadLetter{}).
+		Where("id = ?", deadLetter.Id).
+		Updates(updates)
+
+	if replayErr != nil {
+		return replayErr
+	}
+	return result.Error
+}
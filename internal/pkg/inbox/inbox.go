@@ -0,0 +1,202 @@
+package inbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/blockchain"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/model"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/pubsub"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EventIndexAttribute is set by the event service next to the transaction id,
+// together they identify a Flow event independently of the Pub/Sub message.
+const EventIndexAttribute = "eventIndex"
+
+// Event is a message being consumed. Side effects go through Tx, anything
+// that must only happen once the event is committed (websocket notifications)
+// is registered with AfterCommit.
+type Event struct {
+	Message     *pubsub.Message
+	Tx          *gorm.DB
+	afterCommit []func()
+}
+
+type Handler func(ctx context.Context, event *Event) error
+
+// ErrDryRunOutsideTransaction is returned for a dry run the caller could
+// not roll back
+var ErrDryRunOutsideTransaction = errors.New("dry run outside of a transaction")
+
+// handlers by consumer, dead-lettered events are replayed with them
+var (
+	handlersMu sync.RWMutex
+	handlers   = map[string]Handler{}
+)
+
+func (e *Event) AfterCommit(f func()) {
+	e.afterCommit = append(e.afterCommit, f)
+}
+
+// Subscribe registers the handler for the subscription and consumes it in the
+// background with the idempotent handler, see Wrap. The broker redelivers
+// failed events with the retry backoff of the subscription.
+func Subscribe(broker pubsub.Broker, db *gorm.DB, subscriptionId string, handler Handler) {
+	wrapped := Wrap(db, subscriptionId, handler)
+
+	if configurer, ok := broker.(pubsub.RetryConfigurer); ok {
+		err := configurer.SetRetryBackoff(context.Background(), subscriptionId, minRetryBackoff, maxRetryBackoff)
+		if err != nil {
+			log.Warn().Err(err).Msg(fmt.Sprintf("Cannot set the retry backoff of %s, failed events are redelivered with its retry policy", subscriptionId))
+		}
+	}
+
+	go func() {
+		err := broker.Subscribe(context.Background(), subscriptionId, wrapped)
+		if err != nil {
+			log.Error().Err(err).Msg(fmt.Sprintf("Subscriber error for sub id %s", subscriptionId))
+		}
+	}()
+}
+
+// Deliver runs the handler registered for the consumer once with the
+// message, outside of its subscription and without retries. It reports
+// whether the event had already been processed.
+//
+// A dry run skips the after commit callbacks and has to run in a transaction
+// the caller rolls back. The handler runs in a savepoint of it, so the events
+// delivered next see its changes and a failing one leaves no trace.
+func Deliver(ctx context.Context, db *gorm.DB, consumer string, message *pubsub.Message, dryRun bool) (bool, error) {
+	handler, ok := handlerOf(consumer)
+	if !ok {
+		return false, fmt.Errorf("%w %s", ErrNoHandler, consumer)
+	}
+	if _, inTransaction := db.Statement.ConnPool.(gorm.TxCommitter); dryRun && !inTransaction {
+		return false, ErrDryRunOutsideTransaction
+	}
+
+	return run(ctx, db, consumer, handler, message, dryRun)
+}
+
+// Wrap makes a handler idempotent. The event is recorded in the
+// processed_event table in the same transaction as the side effects of the
+// handler, redelivered events are acknowledged without running it again.
+//
+// Failed events are nacked right away, the subscription delays their
+// redelivery. Permanent failures and events that keep failing are moved to
+// the dead_letter table and acked, deferred events are retried for much
+// longer first.
+func Wrap(db *gorm.DB, consumer string, handler Handler) pubsub.MessageHandler {
+	handlersMu.Lock()
+	handlers[consumer] = handler
+	handlersMu.Unlock()
+
+	retries := newRetryPolicy()
+
+	return func(ctx context.Context, message *pubsub.Message) {
+		log.Info().Msg("Received message payload " + string(message.Data))
+
+		err := process(ctx, db, consumer, handler, message)
+		if err == nil {
+			retries.forget(message)
+			message.Ack()
+			return
+		}
+
+		attempt := retries.attempt(message)
+		permanent := IsPermanent(err)
+		log.Warn().Err(err).Msg(fmt.Sprintf("Error while handling message %s from %s, attempt %d", message.ID, consumer, attempt))
+
+		if permanent || retries.exhausted(err, attempt) {
+			deadLetterErr := deadLetter(db, consumer, message, err, permanent, attempt)
+			if deadLetterErr != nil {
+				log.Error().Err(deadLetterErr).Msg(fmt.Sprintf("Cannot dead-letter message %s from %s", message.ID, consumer))
+				message.Nack()
+				return
+			}
+
+			retries.forget(message)
+			message.Ack()
+			return
+		}
+
+		message.Nack()
+	}
+}
+
+// EventKey identifies the event by its Flow transaction and event index when
+// the event service provides them, by the Pub/Sub message id otherwise.
+func EventKey(message *pubsub.Message) string {
+	transactionId := message.Attributes[blockchain.TransactionIdAttribute]
+	eventIndex, ok := message.Attributes[EventIndexAttribute]
+	if transactionId != "" && ok {
+		return fmt.Sprintf("flow/%s/%s", transactionId, eventIndex)
+	}
+
+	return fmt.Sprintf("message/%s", message.ID)
+}
+
+func process(ctx context.Context, db *gorm.DB, consumer string, handler Handler, message *pubsub.Message) error {
+	_, err := run(ctx, db, consumer, handler, message, false)
+	return err
+}
+
+// runs the handler once per event key and the after commit callbacks of the
+// event once it is committed
+func run(ctx context.Context, db *gorm.DB, consumer string, handler Handler, message *pubsub.Message, dryRun bool) (bool, error) {
+	event := &Event{Message: message}
+	duplicate := false
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.
+			Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&model.ProcessedEvent{
+				Consumer:    consumer,
+				EventKey:    EventKey(message),
+				MessageId:   message.ID,
+				ProcessedAt: time.Now().UTC().UnixMilli(),
+			})
+
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			duplicate = true
+			return nil
+		}
+
+		event.Tx = tx
+		return handler(ctx, event)
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	if duplicate {
+		log.Info().Msg(fmt.Sprintf("Skipped already processed message %s from %s", message.ID, consumer))
+		return true, nil
+	}
+	if dryRun {
+		return false, nil
+	}
+
+	for _, f := range event.afterCommit {
+		f()
+	}
+	return false, nil
+}
+
+func handlerOf(consumer string) (Handler, bool) {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+
+	handler, ok := handlers[consumer]
+	return handler, ok
+}
@@ -0,0 +1,139 @@
+package inbox
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/pubsub"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultMaxAttempts         = 5
+	defaultMaxDeferredAttempts = 200
+	maxTrackedMessages         = 10_000
+
+	// the retry backoff set on the subscriptions
+	minRetryBackoff = time.Second
+	maxRetryBackoff = 30 * time.Second
+)
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks an error retrying cannot fix, like a payload that cannot
+// be decoded. The event is dead-lettered right away.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// IsPermanent reports whether the error was marked Permanent, every other
+// error is retried.
+func IsPermanent(err error) bool {
+	var permanent permanentError
+	return errors.As(err, &permanent)
+}
+
+type deferredError struct {
+	err error
+}
+
+func (e deferredError) Error() string {
+	return e.err.Error()
+}
+
+func (e deferredError) Unwrap() error {
+	return e.err
+}
+
+// Deferred marks an event that waits for another one to be applied first,
+// like a move whose previous move is still in flight. It is retried with the
+// much larger budget of deferred events before it is dead-lettered.
+func Deferred(err error) error {
+	if err == nil {
+		return nil
+	}
+	return deferredError{err: err}
+}
+
+func IsDeferred(err error) bool {
+	var deferred deferredError
+	return errors.As(err, &deferred)
+}
+
+// retryPolicy counts delivery attempts of failing messages. Pub/Sub only
+// reports the delivery attempt for subscriptions with a dead letter policy,
+// otherwise the attempts are counted by this instance.
+type retryPolicy struct {
+	maxAttempts         int
+	maxDeferredAttempts int
+
+	mu       sync.Mutex
+	attempts map[string]int
+}
+
+// newRetryPolicy reads EVENT_MAX_ATTEMPTS, how often a failing event is
+// tried, and EVENT_MAX_DEFERRED_ATTEMPTS, how often a deferred one is. With
+// the maximum retry backoff, the default lets a deferred event wait well
+// over an hour for its predecessor.
+func newRetryPolicy() *retryPolicy {
+	maxAttempts := viper.GetInt("EVENT_MAX_ATTEMPTS")
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+
+	maxDeferredAttempts := viper.GetInt("EVENT_MAX_DEFERRED_ATTEMPTS")
+	if maxDeferredAttempts <= 0 {
+		maxDeferredAttempts = defaultMaxDeferredAttempts
+	}
+
+	return &retryPolicy{
+		maxAttempts:         maxAttempts,
+		maxDeferredAttempts: maxDeferredAttempts,
+		attempts:            map[string]int{},
+	}
+}
+
+func (p *retryPolicy) attempt(message *pubsub.Message) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.attempts) >= maxTrackedMessages {
+		p.attempts = map[string]int{}
+	}
+	p.attempts[message.ID]++
+	attempt := p.attempts[message.ID]
+
+	if message.DeliveryAttempt != nil && *message.DeliveryAttempt > attempt {
+		attempt = *message.DeliveryAttempt
+	}
+	return attempt
+}
+
+// exhausted reports whether the event failed with err too often to retry
+func (p *retryPolicy) exhausted(err error, attempt int) bool {
+	if IsDeferred(err) {
+		return attempt >= p.maxDeferredAttempts
+	}
+	return attempt >= p.maxAttempts
+}
+
+func (p *retryPolicy) forget(message *pubsub.Message) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.attempts, message.ID)
+}
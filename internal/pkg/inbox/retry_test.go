@@ -0,0 +1,24 @@
+package inbox
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestDeferredEventsGetTheLargerBudget(t *testing.T) {
+	policy := &retryPolicy{maxAttempts: 5, maxDeferredAttempts: 200}
+
+	failed := errors.New("database is down")
+	deferred := Deferred(fmt.Errorf("handling move: %w", errors.New("move out of order")))
+
+	if policy.exhausted(failed, 4) || !policy.exhausted(failed, 5) {
+		t.Error("failing events are dead-lettered after 5 attempts")
+	}
+	if policy.exhausted(deferred, 5) || policy.exhausted(deferred, 199) || !policy.exhausted(deferred, 200) {
+		t.Error("deferred events are dead-lettered after 200 attempts")
+	}
+	if !IsDeferred(fmt.Errorf("wrapped: %w", deferred)) || IsDeferred(failed) || IsPermanent(deferred) {
+		t.Error("deferred errors are told apart when wrapped")
+	}
+}
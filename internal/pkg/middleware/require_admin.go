@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/reject"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/utils"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const adminRequired string = "error.admin.required"
+
+// RequireAdmin lets through users whose email is listed in the comma
+// separated ADMIN_EMAILS. It has to run after VerifyAuthToken.
+func RequireAdmin(context *gin.Context) {
+	if IsAdmin(utils.GetUserEmail(context)) {
+		return
+	}
+
+	log.Warn().Msg("Admin access denied: 403")
+	context.AbortWithStatusJSON(
+		http.StatusForbidden,
+		reject.NewProblem().
+			WithTitle("Admin access required").
+			WithStatus(http.StatusForbidden).
+			WithCode(adminRequired).
+			Build())
+}
+
+func IsAdmin(email string) bool {
+	for _, admin := range strings.Split(viper.GetString("ADMIN_EMAILS"), ",") {
+		if email != "" && strings.EqualFold(strings.TrimSpace(admin), email) {
+			return true
+		}
+	}
+	return false
+}
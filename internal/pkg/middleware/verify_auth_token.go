@@ -17,35 +17,44 @@ const (
 )
 
 func VerifyAuthToken(context *gin.Context) {
-	authHeader := context.Request.Header.Get("Authorization")
-	idTokenValue := strings.TrimSpace(strings.ReplaceAll(authHeader, "Bearer", ""))
+	accessToken, problem := VerifyToken(context.Request.Header.Get("Authorization"))
+	if problem != nil {
+		context.AbortWithStatusJSON(problem.Problem.Status, problem.Problem)
+		return
+	}
+	utils.SetAccessTokenCtx(accessToken, context)
+}
+
+// VerifyToken verifies a Firebase id token, with or without the Bearer
+// prefix. Connections that cannot send the Authorization header are
+// verified with it directly.
+func VerifyToken(rawToken string) (*utils.AccessToken, *reject.ProblemWithTrace) {
+	idTokenValue := strings.TrimSpace(strings.ReplaceAll(rawToken, "Bearer", ""))
 	if idTokenValue == "" {
 		log.Warn().Msg("Token missing: 401")
-		context.AbortWithStatusJSON(
-			http.StatusUnauthorized,
-			reject.NewProblem().
+		return nil, &reject.ProblemWithTrace{
+			Problem: reject.NewProblem().
 				WithTitle("Missing access token").
 				WithStatus(http.StatusUnauthorized).
 				WithCode(accessTokenRequired).
-				Build())
-		return
+				Build(),
+		}
 	}
 	token, err := firebase.VerifyIdToken(idTokenValue)
 	if err != nil {
 		log.Warn().Msg(fmt.Sprintf("Error verifying token: %s", err.Error()))
-		context.AbortWithStatusJSON(
-			http.StatusUnauthorized,
-			reject.NewProblem().
+		return nil, &reject.ProblemWithTrace{
+			Problem: reject.NewProblem().
 				WithTitle("Cannot verify access token").
 				WithStatus(http.StatusUnauthorized).
 				WithCode(accessTokenInvalid).
 				WithDetail(err.Error()).
-				Build())
-		return
+				Build(),
+			Cause: err,
+		}
 	}
-	accessTokenDetails := utils.AccessToken{
+	return &utils.AccessToken{
 		Token:    *token,
 		RawToken: idTokenValue,
-	}
-	utils.SetAccessTokenCtx(&accessTokenDetails, context)
+	}, nil
 }
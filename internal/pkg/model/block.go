@@ -1,13 +1,15 @@
 package model
 
+import "github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/money"
+
 type Block struct {
-	Id        uint64 `json:"id"`
-	Name      string `json:"name"`
-	BlockType string `json:"blockType"`
-	Rarity    string `json:"rarity"`
-	Pattern   string `json:"pattern"`
-	Price     uint32 `json:"price"`
-	ColorHex  string `json:"colorHex"`
+	Id        uint64       `json:"id"`
+	Name      string       `json:"name"`
+	BlockType string       `json:"blockType"`
+	Rarity    string       `json:"rarity"`
+	Pattern   string       `json:"pattern"`
+	Price     money.Amount `json:"price"`
+	ColorHex  string       `json:"colorHex"`
 }
 
 func (Block) TableName() string {
@@ -0,0 +1,27 @@
+package model
+
+type CommandStatus string
+
+const (
+	CommandPending   CommandStatus = "PENDING"
+	CommandSubmitted CommandStatus = "SUBMITTED"
+	CommandSealed    CommandStatus = "SEALED"
+	CommandFailed    CommandStatus = "FAILED"
+)
+
+type BlockchainCommand struct {
+	Id                string        `gorm:"primaryKey" json:"id"`
+	Type              string        `json:"type"`
+	Reference         *string       `json:"reference"`
+	Payload           string        `json:"-"`
+	Authorizers       string        `json:"-"`
+	Status            CommandStatus `json:"status"`
+	FlowTransactionId *string       `json:"flowTransactionId"`
+	Error             *string       `json:"error"`
+	CreatedAt         int64         `gorm:"autoCreateTime:false" json:"createdAt"`
+	UpdatedAt         int64         `gorm:"autoUpdateTime:false" json:"updatedAt"`
+}
+
+func (BlockchainCommand) TableName() string {
+	return "blockchain_command"
+}
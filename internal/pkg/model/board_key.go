@@ -0,0 +1,12 @@
+package model
+
+type BoardKey struct {
+	GameId     uint64 `gorm:"primaryKey"`
+	UserId     uint64 `gorm:"primaryKey"`
+	KeyId      string
+	WrappedKey []byte
+}
+
+func (BoardKey) TableName() string {
+	return "board_key"
+}
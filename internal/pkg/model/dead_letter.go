@@ -0,0 +1,27 @@
+package model
+
+type DeadLetterStatus string
+
+const (
+	DeadLetterPending  DeadLetterStatus = "DEAD"
+	DeadLetterReplayed DeadLetterStatus = "REPLAYED"
+)
+
+type DeadLetter struct {
+	Id         uint64           `gorm:"primaryKey" json:"id"`
+	Consumer   string           `json:"consumer"`
+	MessageId  string           `json:"messageId"`
+	EventKey   string           `json:"eventKey"`
+	Payload    []byte           `json:"-"`
+	Attributes string           `json:"-"`
+	Error      string           `json:"error"`
+	Permanent  bool             `json:"permanent"`
+	Attempts   int              `json:"attempts"`
+	Status     DeadLetterStatus `json:"status"`
+	CreatedAt  int64            `gorm:"autoCreateTime:false" json:"createdAt"`
+	UpdatedAt  int64            `gorm:"autoUpdateTime:false" json:"updatedAt"`
+}
+
+func (DeadLetter) TableName() string {
+	return "dead_letter"
+}
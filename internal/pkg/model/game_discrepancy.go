@@ -0,0 +1,37 @@
+package model
+
+type DiscrepancyKind string
+
+const (
+	// the GAME_CREATE transaction failed, the game is cancelled
+	DiscrepancyCreationFailed DiscrepancyKind = "CREATION_FAILED"
+	// the GAME_CREATE transaction did not finish in time
+	DiscrepancyCreationPending DiscrepancyKind = "CREATION_PENDING"
+	// the GAME_CREATE transaction was sealed but the game has no flow id
+	DiscrepancyCreatedEventMissing DiscrepancyKind = "CREATED_EVENT_MISSING"
+	DiscrepancyMissingOnChain      DiscrepancyKind = "MISSING_ON_CHAIN"
+	DiscrepancyStatusMismatch      DiscrepancyKind = "STATUS_MISMATCH"
+	DiscrepancyJoinMissing         DiscrepancyKind = "CHALLENGER_JOINED_MISSING"
+	DiscrepancyTurnBehind          DiscrepancyKind = "TURN_BEHIND"
+	DiscrepancyTurnAhead           DiscrepancyKind = "TURN_AHEAD"
+	DiscrepancyGameOverMissing     DiscrepancyKind = "GAME_OVER_MISSING"
+)
+
+// GameDiscrepancy is a difference between a game and its on-chain state
+// found by the reconciler. Repaired discrepancies are resolved when found,
+// the others stay open until a run no longer finds them or an admin resolves
+// them.
+type GameDiscrepancy struct {
+	Id          uint64          `gorm:"primaryKey" json:"id"`
+	GameId      uint64          `json:"gameId"`
+	Kind        DiscrepancyKind `json:"kind"`
+	Detail      string          `json:"detail"`
+	Repaired    bool            `json:"repaired"`
+	FirstSeenAt int64           `json:"firstSeenAt"`
+	LastSeenAt  int64           `json:"lastSeenAt"`
+	ResolvedAt  *int64          `json:"resolvedAt"`
+}
+
+func (GameDiscrepancy) TableName() string {
+	return "game_discrepancy"
+}
@@ -0,0 +1,26 @@
+package model
+
+type GameEventType string
+
+const (
+	GameEventCreated          GameEventType = "CREATED"
+	GameEventChallengerJoined GameEventType = "CHALLENGER_JOINED"
+	GameEventMoved            GameEventType = "MOVED"
+	GameEventOver             GameEventType = "GAME_OVER"
+)
+
+// GameEvent records a game contract event applied to a game. Turn is the
+// on-chain turn of the game once the event is applied.
+type GameEvent struct {
+	Id            uint64        `gorm:"primaryKey" json:"id"`
+	GameId        uint64        `json:"gameId"`
+	EventType     GameEventType `json:"eventType"`
+	Turn          uint64        `json:"turn"`
+	TransactionId *string       `json:"transactionId"`
+	EventKey      string        `json:"eventKey"`
+	AppliedAt     int64         `json:"appliedAt"`
+}
+
+func (GameEvent) TableName() string {
+	return "game_event"
+}
@@ -3,8 +3,13 @@ package model
 type GameGridPoint struct {
 	GameId       uint64 `gorm:"primaryKey"`
 	UserId       uint64 `gorm:"primaryKey"`
-	BlockPresent bool
 	CoordinateX  uint64 `gorm:"primaryKey"`
 	CoordinateY  uint64 `gorm:"primaryKey"`
-	Nonce        string
+	Secret       []byte
+	BlockPresent bool   `gorm:"-"`
+	Nonce        string `gorm:"-"`
+}
+
+func (GameGridPoint) TableName() string {
+	return "game_grid_point"
 }
@@ -7,4 +7,5 @@ const (
 	GamePreparing GameStatus = "PREPARING"
 	GamePlaying   GameStatus = "PLAYING"
 	GameFinished  GameStatus = "FINISHED"
+	GameCancelled GameStatus = "CANCELLED"
 )
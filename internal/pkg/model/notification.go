@@ -0,0 +1,28 @@
+package model
+
+type NotificationType string
+
+const (
+	NotificationGameJoined     NotificationType = "GAME_JOINED"
+	NotificationOpponentMoved  NotificationType = "OPPONENT_MOVED"
+	NotificationGameWon        NotificationType = "GAME_WON"
+	NotificationGameLost       NotificationType = "GAME_LOST"
+	NotificationNftMinted      NotificationType = "NFT_MINTED"
+	NotificationAccountCreated NotificationType = "ACCOUNT_CREATED"
+)
+
+// Notification tells a user what happened while they may have been offline.
+// Reference is the entity it is about, e.g. "game/12".
+type Notification struct {
+	Id        uint64           `gorm:"primaryKey" json:"id"`
+	UserId    uint64           `json:"userId"`
+	Type      NotificationType `json:"type"`
+	Reference string           `json:"reference"`
+	Payload   string           `json:"-"`
+	ReadAt    *int64           `json:"readAt"`
+	CreatedAt int64            `gorm:"autoCreateTime:false" json:"createdAt"`
+}
+
+func (Notification) TableName() string {
+	return "notification"
+}
@@ -0,0 +1,17 @@
+package model
+
+type OutboxMessage struct {
+	Id            uint64  `gorm:"primaryKey" json:"id"`
+	Topic         string  `json:"topic"`
+	OrderingKey   *string `json:"orderingKey"`
+	Payload       []byte  `json:"payload"`
+	Attempts      int     `json:"attempts"`
+	LastError     *string `json:"lastError"`
+	CreatedAt     int64   `gorm:"autoCreateTime:false" json:"createdAt"`
+	NextAttemptAt int64   `json:"nextAttemptAt"`
+	SentAt        *int64  `json:"sentAt"`
+}
+
+func (OutboxMessage) TableName() string {
+	return "outbox_message"
+}
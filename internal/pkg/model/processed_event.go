@@ -0,0 +1,12 @@
+package model
+
+type ProcessedEvent struct {
+	Consumer    string `gorm:"primaryKey" json:"consumer"`
+	EventKey    string `gorm:"primaryKey" json:"eventKey"`
+	MessageId   string `json:"messageId"`
+	ProcessedAt int64  `json:"processedAt"`
+}
+
+func (ProcessedEvent) TableName() string {
+	return "processed_event"
+}
@@ -0,0 +1,24 @@
+package model
+
+import "github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/money"
+
+type LedgerEntryType string
+
+const (
+	LedgerReserve LedgerEntryType = "RESERVE"
+	LedgerRelease LedgerEntryType = "RELEASE"
+	LedgerSettle  LedgerEntryType = "SETTLE"
+)
+
+type StakeLedgerEntry struct {
+	Id        uint64          `gorm:"primaryKey" json:"id"`
+	UserId    uint64          `json:"userId"`
+	GameId    uint64          `json:"gameId"`
+	EntryType LedgerEntryType `json:"entryType"`
+	Amount    money.Amount    `json:"amount"`
+	CreatedAt int64           `gorm:"autoCreateTime:false" json:"createdAt"`
+}
+
+func (StakeLedgerEntry) TableName() string {
+	return "stake_ledger_entry"
+}
@@ -0,0 +1,185 @@
+package money
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/onflow/cadence"
+)
+
+const (
+	Decimals = 8
+
+	scale = 100_000_000
+)
+
+var (
+	ErrInvalidAmount = errors.New("invalid amount")
+	ErrOverflow      = errors.New("amount out of range")
+)
+
+// Amount is a non-negative FLOW amount with 8 fractional digits, stored as
+// the number of 10^-8 units. This is the same representation Cadence uses for
+// UFix64, so amounts convert losslessly in both directions.
+type Amount uint64
+
+func Units(units uint64) Amount {
+	return Amount(units)
+}
+
+func FromInt(whole uint64) (Amount, error) {
+	if whole > math.MaxUint64/scale {
+		return 0, ErrOverflow
+	}
+	return Amount(whole * scale), nil
+}
+
+// Parse reads a decimal like "12", "0.5" or "1.00000000". More than 8
+// fractional digits, signs and exponents are rejected.
+func Parse(value string) (Amount, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, ErrInvalidAmount
+	}
+
+	whole, fraction, _ := strings.Cut(value, ".")
+	if whole == "" || len(fraction) > Decimals || !digitsOnly(whole) || !digitsOnly(fraction) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, value)
+	}
+
+	wholeUnits, err := strconv.ParseUint(whole, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrOverflow, value)
+	}
+
+	fraction += strings.Repeat("0", Decimals-len(fraction))
+	fractionUnits, _ := strconv.ParseUint(fraction, 10, 64)
+
+	amount, err := FromInt(wholeUnits)
+	if err != nil {
+		return 0, err
+	}
+
+	return amount.Add(Amount(fractionUnits))
+}
+
+func MustParse(value string) Amount {
+	amount, err := Parse(value)
+	if err != nil {
+		panic(err)
+	}
+	return amount
+}
+
+func FromUFix64(value cadence.UFix64) Amount {
+	return Amount(value)
+}
+
+func (a Amount) UFix64() cadence.UFix64 {
+	return cadence.UFix64(a)
+}
+
+func (a Amount) Units() uint64 {
+	return uint64(a)
+}
+
+func (a Amount) IsZero() bool {
+	return a == 0
+}
+
+func (a Amount) Add(b Amount) (Amount, error) {
+	if a > math.MaxUint64-b {
+		return 0, ErrOverflow
+	}
+	return a + b, nil
+}
+
+// Sub subtracts b from a, going no lower than zero.
+func (a Amount) Sub(b Amount) Amount {
+	if b > a {
+		return 0
+	}
+	return a - b
+}
+
+// String formats the amount with all 8 fractional digits, the format Cadence
+// expects for UFix64 arguments.
+func (a Amount) String() string {
+	return fmt.Sprintf("%d.%08d", uint64(a)/scale, uint64(a)%scale)
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	// a JSON number keeps the API compatible with clients sending plain numbers
+	trimmed := strings.TrimRight(a.String(), "0")
+	return []byte(strings.TrimSuffix(trimmed, ".")), nil
+}
+
+// UnmarshalJSON accepts both JSON numbers and strings.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidAmount, data)
+	}
+
+	amount, err := Parse(number.String())
+	if err != nil {
+		return err
+	}
+
+	*a = amount
+	return nil
+}
+
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// Scan reads NUMERIC columns. Integers are read as whole FLOW.
+func (a *Amount) Scan(value any) error {
+	var amount Amount
+	var err error
+
+	switch v := value.(type) {
+	case nil:
+		amount = 0
+	case string:
+		amount, err = Parse(v)
+	case []byte:
+		amount, err = Parse(string(v))
+	case int64:
+		if v < 0 {
+			return fmt.Errorf("%w: %d", ErrInvalidAmount, v)
+		}
+		amount, err = FromInt(uint64(v))
+	case float64:
+		amount, err = Parse(strconv.FormatFloat(v, 'f', Decimals, 64))
+	default:
+		return fmt.Errorf("cannot scan %T into money.Amount", value)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	*a = amount
+	return nil
+}
+
+func digitsOnly(value string) bool {
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
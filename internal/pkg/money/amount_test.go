@@ -0,0 +1,144 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseAndString(t *testing.T) {
+	cases := []struct {
+		value  string
+		units  uint64
+		string string
+	}{
+		{"0", 0, "0.00000000"},
+		{"12", 1_200_000_000, "12.00000000"},
+		{"0.5", 50_000_000, "0.50000000"},
+		{"1.00000000", 100_000_000, "1.00000000"},
+		{"0.00000001", 1, "0.00000001"},
+		{" 3.25 ", 325_000_000, "3.25000000"},
+		{"184467440737.09551615", 18446744073709551615, "184467440737.09551615"},
+	}
+
+	for _, c := range cases {
+		amount, err := Parse(c.value)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", c.value, err)
+		}
+		if amount.Units() != c.units {
+			t.Errorf("Parse(%q) = %d units, want %d", c.value, amount.Units(), c.units)
+		}
+		if amount.String() != c.string {
+			t.Errorf("Parse(%q).String() = %q, want %q", c.value, amount.String(), c.string)
+		}
+
+		again, err := Parse(amount.String())
+		if err != nil || again != amount {
+			t.Errorf("round trip of %q = %d, %v", c.value, again, err)
+		}
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	cases := []struct {
+		value string
+		err   error
+	}{
+		{"", ErrInvalidAmount},
+		{"1.000000001", ErrInvalidAmount},
+		{"-1", ErrInvalidAmount},
+		{"+1", ErrInvalidAmount},
+		{"1e3", ErrInvalidAmount},
+		{".5", ErrInvalidAmount},
+		{"1,5", ErrInvalidAmount},
+		{"abc", ErrInvalidAmount},
+		{"184467440737.09551616", ErrOverflow},
+		{"184467440738", ErrOverflow},
+		{"99999999999999999999", ErrOverflow},
+	}
+
+	for _, c := range cases {
+		if _, err := Parse(c.value); !errors.Is(err, c.err) {
+			t.Errorf("Parse(%q) error = %v, want %v", c.value, err, c.err)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	cases := []struct {
+		data string
+		want Amount
+	}{
+		{`1.5`, 150_000_000},
+		{`"1.5"`, 150_000_000},
+		{`10`, 1_000_000_000},
+		{`"0.00000001"`, 1},
+	}
+
+	for _, c := range cases {
+		var amount Amount
+		if err := json.Unmarshal([]byte(c.data), &amount); err != nil {
+			t.Fatalf("Unmarshal(%s): %v", c.data, err)
+		}
+		if amount != c.want {
+			t.Errorf("Unmarshal(%s) = %d, want %d", c.data, amount, c.want)
+		}
+	}
+
+	for _, data := range []string{`-1`, `"1.000000001"`, `1e2`, `true`, `"abc"`} {
+		var amount Amount
+		if err := json.Unmarshal([]byte(data), &amount); err == nil {
+			t.Errorf("Unmarshal(%s) = %d, want an error", data, amount)
+		}
+	}
+
+	amount := Amount(7)
+	if err := json.Unmarshal([]byte(`null`), &amount); err != nil || amount != 7 {
+		t.Errorf("Unmarshal(null) = %d, %v, want the amount unchanged", amount, err)
+	}
+
+	encoded, err := json.Marshal(map[string]Amount{"a": 150_000_000, "b": 1_000_000_000, "c": 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(encoded) != `{"a":1.5,"b":10,"c":0}` {
+		t.Errorf("Marshal = %s", encoded)
+	}
+}
+
+func TestScan(t *testing.T) {
+	cases := []struct {
+		value any
+		want  Amount
+	}{
+		{"12.50000000", 1_250_000_000},
+		{[]byte("0.00000001"), 1},
+		{[]byte("1000.00000000"), 100_000_000_000},
+		{int64(3), 300_000_000},
+		{float64(0.25), 25_000_000},
+		{nil, 0},
+	}
+
+	for _, c := range cases {
+		amount := Amount(99)
+		if err := amount.Scan(c.value); err != nil {
+			t.Fatalf("Scan(%v): %v", c.value, err)
+		}
+		if amount != c.want {
+			t.Errorf("Scan(%v) = %d, want %d", c.value, amount, c.want)
+		}
+	}
+
+	for _, value := range []any{"-1.00000000", int64(-1), true} {
+		var amount Amount
+		if err := amount.Scan(value); err == nil {
+			t.Errorf("Scan(%v) = %d, want an error", value, amount)
+		}
+	}
+
+	value, err := Amount(1_250_000_000).Value()
+	if err != nil || value != "12.50000000" {
+		t.Errorf("Value() = %v, %v", value, err)
+	}
+}
@@ -0,0 +1,89 @@
+package notification
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/inbox"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/model"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/ws"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/wsevent"
+	"github.com/rs/zerolog/log"
+)
+
+// View is a notification as it is listed and published
+type View struct {
+	model.Notification
+	Payload json.RawMessage `json:"payload"`
+}
+
+func ToView(notification model.Notification) View {
+	return View{Notification: notification, Payload: json.RawMessage(notification.Payload)}
+}
+
+// Topic is the websocket topic the notifications of the user are published to
+func Topic(userId uint64) string {
+	return fmt.Sprintf("notifications/%d", userId)
+}
+
+// Notifier stores the notifications produced by event handlers with the
+// changes of the event, so users who were offline find them later, and
+// publishes them to the users who are connected.
+type Notifier struct {
+	notificationHub *ws.WebSocketNotificationHub
+}
+
+func NewNotifier(notificationHub *ws.WebSocketNotificationHub) *Notifier {
+	return &Notifier{notificationHub: notificationHub}
+}
+
+// Notify stores the notification in the transaction of the event and
+// publishes it once the event is applied. The payload is the websocket event
+// the notification is about.
+func (n *Notifier) Notify(event *inbox.Event, userId uint64, notificationType model.NotificationType, reference string, payload wsevent.Event) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	notification := model.Notification{
+		UserId:    userId,
+		Type:      notificationType,
+		Reference: reference,
+		Payload:   string(data),
+		CreatedAt: time.Now().UTC().UnixMilli(),
+	}
+	result := event.Tx.Create(&notification)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	event.AfterCommit(func() {
+		n.notificationHub.Publish(Topic(userId), wsevent.Notification{
+			Id:        notification.Id,
+			UserId:    notification.UserId,
+			Type:      string(notification.Type),
+			Reference: notification.Reference,
+			Payload:   json.RawMessage(notification.Payload),
+			ReadAt:    notification.ReadAt,
+			CreatedAt: notification.CreatedAt,
+		})
+	})
+	return nil
+}
+
+// Replace deletes the unread notifications of the same type and reference
+// before notifying, e.g. the moves of a game the user has not looked at
+// collapse into the last one
+func (n *Notifier) Replace(event *inbox.Event, userId uint64, notificationType model.NotificationType, reference string, payload wsevent.Event) error {
+	result := event.Tx.
+		Where("user_id = ? AND type = ? AND reference = ? AND read_at IS NULL", userId, notificationType, reference).
+		Delete(&model.Notification{})
+	if result.Error != nil {
+		log.Warn().Err(result.Error).Msg("Error while replacing notification")
+		return result.Error
+	}
+
+	return n.Notify(event, userId, notificationType, reference, payload)
+}
@@ -0,0 +1,31 @@
+package outbox
+
+import (
+	"time"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/model"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/pubsub"
+	"gorm.io/gorm"
+)
+
+// Enqueue stores the message in the outbox table using the given transaction.
+// The message is published by the Relay only after the transaction commits,
+// so a rolled back transaction never produces a message. Messages
+// implementing pubsub.Ordered are relayed in order per ordering key.
+func Enqueue(tx *gorm.DB, message pubsub.Publishable) error {
+	now := time.Now().UTC().UnixMilli()
+
+	var orderingKey *string
+	if ordered, ok := message.(pubsub.Ordered); ok && ordered.OrderingKey() != "" {
+		key := ordered.OrderingKey()
+		orderingKey = &key
+	}
+
+	return tx.Create(&model.OutboxMessage{
+		Topic:         message.GetEventTopicName(),
+		OrderingKey:   orderingKey,
+		Payload:       pubsub.EncodeMessage(message),
+		CreatedAt:     now,
+		NextAttemptAt: now,
+	}).Error
+}
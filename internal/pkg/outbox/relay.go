@@ -0,0 +1,177 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jpillora/backoff"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/model"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/pubsub"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultPollInterval = 500 * time.Millisecond
+	defaultBatchSize    = 50
+	publishTimeout      = 10 * time.Second
+	sentRetention       = 7 * 24 * time.Hour
+	cleanupInterval     = time.Hour
+)
+
+// Relay publishes pending outbox messages. Messages are claimed with
+// FOR UPDATE SKIP LOCKED so any number of instances can run a relay at the
+// same time without publishing the same message concurrently.
+type Relay struct {
+	db           *gorm.DB
+	broker       pubsub.Broker
+	pollInterval time.Duration
+	batchSize    int
+	backoff      *backoff.Backoff
+	lastCleanup  time.Time
+}
+
+func NewRelay(db *gorm.DB, broker pubsub.Broker) *Relay {
+	pollInterval := viper.GetDuration("OUTBOX_POLL_INTERVAL")
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+
+	return &Relay{
+		db:           db,
+		broker:       broker,
+		pollInterval: pollInterval,
+		batchSize:    defaultBatchSize,
+		backoff: &backoff.Backoff{
+			Min:    time.Second,
+			Max:    5 * time.Minute,
+			Factor: 2,
+			Jitter: true,
+		},
+	}
+}
+
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for {
+			relayed, err := r.relayBatch(ctx)
+			if err != nil {
+				log.Warn().Err(err).Msg("Error while relaying outbox messages")
+				break
+			}
+			if relayed < r.batchSize {
+				break
+			}
+		}
+
+		r.cleanup()
+	}
+}
+
+func (r *Relay) relayBatch(ctx context.Context) (int, error) {
+	relayed := 0
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var messages []model.OutboxMessage
+		result := tx.
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("sent_at IS NULL AND next_attempt_at <= ?", time.Now().UTC().UnixMilli()).
+			// an ordered message waits until the earlier ones with its key are sent
+			Where(`(ordering_key IS NULL OR NOT EXISTS (SELECT 1 FROM outbox_message earlier
+				WHERE earlier.ordering_key = outbox_message.ordering_key
+				AND earlier.sent_at IS NULL AND earlier.id < outbox_message.id))`).
+			Order("id").
+			Limit(r.batchSize).
+			Find(&messages)
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		blockedKeys := map[string]bool{}
+		for _, message := range messages {
+			if message.OrderingKey != nil && blockedKeys[*message.OrderingKey] {
+				continue
+			}
+
+			sent, err := r.publish(ctx, tx, message)
+			if err != nil {
+				return err
+			}
+			if !sent && message.OrderingKey != nil {
+				blockedKeys[*message.OrderingKey] = true
+			}
+			relayed++
+		}
+
+		return nil
+	})
+
+	return relayed, err
+}
+
+// publish reports whether the message was sent, a failed publish is
+// rescheduled
+func (r *Relay) publish(ctx context.Context, tx *gorm.DB, message model.OutboxMessage) (bool, error) {
+	publishCtx, cancel := context.WithTimeout(ctx, publishTimeout)
+	defer cancel()
+
+	publication := pubsub.Publication{
+		Topic: message.Topic,
+		Data:  message.Payload,
+	}
+	if message.OrderingKey != nil {
+		publication.OrderingKey = *message.OrderingKey
+	}
+
+	publishErr := r.broker.Publish(publishCtx, publication)
+	now := time.Now().UTC()
+
+	if publishErr != nil {
+		log.Warn().Err(publishErr).Msg(fmt.Sprintf("Failed to relay outbox message %d to %s", message.Id, message.Topic))
+		lastError := publishErr.Error()
+		return false, tx.
+			Model(&model.OutboxMessage{}).
+			Where("id = ?", message.Id).
+			Updates(map[string]any{
+				"attempts":        message.Attempts + 1,
+				"last_error":      lastError,
+				"next_attempt_at": now.Add(r.backoff.ForAttempt(float64(message.Attempts))).UnixMilli(),
+			}).Error
+	}
+
+	return true, tx.
+		Model(&model.OutboxMessage{}).
+		Where("id = ?", message.Id).
+		Updates(map[string]any{
+			"attempts": message.Attempts + 1,
+			"sent_at":  now.UnixMilli(),
+		}).Error
+}
+
+func (r *Relay) cleanup() {
+	if time.Since(r.lastCleanup) < cleanupInterval {
+		return
+	}
+	r.lastCleanup = time.Now()
+
+	result := r.db.
+		Where("sent_at IS NOT NULL AND sent_at < ?", time.Now().UTC().Add(-sentRetention).UnixMilli()).
+		Delete(&model.OutboxMessage{})
+
+	if result.Error != nil {
+		log.Warn().Err(result.Error).Msg("Error while cleaning up sent outbox messages")
+	}
+}
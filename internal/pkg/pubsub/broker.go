@@ -0,0 +1,122 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	BrokerGcp    = "gcp"
+	BrokerMemory = "memory"
+)
+
+// Broker publishes messages to topics and delivers messages of a
+// subscription to a handler. Every delivered message has to be acked or
+// nacked, nacked messages are redelivered.
+type Broker interface {
+	Publish(ctx context.Context, publication Publication) error
+	// Subscribe blocks until the context is done or the subscription fails.
+	Subscribe(ctx context.Context, subscriptionId string, handler MessageHandler) error
+	Close() error
+}
+
+// SubscriptionCreator is implemented by the brokers that create
+// subscriptions at runtime, e.g. one for every instance
+type SubscriptionCreator interface {
+	// CreateSubscription subscribes to the topic unless the subscription
+	// exists already
+	CreateSubscription(ctx context.Context, topic string, subscriptionId string, options SubscriptionOptions) error
+}
+
+// RetryConfigurer is implemented by the brokers that delay the redelivery
+// of nacked messages with a backoff per subscription
+type RetryConfigurer interface {
+	// SetRetryBackoff redelivers nacked messages of the subscription after a
+	// delay growing from min to max with the delivery attempts
+	SetRetryBackoff(ctx context.Context, subscriptionId string, min time.Duration, max time.Duration) error
+}
+
+type SubscriptionOptions struct {
+	// Ordered delivers the messages with the same ordering key in order
+	Ordered bool
+	// Expiration deletes the subscription once it had no subscriber for that
+	// long, GCP accepts a day or more. Zero keeps the subscription.
+	Expiration time.Duration
+}
+
+type Publication struct {
+	Topic      string
+	Data       []byte
+	Attributes map[string]string
+	// OrderingKey is optional, messages of a subscription with the same key
+	// are delivered one at a time in publish order
+	OrderingKey string
+}
+
+type MessageHandler func(ctx context.Context, message *Message)
+
+type Message struct {
+	ID          string
+	Data        []byte
+	Attributes  map[string]string
+	OrderingKey string
+	// DeliveryAttempt is nil when the broker does not count deliveries
+	DeliveryAttempt *int
+
+	ack  func()
+	nack func()
+}
+
+func NewMessage(id string, data []byte, attributes map[string]string, ack func(), nack func()) *Message {
+	if attributes == nil {
+		attributes = map[string]string{}
+	}
+
+	return &Message{
+		ID:         id,
+		Data:       data,
+		Attributes: attributes,
+		ack:        ack,
+		nack:       nack,
+	}
+}
+
+func (m *Message) Ack() {
+	if m.ack != nil {
+		m.ack()
+	}
+}
+
+func (m *Message) Nack() {
+	if m.nack != nil {
+		m.nack()
+	}
+}
+
+// NewBrokerFromConfig creates the broker selected with PUBSUB_BROKER, GCP
+// Pub/Sub unless set to memory.
+func NewBrokerFromConfig(ctx context.Context) (Broker, error) {
+	switch broker := viper.GetString("PUBSUB_BROKER"); broker {
+	case "", BrokerGcp:
+		return NewGcpBroker(ctx, viper.GetString("GOOGLE_PROJECT_ID"))
+	case BrokerMemory:
+		return NewMemoryBroker(), nil
+	default:
+		return nil, fmt.Errorf("unknown PUBSUB_BROKER %s", broker)
+	}
+}
+
+func EncodeMessage(message any) []byte {
+	switch message.(type) {
+	case string:
+		return []byte(message.(string))
+
+	default:
+		bytes, _ := json.Marshal(message)
+		return bytes
+	}
+}
@@ -0,0 +1,125 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	gcppubsub "cloud.google.com/go/pubsub"
+	"github.com/rs/zerolog/log"
+)
+
+type gcpBroker struct {
+	client *gcppubsub.Client
+
+	mu     sync.Mutex
+	topics map[string]*gcppubsub.Topic
+}
+
+func NewGcpBroker(ctx context.Context, projectId string) (Broker, error) {
+	if projectId == "" {
+		return nil, errors.New("pub sub missing projectID to initialize")
+	}
+
+	client, err := gcppubsub.NewClient(ctx, projectId)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing pub sub connection: %w", err)
+	}
+	log.Info().Msg(fmt.Sprintf("Successful pubsub init with projectID %s", projectId))
+
+	return &gcpBroker{
+		client: client,
+		topics: map[string]*gcppubsub.Topic{},
+	}, nil
+}
+
+func (b *gcpBroker) Publish(ctx context.Context, publication Publication) error {
+	topic := b.topic(publication.Topic)
+	_, err := topic.Publish(ctx, &gcppubsub.Message{
+		Data:        publication.Data,
+		Attributes:  publication.Attributes,
+		OrderingKey: publication.OrderingKey,
+	}).Get(ctx)
+
+	// a failed publish pauses the ordering key until resumed, the caller
+	// publishes the message again
+	if err != nil && publication.OrderingKey != "" {
+		topic.ResumePublish(publication.OrderingKey)
+	}
+	return err
+}
+
+func (b *gcpBroker) Subscribe(ctx context.Context, subscriptionId string, handler MessageHandler) error {
+	sub := b.client.Subscription(subscriptionId)
+
+	return sub.Receive(ctx, func(ctx context.Context, m *gcppubsub.Message) {
+		message := NewMessage(m.ID, m.Data, m.Attributes, m.Ack, m.Nack)
+		message.OrderingKey = m.OrderingKey
+		message.DeliveryAttempt = m.DeliveryAttempt
+		handler(ctx, message)
+	})
+}
+
+func (b *gcpBroker) CreateSubscription(ctx context.Context, topic string, subscriptionId string, options SubscriptionOptions) error {
+	sub := b.client.Subscription(subscriptionId)
+	exists, err := sub.Exists(ctx)
+	if err != nil {
+		return fmt.Errorf("error checking subscription %s: %w", subscriptionId, err)
+	}
+	if exists {
+		return nil
+	}
+
+	config := gcppubsub.SubscriptionConfig{
+		Topic:                 b.client.Topic(topic),
+		EnableMessageOrdering: options.Ordered,
+	}
+	if options.Expiration > 0 {
+		config.ExpirationPolicy = options.Expiration
+	}
+
+	if _, err := b.client.CreateSubscription(ctx, subscriptionId, config); err != nil {
+		return fmt.Errorf("error creating subscription %s: %w", subscriptionId, err)
+	}
+	log.Info().Str("subscription", subscriptionId).Str("topic", topic).Msg("Created pubsub subscription")
+	return nil
+}
+
+func (b *gcpBroker) SetRetryBackoff(ctx context.Context, subscriptionId string, min time.Duration, max time.Duration) error {
+	_, err := b.client.Subscription(subscriptionId).Update(ctx, gcppubsub.SubscriptionConfigToUpdate{
+		RetryPolicy: &gcppubsub.RetryPolicy{MinimumBackoff: min, MaximumBackoff: max},
+	})
+	if err != nil {
+		return fmt.Errorf("error setting the retry policy of subscription %s: %w", subscriptionId, err)
+	}
+	return nil
+}
+
+func (b *gcpBroker) Close() error {
+	b.mu.Lock()
+	for _, topic := range b.topics {
+		topic.Stop()
+	}
+	b.topics = map[string]*gcppubsub.Topic{}
+	b.mu.Unlock()
+
+	return b.client.Close()
+}
+
+// topics batch messages in the background, they are kept until Close.
+// Ordering keys are only honored by subscriptions created with message
+// ordering enabled.
+func (b *gcpBroker) topic(topicName string) *gcppubsub.Topic {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	topic, ok := b.topics[topicName]
+	if !ok {
+		topic = b.client.Topic(topicName)
+		topic.EnableMessageOrdering = true
+		b.topics[topicName] = topic
+	}
+	return topic
+}
@@ -0,0 +1,317 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jpillora/backoff"
+)
+
+const (
+	memoryQueueSize    = 1024
+	memoryRequeueDelay = 10 * time.Millisecond
+)
+
+var ErrBrokerClosed = errors.New("broker closed")
+
+var (
+	_ Broker              = (*MemoryBroker)(nil)
+	_ SubscriptionCreator = (*MemoryBroker)(nil)
+	_ RetryConfigurer     = (*MemoryBroker)(nil)
+)
+
+// MemoryBroker delivers messages in process, for tests and local runs without
+// Google Cloud. A subscription receives the messages of the topics bound to
+// it with Bind. Subscriptions named like the topic, or like the topic with a
+// "-sub" suffix, are bound to it by default. Messages published before a
+// subscription exists are not delivered to it. Messages with an ordering key
+// are delivered one at a time per subscription and key, the next one once
+// the previous one is acked.
+type MemoryBroker struct {
+	mu            sync.Mutex
+	bindings      map[string][]string
+	subscriptions map[string]*memorySubscription
+	nextId        uint64
+	done          chan struct{}
+	closeOnce     sync.Once
+}
+
+type memorySubscription struct {
+	queue chan memoryDelivery
+
+	mu sync.Mutex
+	// ordered deliveries waiting for the delivery in flight with their key,
+	// a key is present while one of its deliveries is in flight
+	waiting map[string][]memoryDelivery
+	// nacked deliveries are redelivered right away without retry backoff
+	retry *backoff.Backoff
+}
+
+type memoryDelivery struct {
+	id          string
+	data        []byte
+	attributes  map[string]string
+	orderingKey string
+	attempt     int
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		bindings:      map[string][]string{},
+		subscriptions: map[string]*memorySubscription{},
+		done:          make(chan struct{}),
+	}
+}
+
+// Bind delivers the messages published to the topic to the subscription.
+func (b *MemoryBroker) Bind(topic string, subscriptionId string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.bindings[topic] = append(b.bindings[topic], subscriptionId)
+	b.subscription(subscriptionId)
+}
+
+// CreateSubscription binds the subscription to the topic, memory
+// subscriptions are always ordered and never expire
+func (b *MemoryBroker) CreateSubscription(_ context.Context, topic string, subscriptionId string, _ SubscriptionOptions) error {
+	b.Bind(topic, subscriptionId)
+	return nil
+}
+
+func (b *MemoryBroker) SetRetryBackoff(_ context.Context, subscriptionId string, min time.Duration, max time.Duration) error {
+	b.mu.Lock()
+	sub := b.subscription(subscriptionId)
+	b.mu.Unlock()
+
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	sub.retry = &backoff.Backoff{Min: min, Max: max, Factor: 2}
+	return nil
+}
+
+func (b *MemoryBroker) Publish(_ context.Context, publication Publication) error {
+	select {
+	case <-b.done:
+		return ErrBrokerClosed
+	default:
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// a publication goes to every subscription or to none, so a retry does
+	// not deliver it twice. Queues only fill up with the lock held.
+	subscriptionIds := b.subscribers(publication.Topic)
+	for _, subscriptionId := range subscriptionIds {
+		queue := b.subscriptions[subscriptionId].queue
+		if len(queue) == cap(queue) {
+			return fmt.Errorf("subscription %s is full", subscriptionId)
+		}
+	}
+
+	b.nextId++
+	id := strconv.FormatUint(b.nextId, 10)
+
+	for _, subscriptionId := range subscriptionIds {
+		b.subscriptions[subscriptionId].queue <- memoryDelivery{
+			id:          id,
+			data:        publication.Data,
+			attributes:  copyAttributes(publication.Attributes),
+			orderingKey: publication.OrderingKey,
+			attempt:     1,
+		}
+	}
+
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(ctx context.Context, subscriptionId string, handler MessageHandler) error {
+	b.mu.Lock()
+	sub := b.subscription(subscriptionId)
+	b.mu.Unlock()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-b.done:
+			return nil
+		case delivery := <-sub.queue:
+			if sub.hold(delivery) {
+				continue
+			}
+			go handler(ctx, b.message(ctx, sub, delivery, handler))
+		}
+	}
+}
+
+func (b *MemoryBroker) Close() error {
+	b.closeOnce.Do(func() { close(b.done) })
+	return nil
+}
+
+func (b *MemoryBroker) message(ctx context.Context, sub *memorySubscription, delivery memoryDelivery, handler MessageHandler) *Message {
+	var once sync.Once
+	ack := func() {
+		once.Do(func() {
+			if next, ok := sub.release(delivery.orderingKey); ok {
+				go handler(ctx, b.message(ctx, sub, next, handler))
+			}
+		})
+	}
+	nack := func() {
+		once.Do(func() {
+			redelivery := delivery
+			redelivery.attempt++
+			delay := sub.retryDelay(delivery.attempt)
+
+			// ordered deliveries keep their place ahead of the waiting ones
+			if delivery.orderingKey != "" {
+				go func() {
+					if b.wait(delay) {
+						handler(ctx, b.message(ctx, sub, redelivery, handler))
+					}
+				}()
+				return
+			}
+
+			go func() {
+				if b.wait(delay) {
+					b.requeue(sub, redelivery)
+				}
+			}()
+		})
+	}
+
+	message := NewMessage(delivery.id, delivery.data, copyAttributes(delivery.attributes), ack, nack)
+	message.OrderingKey = delivery.orderingKey
+	attempt := delivery.attempt
+	message.DeliveryAttempt = &attempt
+	return message
+}
+
+// requeue puts a nacked delivery back once its queue has room, queues are
+// only written with the lock held
+func (b *MemoryBroker) requeue(sub *memorySubscription, delivery memoryDelivery) {
+	for {
+		b.mu.Lock()
+		select {
+		case sub.queue <- delivery:
+			b.mu.Unlock()
+			return
+		default:
+		}
+		b.mu.Unlock()
+
+		select {
+		case <-b.done:
+			return
+		case <-time.After(memoryRequeueDelay):
+		}
+	}
+}
+
+// wait reports false when the broker closed in the meantime
+func (b *MemoryBroker) wait(delay time.Duration) bool {
+	if delay <= 0 {
+		return true
+	}
+
+	select {
+	case <-b.done:
+		return false
+	case <-time.After(delay):
+		return true
+	}
+}
+
+// must be called with the lock held
+func (b *MemoryBroker) subscription(subscriptionId string) *memorySubscription {
+	sub, ok := b.subscriptions[subscriptionId]
+	if !ok {
+		sub = &memorySubscription{
+			queue:   make(chan memoryDelivery, memoryQueueSize),
+			waiting: map[string][]memoryDelivery{},
+		}
+		b.subscriptions[subscriptionId] = sub
+	}
+	return sub
+}
+
+// must be called with the lock held
+func (b *MemoryBroker) subscribers(topic string) []string {
+	var subscriptionIds []string
+	seen := map[string]bool{}
+
+	candidates := append([]string{topic, topic + "-sub"}, b.bindings[topic]...)
+	for _, subscriptionId := range candidates {
+		if _, ok := b.subscriptions[subscriptionId]; ok && !seen[subscriptionId] {
+			seen[subscriptionId] = true
+			subscriptionIds = append(subscriptionIds, subscriptionId)
+		}
+	}
+	return subscriptionIds
+}
+
+// retryDelay is how long a delivery nacked on the attempt waits
+func (s *memorySubscription) retryDelay(attempt int) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.retry == nil {
+		return 0
+	}
+	return s.retry.ForAttempt(float64(attempt - 1))
+}
+
+// hold queues an ordered delivery behind the one in flight with its key,
+// it reports false when the delivery can be handled right away
+func (s *memorySubscription) hold(delivery memoryDelivery) bool {
+	if delivery.orderingKey == "" {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	waiting, inFlight := s.waiting[delivery.orderingKey]
+	if inFlight {
+		s.waiting[delivery.orderingKey] = append(waiting, delivery)
+	} else {
+		s.waiting[delivery.orderingKey] = nil
+	}
+	return inFlight
+}
+
+// release returns the next delivery waiting for the acked one of the key
+func (s *memorySubscription) release(orderingKey string) (memoryDelivery, bool) {
+	if orderingKey == "" {
+		return memoryDelivery{}, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	waiting := s.waiting[orderingKey]
+	if len(waiting) == 0 {
+		delete(s.waiting, orderingKey)
+		return memoryDelivery{}, false
+	}
+
+	s.waiting[orderingKey] = waiting[1:]
+	return waiting[0], true
+}
+
+func copyAttributes(attributes map[string]string) map[string]string {
+	copied := make(map[string]string, len(attributes))
+	for k, v := range attributes {
+		copied[k] = v
+	}
+	return copied
+}
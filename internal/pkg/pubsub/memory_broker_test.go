@@ -0,0 +1,82 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryPublishToFullSubscriptionDeliversToNone(t *testing.T) {
+	b := NewMemoryBroker()
+	b.Bind("topic", "a")
+	b.Bind("topic", "b")
+
+	full := b.subscriptions["b"].queue
+	for i := 0; i < cap(full); i++ {
+		full <- memoryDelivery{}
+	}
+
+	if err := b.Publish(context.Background(), Publication{Topic: "topic", Data: []byte("{}")}); err == nil {
+		t.Fatal("published to a full subscription")
+	}
+	if n := len(b.subscriptions["a"].queue); n != 0 {
+		t.Fatalf("delivered %d messages to the other subscription", n)
+	}
+
+	<-full
+	if err := b.Publish(context.Background(), Publication{Topic: "topic", Data: []byte("{}")}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(b.subscriptions["a"].queue); n != 1 {
+		t.Errorf("delivered %d messages after the retry, want 1", n)
+	}
+}
+
+func TestMemoryNackedMessageWaitsForRetryBackoff(t *testing.T) {
+	const backoff = 50 * time.Millisecond
+
+	for _, orderingKey := range []string{"", "game/1"} {
+		t.Run("ordering key "+orderingKey, func(t *testing.T) {
+			b := NewMemoryBroker()
+			defer b.Close()
+			b.Bind("topic", "sub")
+			if err := b.SetRetryBackoff(context.Background(), "sub", backoff, time.Second); err != nil {
+				t.Fatal(err)
+			}
+
+			deliveries := make(chan time.Time, 2)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go b.Subscribe(ctx, "sub", func(_ context.Context, message *Message) {
+				deliveries <- time.Now()
+				if *message.DeliveryAttempt == 1 {
+					message.Nack()
+					return
+				}
+				message.Ack()
+			})
+
+			err := b.Publish(context.Background(), Publication{Topic: "topic", Data: []byte("{}"), OrderingKey: orderingKey})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			first, second := receive(t, deliveries), receive(t, deliveries)
+			if waited := second.Sub(first); waited < backoff {
+				t.Errorf("redelivered after %s, want at least %s", waited, backoff)
+			}
+		})
+	}
+}
+
+func receive(t *testing.T, deliveries chan time.Time) time.Time {
+	t.Helper()
+
+	select {
+	case at := <-deliveries:
+		return at
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not delivered")
+		return time.Time{}
+	}
+}
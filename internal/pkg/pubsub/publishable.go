@@ -3,3 +3,9 @@ package pubsub
 type Publishable interface {
 	GetEventTopicName() string
 }
+
+// Ordered is implemented by messages that must be delivered in publish order
+// relative to other messages with the same ordering key.
+type Ordered interface {
+	OrderingKey() string
+}
@@ -36,6 +36,12 @@ func GetUserEmail(ctx *gin.Context) string {
 	return token.Claims[emailClaimKey].(string)
 }
 
+// Email is the email claim of the token, empty when the token has none
+func (at AccessToken) Email() string {
+	email, _ := at.Token.Claims[emailClaimKey].(string)
+	return email
+}
+
 func GetUserExternalId(ctx *gin.Context) string {
 	token := GetAccessToken(ctx)
 	return token.Subject
@@ -0,0 +1,231 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	writeWait      = 10 * time.Second
+	pingPeriod     = 30 * time.Second
+	pongWait       = 2 * pingPeriod
+	maxMessageSize = 64 * 1024
+)
+
+// SlowClientPolicy decides what happens to a client whose send queue is full
+type SlowClientPolicy string
+
+const (
+	// DropMessages drops the messages that do not fit the queue
+	DropMessages SlowClientPolicy = "drop"
+	// CloseClient closes the connection, the client reconnects and reloads
+	// its state
+	CloseClient SlowClientPolicy = "close"
+)
+
+// Client is a connection listening to hub topics. Connections support one
+// concurrent writer, so every write goes through the send queue drained by
+// the writer goroutine of the client.
+type Client struct {
+	conn      *websocket.Conn
+	transport transport
+	send      chan message
+	policy    SlowClientPolicy
+
+	done        chan struct{}
+	stopped     chan struct{}
+	closeOnce   sync.Once
+	closeCode   int
+	closeReason string
+}
+
+type message struct {
+	// seq is the sequence number of a hub event, 0 for other messages
+	seq  uint64
+	data []byte
+}
+
+// transport writes the messages of a client to its connection
+type transport interface {
+	write(m message, deadline time.Time) error
+	ping(deadline time.Time) error
+	close(code int, reason string, deadline time.Time)
+}
+
+func newClient(conn *websocket.Conn, queueSize int, policy SlowClientPolicy) *Client {
+	client := startClient(websocketTransport{conn: conn}, queueSize, policy)
+	client.conn = conn
+	return client
+}
+
+func startClient(t transport, queueSize int, policy SlowClientPolicy) *Client {
+	client := &Client{
+		transport: t,
+		send:      make(chan message, queueSize),
+		policy:    policy,
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	go client.writeLoop()
+	return client
+}
+
+// Send queues a message without blocking, it returns false when the message
+// was not queued
+func (c *Client) Send(data []byte) bool {
+	return c.enqueue(message{data: data})
+}
+
+func (c *Client) enqueue(m message) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+
+	select {
+	case c.send <- m:
+		return true
+	case <-c.done:
+		return false
+	default:
+	}
+
+	if c.policy == DropMessages {
+		log.Warn().Msg("[WEBSOCKET] Send queue full, message dropped")
+		return false
+	}
+
+	log.Warn().Msg("[WEBSOCKET] Send queue full, closing connection")
+	c.CloseWith(websocket.CloseTryAgainLater, "Client too slow")
+	return false
+}
+
+func (c *Client) SendJSON(event any) bool {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Warn().Err(err).Msg("[WEBSOCKET] Error encoding event")
+		return false
+	}
+	return c.Send(data)
+}
+
+// ReadMessages passes every received message to handle until the connection
+// is closed or the client stops answering pings, only websocket clients
+// receive messages
+func (c *Client) ReadMessages(handle func(data []byte)) error {
+	if c.conn == nil {
+		return errors.New("client does not receive messages")
+	}
+
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		handle(data)
+	}
+}
+
+func (c *Client) Close() {
+	c.CloseWith(websocket.CloseNormalClosure, "")
+}
+
+// CloseWith closes the connection with the websocket close code once the
+// queued messages are written, it is safe to call more than once
+func (c *Client) CloseWith(code int, reason string) {
+	c.closeOnce.Do(func() {
+		c.closeCode = code
+		c.closeReason = reason
+		close(c.done)
+	})
+}
+
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Stopped is closed once the writer is done with the connection
+func (c *Client) Stopped() <-chan struct{} {
+	return c.stopped
+}
+
+func (c *Client) writeLoop() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		close(c.stopped)
+	}()
+
+	for {
+		select {
+		case m := <-c.send:
+			if err := c.transport.write(m, time.Now().Add(writeWait)); err != nil {
+				log.Warn().Err(err).Msg("[WEBSOCKET] Error writing to connection")
+				c.CloseWith(websocket.CloseAbnormalClosure, "")
+				c.transport.close(websocket.CloseAbnormalClosure, "", time.Now())
+				return
+			}
+
+		case <-ticker.C:
+			if err := c.transport.ping(time.Now().Add(writeWait)); err != nil {
+				c.CloseWith(websocket.CloseAbnormalClosure, "")
+				c.transport.close(websocket.CloseAbnormalClosure, "", time.Now())
+				return
+			}
+
+		case <-c.done:
+			deadline := time.Now().Add(writeWait)
+			c.flush(deadline)
+			c.transport.close(c.closeCode, c.closeReason, deadline)
+			return
+		}
+	}
+}
+
+// flush writes the messages queued before the client was closed, a slow
+// client gets writeWait for all of them
+func (c *Client) flush(deadline time.Time) {
+	for {
+		select {
+		case m := <-c.send:
+			if err := c.transport.write(m, deadline); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+type websocketTransport struct {
+	conn *websocket.Conn
+}
+
+func (t websocketTransport) write(m message, deadline time.Time) error {
+	t.conn.SetWriteDeadline(deadline)
+	return t.conn.WriteMessage(websocket.TextMessage, m.data)
+}
+
+func (t websocketTransport) ping(deadline time.Time) error {
+	return t.conn.WriteControl(websocket.PingMessage, nil, deadline)
+}
+
+// close sends the close frame unless the connection failed
+func (t websocketTransport) close(code int, reason string, deadline time.Time) {
+	if code != websocket.CloseAbnormalClosure {
+		t.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
+	}
+	t.conn.Close()
+}
@@ -0,0 +1,187 @@
+package ws
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const testTimeout = 5 * time.Second
+
+// fakeTransport records the messages written to a client. With a gate, every
+// write waits for the gate to be closed.
+type fakeTransport struct {
+	mu        sync.Mutex
+	writes    []message
+	closeCode int
+
+	gate    chan struct{}
+	writing chan struct{}
+	closed  chan struct{}
+}
+
+func newFakeTransport() *fakeTransport {
+	return &fakeTransport{writing: make(chan struct{}, 1), closed: make(chan struct{})}
+}
+
+func newGatedTransport() *fakeTransport {
+	t := newFakeTransport()
+	t.gate = make(chan struct{})
+	return t
+}
+
+func (t *fakeTransport) write(m message, _ time.Time) error {
+	select {
+	case t.writing <- struct{}{}:
+	default:
+	}
+	if t.gate != nil {
+		<-t.gate
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.writes = append(t.writes, m)
+	return nil
+}
+
+func (t *fakeTransport) ping(time.Time) error {
+	return nil
+}
+
+func (t *fakeTransport) close(code int, _ string, _ time.Time) {
+	t.mu.Lock()
+	t.closeCode = code
+	t.mu.Unlock()
+	close(t.closed)
+}
+
+func (t *fakeTransport) written() []message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]message(nil), t.writes...)
+}
+
+func (t *fakeTransport) waitWriting(tb testing.TB) {
+	tb.Helper()
+
+	select {
+	case <-t.writing:
+	case <-time.After(testTimeout):
+		tb.Fatal("client did not write")
+	}
+}
+
+func waitStopped(tb testing.TB, client *Client) {
+	tb.Helper()
+
+	select {
+	case <-client.Stopped():
+	case <-time.After(testTimeout):
+		tb.Fatal("client did not stop")
+	}
+}
+
+func testMessage(i int) message {
+	return message{data: []byte(strconv.Itoa(i))}
+}
+
+func TestFullQueueDropsMessages(t *testing.T) {
+	transport := newGatedTransport()
+	client := startClient(transport, 1, DropMessages)
+
+	if !client.enqueue(testMessage(1)) {
+		t.Fatal("first message not queued")
+	}
+	transport.waitWriting(t)
+	if !client.enqueue(testMessage(2)) {
+		t.Fatal("second message not queued")
+	}
+
+	if client.enqueue(testMessage(3)) {
+		t.Error("message queued to a full queue")
+	}
+	select {
+	case <-client.Done():
+		t.Fatal("client closed when dropping messages")
+	default:
+	}
+
+	close(transport.gate)
+	client.Close()
+	waitStopped(t, client)
+
+	writes := transport.written()
+	if len(writes) != 2 || string(writes[0].data) != "1" || string(writes[1].data) != "2" {
+		t.Errorf("written %q, want the messages 1 and 2", writes)
+	}
+	if transport.closeCode != websocket.CloseNormalClosure {
+		t.Errorf("closed with %d, want %d", transport.closeCode, websocket.CloseNormalClosure)
+	}
+}
+
+func TestFullQueueClosesClient(t *testing.T) {
+	transport := newGatedTransport()
+	client := startClient(transport, 1, CloseClient)
+
+	client.enqueue(testMessage(1))
+	transport.waitWriting(t)
+	client.enqueue(testMessage(2))
+
+	if client.enqueue(testMessage(3)) {
+		t.Error("message queued to a full queue")
+	}
+	select {
+	case <-client.Done():
+	default:
+		t.Fatal("client not closed")
+	}
+	if client.enqueue(testMessage(4)) {
+		t.Error("message queued to a closed client")
+	}
+
+	close(transport.gate)
+	waitStopped(t, client)
+
+	if writes := transport.written(); len(writes) != 2 {
+		t.Errorf("written %q, want the 2 queued messages", writes)
+	}
+	if transport.closeCode != websocket.CloseTryAgainLater {
+		t.Errorf("closed with %d, want %d", transport.closeCode, websocket.CloseTryAgainLater)
+	}
+}
+
+func TestCloseFlushesQueuedMessages(t *testing.T) {
+	transport := newGatedTransport()
+	client := startClient(transport, 4, CloseClient)
+
+	client.enqueue(testMessage(1))
+	transport.waitWriting(t)
+	for i := 2; i <= 4; i++ {
+		if !client.enqueue(testMessage(i)) {
+			t.Fatalf("message %d not queued", i)
+		}
+	}
+	client.CloseWith(websocket.CloseGoingAway, "shutdown")
+
+	close(transport.gate)
+	waitStopped(t, client)
+
+	writes := transport.written()
+	if len(writes) != 4 {
+		t.Fatalf("written %q, want 4 messages", writes)
+	}
+	for i, m := range writes {
+		if string(m.data) != strconv.Itoa(i+1) {
+			t.Errorf("message %d is %q", i+1, m.data)
+		}
+	}
+	if transport.closeCode != websocket.CloseGoingAway {
+		t.Errorf("closed with %d, want %d", transport.closeCode, websocket.CloseGoingAway)
+	}
+}
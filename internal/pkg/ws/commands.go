@@ -0,0 +1,107 @@
+package ws
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/reject"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	AckType     = "ACK"
+	ProblemType = "PROBLEM"
+
+	unknownCommand = "error.ws.unknown-command"
+)
+
+// CommandRequest is a command sent by a client over its connection, the
+// response to it carries the same id:
+//
+//	{"id": "1", "type": "PLAY_MOVE", "payload": {"x": 1, "y": 2}}
+//	{"id": "1", "type": "ACK", "payload": {"commandId": "..."}}
+//	{"id": "1", "type": "PROBLEM", "payload": {"title": "...", "status": 403, ...}}
+type CommandRequest struct {
+	Id      string          `json:"id"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+type CommandResponse struct {
+	Id      string `json:"id"`
+	Type    string `json:"type"`
+	Payload any    `json:"payload"`
+}
+
+// Command is a request of an authenticated user on a topic the user is
+// allowed to listen to, the handlers authorize the command itself
+type Command struct {
+	UserEmail string
+	Topic     string
+	Payload   json.RawMessage
+}
+
+// CommandHandler returns the payload of the ACK or the problem of the command
+type CommandHandler func(command Command) (any, *reject.ProblemWithTrace)
+
+// CommandRouter passes the commands of clients to the handlers registered
+// for their type
+type CommandRouter struct {
+	mu       sync.RWMutex
+	handlers map[string]CommandHandler
+}
+
+var commandRouterSingleton *CommandRouter
+
+// NewCommandRouter returns the router of the instance, the handlers are
+// registered with the routes of their package
+func NewCommandRouter() *CommandRouter {
+	singletonMutex.Lock()
+	defer singletonMutex.Unlock()
+
+	if commandRouterSingleton == nil {
+		commandRouterSingleton = &CommandRouter{handlers: map[string]CommandHandler{}}
+	}
+	return commandRouterSingleton
+}
+
+func (r *CommandRouter) Handle(commandType string, handler CommandHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.handlers[commandType] = handler
+}
+
+// Dispatch decodes the message as a command, runs its handler and returns
+// the response to send to the client
+func (r *CommandRouter) Dispatch(userEmail string, topic string, data []byte) CommandResponse {
+	var request CommandRequest
+	if err := json.Unmarshal(data, &request); err != nil || request.Type == "" {
+		return CommandResponse{Id: request.Id, Type: ProblemType, Payload: reject.BodyParseProblem()}
+	}
+
+	r.mu.RLock()
+	handler, ok := r.handlers[request.Type]
+	r.mu.RUnlock()
+
+	if !ok {
+		return CommandResponse{
+			Id:   request.Id,
+			Type: ProblemType,
+			Payload: reject.NewProblem().
+				WithTitle("Unknown command").
+				WithStatus(http.StatusBadRequest).
+				WithCode(unknownCommand).
+				WithParam("type", request.Type).
+				Build(),
+		}
+	}
+
+	result, problem := handler(Command{UserEmail: userEmail, Topic: topic, Payload: request.Payload})
+	if problem != nil {
+		log.Warn().Err(problem.Cause).Msg("[WEBSOCKET] Command " + request.Type + " failed: " + problem.Problem.Title)
+		return CommandResponse{Id: request.Id, Type: ProblemType, Payload: problem.Problem}
+	}
+	return CommandResponse{Id: request.Id, Type: AckType, Payload: result}
+}
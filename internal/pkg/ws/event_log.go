@@ -0,0 +1,145 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+const (
+	EventLogMemory   = "memory"
+	EventLogPostgres = "postgres"
+
+	defaultEventLogSize      = 100
+	defaultEventLogRetention = 24 * time.Hour
+	eventLogPruneInterval    = time.Minute
+)
+
+// EventLog numbers the events of every topic and keeps the last ones, so
+// clients that reconnect get the events they missed replayed.
+type EventLog interface {
+	// Append returns the sequence number of the event, one more than the one
+	// of the previous event of the topic
+	Append(ctx context.Context, topic string, event json.RawMessage) (uint64, error)
+	// Since returns the kept events of the topic after seq in order, and the
+	// sequence number of the last event of the topic
+	Since(ctx context.Context, topic string, seq uint64) ([]Publication, uint64, error)
+}
+
+// NewEventLogFromConfig creates the event log selected with WS_EVENT_LOG. The
+// instances share sequence numbers through postgres, the default when
+// WS_FANOUT connects instances. WS_EVENT_LOG_SIZE is the number of events
+// kept per topic, WS_EVENT_LOG_RETENTION how long they are kept.
+func NewEventLogFromConfig(db *gorm.DB) (EventLog, error) {
+	size := viper.GetInt("WS_EVENT_LOG_SIZE")
+	if size <= 0 {
+		size = defaultEventLogSize
+	}
+
+	retention := viper.GetDuration("WS_EVENT_LOG_RETENTION")
+	if retention <= 0 {
+		retention = defaultEventLogRetention
+	}
+
+	eventLog := viper.GetString("WS_EVENT_LOG")
+	if eventLog == "" {
+		eventLog = EventLogMemory
+		if fanout := viper.GetString("WS_FANOUT"); fanout == FanoutPostgres || fanout == FanoutBroker {
+			eventLog = EventLogPostgres
+		}
+	}
+
+	switch eventLog {
+	case EventLogMemory:
+		return NewMemoryEventLog(size, retention), nil
+	case EventLogPostgres:
+		return NewPostgresEventLog(db, size, retention), nil
+	default:
+		return nil, fmt.Errorf("unknown websocket event log %s", eventLog)
+	}
+}
+
+// MemoryEventLog keeps the events of a single instance
+type MemoryEventLog struct {
+	mu        sync.Mutex
+	size      int
+	retention time.Duration
+	topics    map[string]*memoryTopic
+	prunedAt  time.Time
+}
+
+type memoryTopic struct {
+	lastSeq   uint64
+	events    []Publication
+	updatedAt time.Time
+}
+
+func NewMemoryEventLog(size int, retention time.Duration) *MemoryEventLog {
+	return &MemoryEventLog{
+		size:      size,
+		retention: retention,
+		topics:    map[string]*memoryTopic{},
+		prunedAt:  time.Now(),
+	}
+}
+
+func (l *MemoryEventLog) Append(_ context.Context, topic string, event json.RawMessage) (uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.prune(now)
+
+	t := l.topics[topic]
+	if t == nil {
+		t = &memoryTopic{}
+		l.topics[topic] = t
+	}
+
+	t.lastSeq++
+	t.updatedAt = now
+	t.events = append(t.events, Publication{Topic: topic, Seq: t.lastSeq, Event: event})
+	if len(t.events) > l.size {
+		t.events = append([]Publication(nil), t.events[len(t.events)-l.size:]...)
+	}
+
+	return t.lastSeq, nil
+}
+
+func (l *MemoryEventLog) Since(_ context.Context, topic string, seq uint64) ([]Publication, uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	t := l.topics[topic]
+	if t == nil {
+		return nil, 0, nil
+	}
+
+	var events []Publication
+	for _, event := range t.events {
+		if event.Seq > seq {
+			events = append(events, event)
+		}
+	}
+	return events, t.lastSeq, nil
+}
+
+// prune forgets the topics without events for longer than the retention,
+// must be called with the lock held
+func (l *MemoryEventLog) prune(now time.Time) {
+	if now.Sub(l.prunedAt) < eventLogPruneInterval {
+		return
+	}
+	l.prunedAt = now
+
+	for topic, t := range l.topics {
+		if now.Sub(t.updatedAt) > l.retention {
+			delete(l.topics, topic)
+		}
+	}
+}
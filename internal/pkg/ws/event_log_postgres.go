@@ -0,0 +1,114 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// PostgresEventLog numbers the events of a topic with the row of the topic,
+// which is locked until the event is stored, so every instance numbers the
+// events of a topic the same way.
+type PostgresEventLog struct {
+	db        *gorm.DB
+	size      int
+	retention time.Duration
+
+	mu       sync.Mutex
+	prunedAt time.Time
+}
+
+type websocketEvent struct {
+	Topic     string
+	Seq       uint64
+	Event     string
+	CreatedAt int64
+}
+
+func (websocketEvent) TableName() string {
+	return "websocket_event"
+}
+
+func NewPostgresEventLog(db *gorm.DB, size int, retention time.Duration) *PostgresEventLog {
+	return &PostgresEventLog{db: db, size: size, retention: retention, prunedAt: time.Now()}
+}
+
+func (l *PostgresEventLog) Append(ctx context.Context, topic string, event json.RawMessage) (uint64, error) {
+	var seq uint64
+	err := l.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Raw(`INSERT INTO websocket_topic (topic, last_seq) VALUES (?, 1)
+			ON CONFLICT (topic) DO UPDATE SET last_seq = websocket_topic.last_seq + 1
+			RETURNING last_seq`, topic).Scan(&seq)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		result = tx.Create(&websocketEvent{
+			Topic:     topic,
+			Seq:       seq,
+			Event:     string(event),
+			CreatedAt: time.Now().UTC().UnixMilli(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if seq <= uint64(l.size) {
+			return nil
+		}
+		return tx.Where("topic = ? AND seq <= ?", topic, seq-uint64(l.size)).Delete(&websocketEvent{}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	l.prune(ctx)
+	return seq, nil
+}
+
+func (l *PostgresEventLog) Since(ctx context.Context, topic string, seq uint64) ([]Publication, uint64, error) {
+	var lastSeq uint64
+	result := l.db.WithContext(ctx).
+		Raw("SELECT last_seq FROM websocket_topic WHERE topic = ?", topic).
+		Scan(&lastSeq)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	var events []websocketEvent
+	result = l.db.WithContext(ctx).
+		Where("topic = ? AND seq > ?", topic, seq).
+		Order("seq").
+		Find(&events)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	publications := make([]Publication, len(events))
+	for i, event := range events {
+		publications[i] = Publication{Topic: event.Topic, Seq: event.Seq, Event: json.RawMessage(event.Event)}
+	}
+	return publications, lastSeq, nil
+}
+
+// prune deletes the events older than the retention, at most once per
+// eventLogPruneInterval and instance
+func (l *PostgresEventLog) prune(ctx context.Context) {
+	l.mu.Lock()
+	if time.Since(l.prunedAt) < eventLogPruneInterval {
+		l.mu.Unlock()
+		return
+	}
+	l.prunedAt = time.Now()
+	l.mu.Unlock()
+
+	before := time.Now().Add(-l.retention).UTC().UnixMilli()
+	result := l.db.WithContext(ctx).Where("created_at < ?", before).Delete(&websocketEvent{})
+	if result.Error != nil {
+		log.Warn().Err(result.Error).Msg("[WEBSOCKET] Error pruning the event log")
+	}
+}
@@ -0,0 +1,94 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/pubsub"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+const (
+	FanoutMemory   = "memory"
+	FanoutPostgres = "postgres"
+	FanoutBroker   = "broker"
+)
+
+// Fanout carries hub publications to the hubs of every instance, including
+// the publishing one, so a listener receives an event whichever instance
+// handled it.
+type Fanout interface {
+	Publish(ctx context.Context, publication Publication) error
+	// Listen passes the publications of every instance to deliver, it
+	// blocks until the context is done or the fanout fails.
+	Listen(ctx context.Context, deliver func(Publication)) error
+	Close() error
+}
+
+type Publication struct {
+	Topic string `json:"topic"`
+	// Seq is 0 when the event could not be added to the event log
+	Seq   uint64          `json:"seq,omitempty"`
+	Event json.RawMessage `json:"event"`
+}
+
+// NewFanoutFromConfig creates the fanout selected with WS_FANOUT. Without it
+// publications are only delivered to the listeners of this instance.
+func NewFanoutFromConfig(ctx context.Context, db *gorm.DB, broker pubsub.Broker) (Fanout, error) {
+	switch fanout := viper.GetString("WS_FANOUT"); fanout {
+	case "":
+		return nil, nil
+	case FanoutMemory:
+		return NewMemoryFanout(), nil
+	case FanoutPostgres:
+		return NewPostgresFanout(db, viper.GetString("DB_URL")), nil
+	case FanoutBroker:
+		return NewBrokerFanoutFromConfig(ctx, broker)
+	default:
+		return nil, fmt.Errorf("unknown websocket fanout %s", fanout)
+	}
+}
+
+// MemoryFanout connects the hubs of one process, for tests running several
+// hubs as instances.
+type MemoryFanout struct {
+	mu        sync.RWMutex
+	listeners map[int]func(Publication)
+	nextId    int
+}
+
+func NewMemoryFanout() *MemoryFanout {
+	return &MemoryFanout{listeners: map[int]func(Publication){}}
+}
+
+func (f *MemoryFanout) Publish(_ context.Context, publication Publication) error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	for _, deliver := range f.listeners {
+		deliver(publication)
+	}
+	return nil
+}
+
+func (f *MemoryFanout) Listen(ctx context.Context, deliver func(Publication)) error {
+	f.mu.Lock()
+	id := f.nextId
+	f.nextId++
+	f.listeners[id] = deliver
+	f.mu.Unlock()
+
+	<-ctx.Done()
+
+	f.mu.Lock()
+	delete(f.listeners, id)
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *MemoryFanout) Close() error {
+	return nil
+}
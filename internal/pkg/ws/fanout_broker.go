@@ -0,0 +1,105 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/pubsub"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultFanoutTopic              = "websocket.fanout"
+	defaultFanoutSubscriptionExpiry = 24 * time.Hour
+)
+
+// BrokerFanout publishes to a broker topic that every instance consumes
+// from its own subscription. Publications are ordered by hub topic, so the
+// subscriptions need message ordering enabled.
+type BrokerFanout struct {
+	broker         pubsub.Broker
+	topic          string
+	subscriptionId string
+}
+
+// NewBrokerFanoutFromConfig reads WS_FANOUT_TOPIC and WS_FANOUT_SUBSCRIPTION,
+// the subscription of this instance, which has to exist with message
+// ordering enabled. Without it the subscription is the topic followed by the
+// host name, created with ordering enabled and deleted by the broker once
+// unused for WS_FANOUT_SUBSCRIPTION_EXPIRATION, a day by default.
+func NewBrokerFanoutFromConfig(ctx context.Context, broker pubsub.Broker) (*BrokerFanout, error) {
+	topic := viper.GetString("WS_FANOUT_TOPIC")
+	if topic == "" {
+		topic = defaultFanoutTopic
+	}
+
+	subscriptionId := viper.GetString("WS_FANOUT_SUBSCRIPTION")
+	if subscriptionId != "" {
+		return NewBrokerFanout(broker, topic, subscriptionId), nil
+	}
+
+	creator, ok := broker.(pubsub.SubscriptionCreator)
+	if !ok {
+		return nil, errors.New("the broker does not create subscriptions, WS_FANOUT_SUBSCRIPTION is required")
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	subscriptionId = topic + "-" + hostname
+
+	expiration := viper.GetDuration("WS_FANOUT_SUBSCRIPTION_EXPIRATION")
+	if expiration <= 0 {
+		expiration = defaultFanoutSubscriptionExpiry
+	}
+
+	options := pubsub.SubscriptionOptions{Ordered: true, Expiration: expiration}
+	if err := creator.CreateSubscription(ctx, topic, subscriptionId, options); err != nil {
+		return nil, err
+	}
+	return NewBrokerFanout(broker, topic, subscriptionId), nil
+}
+
+func NewBrokerFanout(broker pubsub.Broker, topic string, subscriptionId string) *BrokerFanout {
+	if memory, ok := broker.(*pubsub.MemoryBroker); ok {
+		memory.Bind(topic, subscriptionId)
+	}
+
+	return &BrokerFanout{broker: broker, topic: topic, subscriptionId: subscriptionId}
+}
+
+func (f *BrokerFanout) Publish(ctx context.Context, publication Publication) error {
+	data, err := json.Marshal(publication)
+	if err != nil {
+		return err
+	}
+
+	return f.broker.Publish(ctx, pubsub.Publication{
+		Topic:       f.topic,
+		Data:        data,
+		OrderingKey: publication.Topic,
+	})
+}
+
+func (f *BrokerFanout) Listen(ctx context.Context, deliver func(Publication)) error {
+	return f.broker.Subscribe(ctx, f.subscriptionId, func(_ context.Context, message *pubsub.Message) {
+		defer message.Ack()
+
+		var publication Publication
+		if err := json.Unmarshal(message.Data, &publication); err != nil {
+			log.Warn().Err(err).Msg("[WEBSOCKET] Error decoding fanout message")
+			return
+		}
+		deliver(publication)
+	})
+}
+
+// Close leaves the broker open, it is shared with the event handlers
+func (f *BrokerFanout) Close() error {
+	return nil
+}
@@ -0,0 +1,74 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+const (
+	postgresFanoutChannel = "websocket_fanout"
+	// NOTIFY payloads must be shorter than 8000 bytes
+	maxNotifyPayload = 7999
+)
+
+// PostgresFanout sends publications with NOTIFY, every instance LISTENs on
+// a dedicated connection. It needs nothing but the database, publications
+// are lost while an instance reconnects.
+type PostgresFanout struct {
+	db  *gorm.DB
+	dsn string
+}
+
+func NewPostgresFanout(db *gorm.DB, dsn string) *PostgresFanout {
+	return &PostgresFanout{db: db, dsn: dsn}
+}
+
+func (f *PostgresFanout) Publish(ctx context.Context, publication Publication) error {
+	payload, err := json.Marshal(publication)
+	if err != nil {
+		return err
+	}
+	if len(payload) > maxNotifyPayload {
+		return fmt.Errorf("publication to %s has %d bytes, NOTIFY allows %d", publication.Topic, len(payload), maxNotifyPayload)
+	}
+
+	return f.db.WithContext(ctx).Exec("SELECT pg_notify(?, ?)", postgresFanoutChannel, string(payload)).Error
+}
+
+func (f *PostgresFanout) Listen(ctx context.Context, deliver func(Publication)) error {
+	conn, err := pgx.Connect(ctx, f.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+postgresFanoutChannel); err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		var publication Publication
+		if err := json.Unmarshal([]byte(notification.Payload), &publication); err != nil {
+			log.Warn().Err(err).Msg("[WEBSOCKET] Error decoding fanout notification")
+			continue
+		}
+		deliver(publication)
+	}
+}
+
+func (f *PostgresFanout) Close() error {
+	return nil
+}
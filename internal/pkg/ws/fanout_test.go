@@ -0,0 +1,105 @@
+package ws
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/pubsub"
+	"github.com/spf13/viper"
+)
+
+// waitListening waits until the fanout delivers to every hub, UseFanout
+// listens in the background
+func waitListening(t *testing.T, fanout *MemoryFanout, hubs int) {
+	t.Helper()
+
+	deadline := time.Now().Add(testTimeout)
+	for time.Now().Before(deadline) {
+		fanout.mu.RLock()
+		listening := len(fanout.listeners)
+		fanout.mu.RUnlock()
+
+		if listening == hubs {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("hubs not listening to the fanout")
+}
+
+func TestMemoryFanoutDeliversToEveryHub(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// instances share the event log, as with postgres
+	events := NewMemoryEventLog(defaultEventLogSize, defaultEventLogRetention)
+	fanout := NewMemoryFanout()
+	hubA := NewHub(defaultSendQueueSize, CloseClient)
+	hubB := NewHub(defaultSendQueueSize, CloseClient)
+	for _, hub := range []*WebSocketNotificationHub{hubA, hubB} {
+		hub.UseEventLog(events)
+		hub.UseFanout(ctx, fanout)
+	}
+	waitListening(t, fanout, 2)
+
+	transportA, transportB := newFakeTransport(), newFakeTransport()
+	clientA := startClient(transportA, defaultSendQueueSize, CloseClient)
+	clientB := startClient(transportB, defaultSendQueueSize, CloseClient)
+	hubA.RegisterListener("game/1", clientA)
+	hubB.RegisterListener("game/1", clientB)
+
+	hubA.Publish("game/1", testEvent(1))
+	hubB.Publish("game/1", testEvent(2))
+	hubA.Publish("game/2", testEvent(3))
+
+	for name, got := range map[string][]uint64{
+		"publishing hub": seqs(t, clientA, transportA),
+		"other hub":      seqs(t, clientB, transportB),
+	} {
+		if len(got) != 2 || got[0] != 1 || got[1] != 2 {
+			t.Errorf("client of the %s received %v, want [1 2]", name, got)
+		}
+	}
+
+	cancel()
+	waitListening(t, fanout, 0)
+}
+
+func TestBrokerFanoutCreatesInstanceSubscription(t *testing.T) {
+	viper.Set("WS_FANOUT_TOPIC", "websocket.test")
+	defer viper.Set("WS_FANOUT_TOPIC", nil)
+
+	broker := pubsub.NewMemoryBroker()
+	defer broker.Close()
+
+	fanout, err := NewBrokerFanoutFromConfig(context.Background(), broker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fanout.subscriptionId == "" || fanout.subscriptionId == fanout.topic {
+		t.Fatalf("subscription %q is not one of the instance", fanout.subscriptionId)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	received := make(chan Publication, 1)
+	go fanout.Listen(ctx, func(publication Publication) {
+		received <- publication
+	})
+
+	publication := Publication{Topic: "game/1", Seq: 1, Event: []byte(`{}`)}
+	if err := fanout.Publish(ctx, publication); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case got := <-received:
+		if got.Topic != publication.Topic || got.Seq != publication.Seq {
+			t.Errorf("received %+v, want %+v", got, publication)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("publication not received")
+	}
+}
@@ -0,0 +1,276 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// the connections of a user to any topic, see IsOnline
+	onlineTopic = ""
+
+	presenceAttempts   = 3
+	presenceRetryDelay = time.Second
+)
+
+// PresenceChange is passed to the presence watchers when the first
+// connection of a user to a topic on any instance is registered or the last
+// one ends
+type PresenceChange struct {
+	Topic     string
+	UserId    uint64
+	Connected bool
+}
+
+type clientPresence struct {
+	userId uint64
+	topics map[string]bool
+}
+
+// Identify tells the hub which user the client is connected for, the topics
+// the client listens to from then on count towards the presence of the user.
+// The user is online until the client stops.
+func (hub *WebSocketNotificationHub) Identify(client *Client, userId uint64) {
+	hub.registrationMutex.Lock()
+	defer hub.registrationMutex.Unlock()
+
+	if _, ok := hub.clients[client]; ok {
+		return
+	}
+	hub.clients[client] = &clientPresence{userId: userId, topics: map[string]bool{}}
+	hub.online[userId]++
+	if hub.online[userId] == 1 {
+		hub.queuePresence(PresenceChange{Topic: onlineTopic, UserId: userId, Connected: true})
+	}
+
+	go func() {
+		<-client.Stopped()
+		hub.forget(client)
+	}()
+}
+
+// forget leaves the topics the client still listens to, its handler may
+// unregister them after the client stopped
+func (hub *WebSocketNotificationHub) forget(client *Client) {
+	hub.registrationMutex.Lock()
+	defer hub.registrationMutex.Unlock()
+
+	p := hub.clients[client]
+	if p == nil {
+		return
+	}
+	for topic := range p.topics {
+		hub.leave(topic, client)
+	}
+	delete(hub.clients, client)
+
+	hub.online[p.userId]--
+	if hub.online[p.userId] <= 0 {
+		delete(hub.online, p.userId)
+		hub.queuePresence(PresenceChange{Topic: onlineTopic, UserId: p.userId})
+	}
+}
+
+// must be called with the lock held
+func (hub *WebSocketNotificationHub) join(topic string, client *Client) {
+	p := hub.clients[client]
+	if p == nil || p.topics[topic] {
+		return
+	}
+	p.topics[topic] = true
+
+	if hub.topicUsers[topic] == nil {
+		hub.topicUsers[topic] = map[uint64]int{}
+	}
+	hub.topicUsers[topic][p.userId]++
+	if hub.topicUsers[topic][p.userId] == 1 {
+		hub.queuePresence(PresenceChange{Topic: topic, UserId: p.userId, Connected: true})
+	}
+}
+
+// must be called with the lock held
+func (hub *WebSocketNotificationHub) leave(topic string, client *Client) {
+	p := hub.clients[client]
+	if p == nil || !p.topics[topic] {
+		return
+	}
+	delete(p.topics, topic)
+
+	hub.topicUsers[topic][p.userId]--
+	if hub.topicUsers[topic][p.userId] > 0 {
+		return
+	}
+	delete(hub.topicUsers[topic], p.userId)
+	if len(hub.topicUsers[topic]) == 0 {
+		delete(hub.topicUsers, topic)
+	}
+	hub.queuePresence(PresenceChange{Topic: topic, UserId: p.userId})
+}
+
+// queuePresence passes a change of the connections of the instance to the
+// presence writer, which records them in order. Must be called with the
+// lock held.
+func (hub *WebSocketNotificationHub) queuePresence(change PresenceChange) {
+	hub.presenceQueue = append(hub.presenceQueue, change)
+	select {
+	case hub.presenceSignal <- struct{}{}:
+	default:
+	}
+}
+
+// writePresence records the queued changes in the presence store, the
+// watchers are told about the ones that changed the presence of a user
+func (hub *WebSocketNotificationHub) writePresence() {
+	for range hub.presenceSignal {
+		hub.registrationMutex.Lock()
+		queue := hub.presenceQueue
+		hub.presenceQueue = nil
+		store := hub.presence
+		hub.registrationMutex.Unlock()
+
+		for _, change := range queue {
+			changed, err := recordPresence(store, change)
+			if err != nil {
+				log.Warn().Err(err).Str("topic", change.Topic).Uint64("userId", change.UserId).Msg("[WEBSOCKET] Error recording presence")
+				continue
+			}
+			if changed {
+				hub.notifyPresence([]PresenceChange{change})
+			}
+		}
+	}
+}
+
+func recordPresence(store PresenceStore, change PresenceChange) (bool, error) {
+	var err error
+	for attempt := 1; attempt <= presenceAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(presenceRetryDelay)
+		}
+
+		var changed bool
+		if change.Connected {
+			changed, err = store.Connect(context.Background(), change.Topic, change.UserId)
+		} else {
+			changed, err = store.Disconnect(context.Background(), change.Topic, change.UserId)
+		}
+		if err == nil {
+			return changed, nil
+		}
+	}
+	return false, err
+}
+
+// UsePresence shares the presence of the users with the other instances
+// through the store and sends the heartbeats of the instance until the
+// context is done, by default presence is kept in memory. It has to be
+// called before clients connect.
+func (hub *WebSocketNotificationHub) UsePresence(ctx context.Context, store PresenceStore) {
+	hub.registrationMutex.Lock()
+	hub.presence = store
+	hub.registrationMutex.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(presenceHeartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			changes, err := store.Heartbeat(ctx)
+			hub.notifyPresence(changes)
+			if errors.Is(err, ErrPresenceExpired) {
+				log.Warn().Msg("[WEBSOCKET] Presence of the instance expired, recording it again")
+				hub.requeuePresence()
+			} else if err != nil {
+				log.Warn().Err(err).Msg("[WEBSOCKET] Error sending presence heartbeat")
+			}
+		}
+	}()
+}
+
+// requeuePresence records every connection of the instance again
+func (hub *WebSocketNotificationHub) requeuePresence() {
+	hub.registrationMutex.Lock()
+	defer hub.registrationMutex.Unlock()
+
+	for userId := range hub.online {
+		hub.queuePresence(PresenceChange{Topic: onlineTopic, UserId: userId, Connected: true})
+	}
+	for topic, users := range hub.topicUsers {
+		for userId := range users {
+			hub.queuePresence(PresenceChange{Topic: topic, UserId: userId, Connected: true})
+		}
+	}
+}
+
+// WatchPresence passes the presence changes of every topic to watch until
+// cancelled. Every change is passed on the instance that recorded it only,
+// outside the lock, so it may publish events.
+func (hub *WebSocketNotificationHub) WatchPresence(watch func(PresenceChange)) (cancel func()) {
+	hub.registrationMutex.Lock()
+	defer hub.registrationMutex.Unlock()
+
+	id := hub.nextWatcherId
+	hub.nextWatcherId++
+	hub.presenceWatchers[id] = watch
+
+	return func() {
+		hub.registrationMutex.Lock()
+		defer hub.registrationMutex.Unlock()
+
+		delete(hub.presenceWatchers, id)
+	}
+}
+
+func (hub *WebSocketNotificationHub) notifyPresence(changes []PresenceChange) {
+	if len(changes) == 0 {
+		return
+	}
+
+	hub.registrationMutex.RLock()
+	watchers := make([]func(PresenceChange), 0, len(hub.presenceWatchers))
+	for _, watch := range hub.presenceWatchers {
+		watchers = append(watchers, watch)
+	}
+	hub.registrationMutex.RUnlock()
+
+	for _, change := range changes {
+		if change.Topic == onlineTopic {
+			continue
+		}
+		for _, watch := range watchers {
+			watch(change)
+		}
+	}
+}
+
+// Online returns which of the users have a connection on any instance
+func (hub *WebSocketNotificationHub) Online(ctx context.Context, userIds []uint64) (map[uint64]bool, error) {
+	presence, err := hub.Presence(ctx, onlineTopic, userIds)
+	if err != nil {
+		return nil, err
+	}
+
+	online := map[uint64]bool{}
+	for userId, p := range presence {
+		online[userId] = p.Connected
+	}
+	return online, nil
+}
+
+// Presence returns whether the users listen to the topic on any instance
+func (hub *WebSocketNotificationHub) Presence(ctx context.Context, topic string, userIds []uint64) (map[uint64]Presence, error) {
+	hub.registrationMutex.RLock()
+	store := hub.presence
+	hub.registrationMutex.RUnlock()
+
+	return store.Presence(ctx, topic, userIds)
+}
@@ -0,0 +1,242 @@
+package ws
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrPresenceExpired is returned by a heartbeat when the instance was taken
+// for stopped, its connections have to be recorded again
+var ErrPresenceExpired = errors.New("presence of the instance expired")
+
+// PostgresPresence records the connections of every instance, the ones of
+// an instance count while it sends heartbeats. Changes of a user on a topic
+// are serialized with an advisory lock, so exactly one instance sees the
+// first connection or the last one.
+type PostgresPresence struct {
+	db       *gorm.DB
+	instance string
+}
+
+type presenceRow struct {
+	UserId    uint64
+	Connected bool
+	UpdatedAt int64
+	Live      bool
+	SeenAt    *int64
+}
+
+// NewPostgresPresence registers the instance, named after the host with a
+// random suffix
+func NewPostgresPresence(db *gorm.DB) (*PostgresPresence, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+
+	p := &PostgresPresence{db: db, instance: hostname + "-" + hex.EncodeToString(suffix)}
+	result := db.Exec("INSERT INTO websocket_instance (id, seen_at) VALUES (?, ?)", p.instance, time.Now().UTC().UnixMilli())
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return p, nil
+}
+
+func (p *PostgresPresence) Connect(ctx context.Context, topic string, userId uint64) (bool, error) {
+	return p.set(ctx, topic, userId, true)
+}
+
+func (p *PostgresPresence) Disconnect(ctx context.Context, topic string, userId uint64) (bool, error) {
+	return p.set(ctx, topic, userId, false)
+}
+
+// set records whether the instance has a connection of the user to the
+// topic, it reports whether that changed the presence of the user
+func (p *PostgresPresence) set(ctx context.Context, topic string, userId uint64, connected bool) (bool, error) {
+	var changed bool
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockPresence(tx, topic, userId); err != nil {
+			return err
+		}
+
+		var wasConnected bool
+		result := tx.Raw(`SELECT connected FROM websocket_presence
+			WHERE instance = ? AND topic = ? AND user_id = ?`, p.instance, topic, userId).Scan(&wasConnected)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		result = tx.Exec(`INSERT INTO websocket_presence (instance, topic, user_id, connected, updated_at) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (topic, user_id, instance) DO UPDATE SET connected = excluded.connected, updated_at = excluded.updated_at`,
+			p.instance, topic, userId, connected, time.Now().UTC().UnixMilli())
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if wasConnected == connected {
+			return nil
+		}
+		others, err := connectedElsewhere(tx, p.instance, topic, userId)
+		changed = others == 0
+		return err
+	})
+	return changed, err
+}
+
+func (p *PostgresPresence) Presence(ctx context.Context, topic string, userIds []uint64) (map[uint64]Presence, error) {
+	var rows []presenceRow
+	result := p.db.WithContext(ctx).Raw(`SELECT p.user_id, p.connected, p.updated_at, COALESCE(i.seen_at >= ?, false) AS live, i.seen_at
+		FROM websocket_presence p LEFT JOIN websocket_instance i ON i.id = p.instance
+		WHERE p.topic = ? AND p.user_id IN ?`, expiredBefore(), topic, userIds).Scan(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	presence := map[uint64]Presence{}
+	for _, row := range rows {
+		current := presence[row.UserId]
+		if current.Connected {
+			continue
+		}
+
+		leftAt := time.UnixMilli(row.UpdatedAt)
+		switch {
+		case row.Connected && row.Live:
+			presence[row.UserId] = Presence{Connected: true}
+			continue
+		case row.Connected && row.SeenAt != nil:
+			// the instance stopped
+			leftAt = time.UnixMilli(*row.SeenAt)
+		case row.Connected:
+			leftAt = time.Time{}
+		}
+
+		if leftAt.After(current.LeftAt) {
+			current.LeftAt = leftAt
+		}
+		presence[row.UserId] = current
+	}
+	return presence, nil
+}
+
+func (p *PostgresPresence) Heartbeat(ctx context.Context) ([]PresenceChange, error) {
+	db := p.db.WithContext(ctx)
+	now := time.Now().UTC().UnixMilli()
+
+	var expired error
+	result := db.Exec("UPDATE websocket_instance SET seen_at = ? WHERE id = ?", now, p.instance)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		result = db.Exec("INSERT INTO websocket_instance (id, seen_at) VALUES (?, ?)", p.instance, now)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		expired = ErrPresenceExpired
+	}
+
+	changes, err := p.dropStopped(ctx)
+	if err != nil {
+		return changes, err
+	}
+
+	before := time.Now().Add(-presenceRetention).UTC().UnixMilli()
+	result = db.Exec("DELETE FROM websocket_presence WHERE NOT connected AND updated_at < ?", before)
+	if result.Error != nil {
+		return changes, result.Error
+	}
+	return changes, expired
+}
+
+// dropStopped disconnects the users of the instances without heartbeat, any
+// instance may do it, the one updating a connection reports it
+func (p *PostgresPresence) dropStopped(ctx context.Context) ([]PresenceChange, error) {
+	db := p.db.WithContext(ctx)
+
+	var instances []struct {
+		Id     string
+		SeenAt int64
+	}
+	result := db.Raw("SELECT id, seen_at FROM websocket_instance WHERE seen_at < ?", expiredBefore()).Scan(&instances)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	var changes []PresenceChange
+	for _, instance := range instances {
+		var connections []struct {
+			Topic  string
+			UserId uint64
+		}
+		result := db.Raw("SELECT topic, user_id FROM websocket_presence WHERE instance = ? AND connected", instance.Id).Scan(&connections)
+		if result.Error != nil {
+			return changes, result.Error
+		}
+
+		for _, connection := range connections {
+			var dropped, last bool
+			err := db.Transaction(func(tx *gorm.DB) error {
+				if err := lockPresence(tx, connection.Topic, connection.UserId); err != nil {
+					return err
+				}
+
+				result := tx.Exec(`UPDATE websocket_presence SET connected = false, updated_at = ?
+					WHERE instance = ? AND topic = ? AND user_id = ? AND connected`,
+					instance.SeenAt, instance.Id, connection.Topic, connection.UserId)
+				if result.Error != nil || result.RowsAffected == 0 {
+					return result.Error
+				}
+
+				dropped = true
+				others, err := connectedElsewhere(tx, instance.Id, connection.Topic, connection.UserId)
+				last = others == 0
+				return err
+			})
+			if err != nil {
+				return changes, err
+			}
+			if dropped && last {
+				changes = append(changes, PresenceChange{Topic: connection.Topic, UserId: connection.UserId})
+			}
+		}
+
+		result = db.Exec("DELETE FROM websocket_instance WHERE id = ? AND seen_at = ?", instance.Id, instance.SeenAt)
+		if result.Error != nil {
+			return changes, result.Error
+		}
+	}
+	return changes, nil
+}
+
+// lockPresence holds the presence of the user on the topic until the
+// transaction ends
+func lockPresence(tx *gorm.DB, topic string, userId uint64) error {
+	key := topic + "/" + strconv.FormatUint(userId, 10)
+	return tx.Exec("SELECT pg_advisory_xact_lock(hashtextextended(?, 0))", key).Error
+}
+
+// connectedElsewhere counts the live instances other than the instance with
+// a connection of the user to the topic
+func connectedElsewhere(tx *gorm.DB, instance string, topic string, userId uint64) (int64, error) {
+	var others int64
+	result := tx.Raw(`SELECT count(*) FROM websocket_presence p JOIN websocket_instance i ON i.id = p.instance
+		WHERE p.topic = ? AND p.user_id = ? AND p.connected AND p.instance <> ? AND i.seen_at >= ?`,
+		topic, userId, instance, expiredBefore()).Scan(&others)
+	return others, result.Error
+}
+
+func expiredBefore() int64 {
+	return time.Now().Add(-presenceExpiry).UTC().UnixMilli()
+}
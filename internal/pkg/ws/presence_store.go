@@ -0,0 +1,147 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+const (
+	PresenceMemory   = "memory"
+	PresencePostgres = "postgres"
+
+	presenceHeartbeatInterval = 30 * time.Second
+	// an instance without heartbeat for that long stopped, its connections
+	// are dropped
+	presenceExpiry = 3 * presenceHeartbeatInterval
+	// how long the time a user left a topic is kept
+	presenceRetention = time.Hour
+)
+
+// PresenceStore shares the presence of users between instances. Hubs record
+// the first connection of a user to a topic on the instance and the last
+// one, in order, the store tells whether that changed the presence of the
+// user on any instance.
+type PresenceStore interface {
+	// Connect reports whether the user had no connection to the topic on any
+	// instance
+	Connect(ctx context.Context, topic string, userId uint64) (bool, error)
+	// Disconnect reports whether the user has no connection to the topic
+	// left on any instance
+	Disconnect(ctx context.Context, topic string, userId uint64) (bool, error)
+	// Presence returns the presence of the users on the topic, users who
+	// never connected are left out
+	Presence(ctx context.Context, topic string, userIds []uint64) (map[uint64]Presence, error)
+	// Heartbeat keeps the connections of the instance and drops those of the
+	// instances that stopped, it returns the users who lost their last
+	// connection to a topic with them
+	Heartbeat(ctx context.Context) ([]PresenceChange, error)
+}
+
+type Presence struct {
+	Connected bool
+	// LeftAt is when the last connection of a user who is not connected
+	// ended, zero when it is no longer known
+	LeftAt time.Time
+}
+
+// NewPresenceStoreFromConfig creates the presence store selected with
+// WS_PRESENCE. The instances share presence through postgres, the default
+// when WS_FANOUT connects instances.
+func NewPresenceStoreFromConfig(db *gorm.DB) (PresenceStore, error) {
+	presence := viper.GetString("WS_PRESENCE")
+	if presence == "" {
+		presence = PresenceMemory
+		if fanout := viper.GetString("WS_FANOUT"); fanout == FanoutPostgres || fanout == FanoutBroker {
+			presence = PresencePostgres
+		}
+	}
+
+	switch presence {
+	case PresenceMemory:
+		return NewMemoryPresence(), nil
+	case PresencePostgres:
+		return NewPostgresPresence(db)
+	default:
+		return nil, fmt.Errorf("unknown websocket presence store %s", presence)
+	}
+}
+
+// MemoryPresence keeps the presence of the hubs of one process
+type MemoryPresence struct {
+	mu          sync.Mutex
+	connections map[presenceKey]int
+	leftAt      map[presenceKey]time.Time
+}
+
+type presenceKey struct {
+	topic  string
+	userId uint64
+}
+
+func NewMemoryPresence() *MemoryPresence {
+	return &MemoryPresence{
+		connections: map[presenceKey]int{},
+		leftAt:      map[presenceKey]time.Time{},
+	}
+}
+
+func (p *MemoryPresence) Connect(_ context.Context, topic string, userId uint64) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := presenceKey{topic: topic, userId: userId}
+	p.connections[key]++
+	delete(p.leftAt, key)
+	return p.connections[key] == 1, nil
+}
+
+func (p *MemoryPresence) Disconnect(_ context.Context, topic string, userId uint64) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := presenceKey{topic: topic, userId: userId}
+	if p.connections[key] == 0 {
+		return false, nil
+	}
+
+	p.connections[key]--
+	if p.connections[key] > 0 {
+		return false, nil
+	}
+	delete(p.connections, key)
+
+	now := time.Now()
+	p.leftAt[key] = now
+	for key, leftAt := range p.leftAt {
+		if now.Sub(leftAt) > presenceRetention {
+			delete(p.leftAt, key)
+		}
+	}
+	return true, nil
+}
+
+func (p *MemoryPresence) Presence(_ context.Context, topic string, userIds []uint64) (map[uint64]Presence, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	presence := map[uint64]Presence{}
+	for _, userId := range userIds {
+		key := presenceKey{topic: topic, userId: userId}
+		if p.connections[key] > 0 {
+			presence[userId] = Presence{Connected: true}
+		} else if leftAt, ok := p.leftAt[key]; ok {
+			presence[userId] = Presence{LeftAt: leftAt}
+		}
+	}
+	return presence, nil
+}
+
+// Heartbeat has nothing to do, the hubs sharing a memory store stop together
+func (p *MemoryPresence) Heartbeat(context.Context) ([]PresenceChange, error) {
+	return nil, nil
+}
@@ -0,0 +1,132 @@
+package ws
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// presenceLog records the presence changes passed to the watchers of hubs
+type presenceLog struct {
+	mu      sync.Mutex
+	changes map[string][]PresenceChange
+}
+
+func (l *presenceLog) watch(hub *WebSocketNotificationHub, instance string) {
+	hub.WatchPresence(func(change PresenceChange) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		l.changes[instance] = append(l.changes[instance], change)
+	})
+}
+
+// wait waits until the watchers got the number of changes, they are passed
+// once the store recorded them
+func (l *presenceLog) wait(t *testing.T, count int) {
+	t.Helper()
+
+	deadline := time.Now().Add(testTimeout)
+	for time.Now().Before(deadline) {
+		if l.count() >= count {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("watchers got %d changes, want %d", l.count(), count)
+}
+
+func (l *presenceLog) count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	count := 0
+	for _, changes := range l.changes {
+		count += len(changes)
+	}
+	return count
+}
+
+// waitConnections waits until the store counts the connections of the user
+// to the topic, the hubs record presence in the background
+func waitConnections(t *testing.T, store *MemoryPresence, topic string, userId uint64, connections int) {
+	t.Helper()
+
+	deadline := time.Now().Add(testTimeout)
+	for time.Now().Before(deadline) {
+		store.mu.Lock()
+		n := store.connections[presenceKey{topic: topic, userId: userId}]
+		store.mu.Unlock()
+
+		if n == connections {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%s has not %d connections of user %d", topic, connections, userId)
+}
+
+func TestPresenceIsSharedBetweenHubs(t *testing.T) {
+	store := NewMemoryPresence()
+	changes := &presenceLog{changes: map[string][]PresenceChange{}}
+
+	hubA := NewHub(defaultSendQueueSize, CloseClient)
+	hubB := NewHub(defaultSendQueueSize, CloseClient)
+	for instance, hub := range map[string]*WebSocketNotificationHub{"a": hubA, "b": hubB} {
+		hub.presence = store
+		changes.watch(hub, instance)
+	}
+
+	clientA := startClient(newFakeTransport(), defaultSendQueueSize, CloseClient)
+	clientB := startClient(newFakeTransport(), defaultSendQueueSize, CloseClient)
+	hubA.Identify(clientA, 7)
+	hubB.Identify(clientB, 7)
+
+	hubA.RegisterListener("game/1", clientA)
+	waitConnections(t, store, "game/1", 7, 1)
+	hubB.RegisterListener("game/1", clientB)
+	waitConnections(t, store, "game/1", 7, 2)
+
+	// the user is still connected to the other instance
+	hubA.UnregisterListener("game/1", clientA)
+	waitConnections(t, store, "game/1", 7, 1)
+
+	clientB.Close()
+	waitStopped(t, clientB)
+	waitConnections(t, store, "game/1", 7, 0)
+	waitConnections(t, store, onlineTopic, 7, 1)
+	changes.wait(t, 2)
+
+	changes.mu.Lock()
+	defer changes.mu.Unlock()
+
+	want := map[string][]PresenceChange{
+		"a": {{Topic: "game/1", UserId: 7, Connected: true}},
+		"b": {{Topic: "game/1", UserId: 7, Connected: false}},
+	}
+	for instance, wantChanges := range want {
+		got := changes.changes[instance]
+		if len(got) != len(wantChanges) || got[0] != wantChanges[0] {
+			t.Errorf("instance %s passed %v, want %v", instance, got, wantChanges)
+		}
+	}
+}
+
+func TestPresenceWatchersSkipOnlineChanges(t *testing.T) {
+	hub := NewHub(defaultSendQueueSize, CloseClient)
+	store := hub.presence.(*MemoryPresence)
+	changes := &presenceLog{changes: map[string][]PresenceChange{}}
+	changes.watch(hub, "a")
+
+	client := startClient(newFakeTransport(), defaultSendQueueSize, CloseClient)
+	hub.Identify(client, 7)
+	waitConnections(t, store, onlineTopic, 7, 1)
+
+	client.Close()
+	waitStopped(t, client)
+	waitConnections(t, store, onlineTopic, 7, 0)
+
+	if n := changes.count(); n != 0 {
+		t.Errorf("watchers got %d changes of the online users", n)
+	}
+}
@@ -0,0 +1,70 @@
+package ws
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// NewSSEClient starts a Server-Sent Events stream on the response. Events are
+// written with their seq as id, so browsers resume with the Last-Event-ID
+// header, and comments keep idle streams open. The handler has to wait for
+// Stopped before returning.
+func (hub *WebSocketNotificationHub) NewSSEClient(w http.ResponseWriter) *Client {
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	t := sseTransport{w: w, rc: http.NewResponseController(w)}
+	t.rc.Flush()
+
+	return startClient(t, hub.sendQueueSize, hub.policy)
+}
+
+type sseTransport struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func (t sseTransport) write(m message, deadline time.Time) error {
+	var frame []byte
+	if m.seq != 0 {
+		frame = append(frame, "id: "+strconv.FormatUint(m.seq, 10)+"\n"...)
+	}
+	frame = append(frame, "data: "...)
+	frame = append(frame, bytes.ReplaceAll(m.data, []byte("\n"), []byte("\ndata: "))...)
+	frame = append(frame, "\n\n"...)
+	return t.writeFrame(frame, deadline)
+}
+
+func (t sseTransport) ping(deadline time.Time) error {
+	return t.writeFrame([]byte(": heartbeat\n\n"), deadline)
+}
+
+// close sends a close event with the websocket close code, clients reconnect
+// unless the stream was closed for good, e.g. because the token expired
+func (t sseTransport) close(code int, reason string, deadline time.Time) {
+	if code == websocket.CloseAbnormalClosure {
+		return
+	}
+	data, _ := json.Marshal(map[string]any{"code": code, "reason": reason})
+	t.writeFrame([]byte(fmt.Sprintf("event: close\ndata: %s\n\n", data)), deadline)
+}
+
+// writeFrame extends the write deadline of the connection, the stream
+// outlives the WriteTimeout of the server
+func (t sseTransport) writeFrame(frame []byte, deadline time.Time) error {
+	t.rc.SetWriteDeadline(deadline)
+	if _, err := t.w.Write(frame); err != nil {
+		return err
+	}
+	return t.rc.Flush()
+}
@@ -1,82 +1,332 @@
 package ws
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/jpillora/backoff"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/wsevent"
 	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultSendQueueSize = 64
+
+	// LobbyTopic broadcasts the games that are created or joined to every
+	// user
+	LobbyTopic = "lobby"
 )
 
 var singletonMutex sync.Mutex
 
+// WebSocketNotificationHub fans events out to the clients listening to a
+// topic. Publishing only queues the event on every client, so a slow client
+// never blocks the event handlers. With a fanout, events reach the clients
+// connected to any instance.
+//
+// Events are sent with their "topic" and numbered per topic with a "seq"
+// field, a client reconnecting with the last seq it received gets the events
+// it missed replayed first.
 type WebSocketNotificationHub struct {
-	registrationMutex sync.Mutex
-	listeners         map[string][]*websocket.Conn
+	registrationMutex sync.RWMutex
+	listeners         map[string]map[*Client]*listener
+	watchers          map[string]map[int]func(Publication)
+	nextWatcherId     int
+	fanout            Fanout
+	events            EventLog
+
+	// connections of the users of identified clients, see Identify, and
+	// the changes the presence writer has to record
+	clients          map[*Client]*clientPresence
+	online           map[uint64]int
+	topicUsers       map[string]map[uint64]int
+	presence         PresenceStore
+	presenceQueue    []PresenceChange
+	presenceSignal   chan struct{}
+	presenceWatchers map[int]func(PresenceChange)
+
+	sendQueueSize int
+	policy        SlowClientPolicy
 }
 
-func (hub *WebSocketNotificationHub) RegisterListener(topic string, conn *websocket.Conn) {
+type listener struct {
+	// live events are buffered while the missed ones are replayed
+	replaying bool
+	buffered  []Publication
+	// events up to replayedSeq were replayed
+	replayedSeq uint64
+}
+
+// NewClient starts the writer of a connection, the client has to be closed
+// once the connection is done
+func (hub *WebSocketNotificationHub) NewClient(conn *websocket.Conn) *Client {
+	return newClient(conn, hub.sendQueueSize, hub.policy)
+}
+
+func (hub *WebSocketNotificationHub) RegisterListener(topic string, client *Client) {
 	hub.registrationMutex.Lock()
 	defer hub.registrationMutex.Unlock()
 
+	hub.register(topic, client, &listener{})
+}
+
+// RegisterListenerFrom replays the events of the topic after lastSeq before
+// the live ones. A client that missed events the log no longer has gets a
+// RESYNC_REQUIRED event and has to reload its state.
+func (hub *WebSocketNotificationHub) RegisterListenerFrom(ctx context.Context, topic string, client *Client, lastSeq uint64) {
+	l := &listener{replaying: true}
+
+	hub.registrationMutex.Lock()
+	hub.register(topic, client, l)
+	events := hub.events
+	hub.registrationMutex.Unlock()
+
+	missed, topicSeq, err := events.Since(ctx, topic, lastSeq)
+	if err != nil {
+		log.Warn().Err(err).Msg("[WEBSOCKET] Error reading the event log")
+	}
+
+	gap := err != nil || lastSeq > topicSeq ||
+		(len(missed) == 0 && topicSeq > lastSeq) ||
+		(len(missed) > 0 && missed[0].Seq > lastSeq+1)
+	if gap {
+		resync := wsevent.Wrap(wsevent.ResyncRequired{Topic: topic, LastSeq: lastSeq, Seq: topicSeq})
+		resync.Topic = topic
+		client.SendJSON(resync)
+	}
+
+	replayedSeq := lastSeq
+	for _, publication := range missed {
+		client.enqueue(message{seq: publication.Seq, data: annotate(publication)})
+		replayedSeq = publication.Seq
+	}
+
+	hub.registrationMutex.Lock()
+	defer hub.registrationMutex.Unlock()
+
+	for _, publication := range l.buffered {
+		if publication.Seq == 0 || publication.Seq > replayedSeq {
+			client.enqueue(message{seq: publication.Seq, data: annotate(publication)})
+		}
+	}
+	l.buffered = nil
+	l.replaying = false
+	l.replayedSeq = replayedSeq
+}
+
+// must be called with the lock held
+func (hub *WebSocketNotificationHub) register(topic string, client *Client, l *listener) {
 	if hub.listeners[topic] == nil {
-		hub.listeners[topic] = []*websocket.Conn{conn}
-		return
+		hub.listeners[topic] = map[*Client]*listener{}
 	}
-	hub.listeners[topic] = append(hub.listeners[topic], conn)
+	hub.listeners[topic][client] = l
+	hub.join(topic, client)
 }
 
-func (hub *WebSocketNotificationHub) UnregisterListener(topic string, conn *websocket.Conn) {
+func (hub *WebSocketNotificationHub) UnregisterListener(topic string, client *Client) {
 	hub.registrationMutex.Lock()
 	defer hub.registrationMutex.Unlock()
 
-	if conn == nil {
+	delete(hub.listeners[topic], client)
+	if len(hub.listeners[topic]) == 0 {
+		delete(hub.listeners, topic)
+	}
+	hub.leave(topic, client)
+}
+
+// Publish sends the event in its envelope to the listeners of the topic
+func (hub *WebSocketNotificationHub) Publish(targetTopic string, event wsevent.Event) {
+	log.Info().Interface("targetTopic", targetTopic).Msg("[WEBSOCKET] Publishing to websocet topic")
+
+	data, err := json.Marshal(wsevent.Wrap(event))
+	if err != nil {
+		log.Warn().Err(err).Msg("[WEBSOCKET] Error encoding event")
 		return
 	}
-	connAddrToClose := conn.RemoteAddr()
+	publication := Publication{Topic: targetTopic, Event: data}
 
-	if len(hub.listeners[topic]) == 1 {
-		delete(hub.listeners, topic)
+	hub.registrationMutex.RLock()
+	fanout := hub.fanout
+	events := hub.events
+	hub.registrationMutex.RUnlock()
+
+	publication.Seq, err = events.Append(context.Background(), targetTopic, data)
+	if err != nil {
+		log.Warn().Err(err).Msg("[WEBSOCKET] Error adding event to the event log, publishing without seq")
+	}
+
+	if fanout == nil {
+		hub.deliver(publication)
 		return
 	}
 
-	var indexToDelete int
-	for i, listener := range hub.listeners[topic] {
-		connAddr := listener.RemoteAddr()
-		if connAddr == connAddrToClose {
-			indexToDelete = i
-			break
-		}
+	if err := fanout.Publish(context.Background(), publication); err != nil {
+		log.Warn().Err(err).Msg("[WEBSOCKET] Error publishing to fanout, delivering to local listeners only")
+		hub.deliver(publication)
 	}
+}
+
+// UseEventLog numbers and keeps the events with the event log, by default
+// the events of the instance are kept in memory
+func (hub *WebSocketNotificationHub) UseEventLog(events EventLog) {
+	hub.registrationMutex.Lock()
+	defer hub.registrationMutex.Unlock()
 
-	hub.listeners[topic] = append(hub.listeners[topic][:indexToDelete], hub.listeners[topic][indexToDelete+1:]...)
+	hub.events = events
 }
 
-func (hub *WebSocketNotificationHub) Publish(targetTopic string, event any) {
-	log.Info().Interface("targetTopic", targetTopic).Msg("[WEBSOCKET] Publishing to websocet topic")
-	for topic := range hub.listeners {
-		if topic == targetTopic {
-			for _, listener := range hub.listeners[topic] {
-				err := listener.WriteJSON(event)
-				if err != nil {
-					log.Warn().Msg("[WEBSOCKET] Error writing json to connection")
-				}
+// UseFanout publishes through the fanout and delivers its publications to
+// the local listeners until the context is done. Listening is restarted
+// with a backoff when the fanout fails.
+func (hub *WebSocketNotificationHub) UseFanout(ctx context.Context, fanout Fanout) {
+	hub.registrationMutex.Lock()
+	hub.fanout = fanout
+	hub.registrationMutex.Unlock()
+
+	go func() {
+		retry := &backoff.Backoff{Min: time.Second, Max: time.Minute, Factor: 2, Jitter: true}
+		for {
+			startedAt := time.Now()
+			err := fanout.Listen(ctx, hub.deliver)
+			if ctx.Err() != nil {
+				return
+			}
+			if time.Since(startedAt) > retry.Max {
+				retry.Reset()
+			}
+
+			log.Warn().Err(err).Msg("[WEBSOCKET] Fanout stopped, listening again")
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retry.Duration()):
 			}
-			break
+		}
+	}()
+}
+
+// Watch passes the publications of the topic to watch until cancelled. It
+// is called outside the lock, so it may register listeners.
+func (hub *WebSocketNotificationHub) Watch(topic string, watch func(Publication)) (cancel func()) {
+	hub.registrationMutex.Lock()
+	defer hub.registrationMutex.Unlock()
+
+	id := hub.nextWatcherId
+	hub.nextWatcherId++
+	if hub.watchers[topic] == nil {
+		hub.watchers[topic] = map[int]func(Publication){}
+	}
+	hub.watchers[topic][id] = watch
+
+	return func() {
+		hub.registrationMutex.Lock()
+		defer hub.registrationMutex.Unlock()
+
+		delete(hub.watchers[topic], id)
+		if len(hub.watchers[topic]) == 0 {
+			delete(hub.watchers, topic)
 		}
 	}
 }
 
+func (hub *WebSocketNotificationHub) deliver(publication Publication) {
+	hub.registrationMutex.Lock()
+
+	m := message{seq: publication.Seq, data: annotate(publication)}
+	for client, l := range hub.listeners[publication.Topic] {
+		switch {
+		case l.replaying:
+			l.buffered = append(l.buffered, publication)
+		case publication.Seq != 0 && publication.Seq <= l.replayedSeq:
+			// replayed already
+		default:
+			client.enqueue(m)
+		}
+	}
+
+	watchers := make([]func(Publication), 0, len(hub.watchers[publication.Topic]))
+	for _, watch := range hub.watchers[publication.Topic] {
+		watchers = append(watchers, watch)
+	}
+	hub.registrationMutex.Unlock()
+
+	for _, watch := range watchers {
+		watch(publication)
+	}
+}
+
+// annotate adds the topic and seq fields to the event object, clients
+// listening to several topics on one connection tell the events apart by
+// topic
+func annotate(publication Publication) []byte {
+	event := publication.Event
+	if len(event) < 2 || event[0] != '{' {
+		return event
+	}
+
+	topic, err := json.Marshal(publication.Topic)
+	if err != nil {
+		return event
+	}
+
+	fields := append([]byte(`{"topic":`), topic...)
+	if publication.Seq != 0 {
+		fields = append(fields, `,"seq":`+strconv.FormatUint(publication.Seq, 10)...)
+	}
+	if len(bytes.TrimSpace(event[1:])) > 1 {
+		fields = append(fields, ',')
+	}
+	return append(fields, event[1:]...)
+}
+
 var notificationHubSingleton *WebSocketNotificationHub
 
+// NewNotificationHub returns the hub of the instance. It reads
+// WS_SEND_QUEUE_SIZE, the number of messages queued per client, and
+// WS_SLOW_CLIENT_POLICY, drop or close (default).
 func NewNotificationHub() *WebSocketNotificationHub {
 	singletonMutex.Lock()
 	defer singletonMutex.Unlock()
 
 	if notificationHubSingleton == nil {
-		notificationHubSingleton = &WebSocketNotificationHub{
-			listeners: make(map[string][]*websocket.Conn),
+		sendQueueSize := viper.GetInt("WS_SEND_QUEUE_SIZE")
+		if sendQueueSize <= 0 {
+			sendQueueSize = defaultSendQueueSize
+		}
+
+		policy := SlowClientPolicy(viper.GetString("WS_SLOW_CLIENT_POLICY"))
+		if policy != DropMessages {
+			policy = CloseClient
 		}
+
+		notificationHubSingleton = NewHub(sendQueueSize, policy)
 	}
 
 	return notificationHubSingleton
 }
+
+// NewHub creates a hub besides the one of the instance, e.g. to run several
+// hubs connected with a MemoryFanout
+func NewHub(sendQueueSize int, policy SlowClientPolicy) *WebSocketNotificationHub {
+	hub := &WebSocketNotificationHub{
+		listeners:        make(map[string]map[*Client]*listener),
+		watchers:         make(map[string]map[int]func(Publication)),
+		events:           NewMemoryEventLog(defaultEventLogSize, defaultEventLogRetention),
+		clients:          make(map[*Client]*clientPresence),
+		online:           make(map[uint64]int),
+		topicUsers:       make(map[string]map[uint64]int),
+		presence:         NewMemoryPresence(),
+		presenceSignal:   make(chan struct{}, 1),
+		presenceWatchers: make(map[int]func(PresenceChange)),
+		sendQueueSize:    sendQueueSize,
+		policy:           policy,
+	}
+	go hub.writePresence()
+	return hub
+}
@@ -0,0 +1,189 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/wsevent"
+)
+
+func testEvent(i int) wsevent.Event {
+	return wsevent.Emote{GameId: 1, UserId: uint64(i), Emote: "wave"}
+}
+
+// seqs returns the seq of every written message, after closing the client
+func seqs(t *testing.T, client *Client, transport *fakeTransport) []uint64 {
+	t.Helper()
+
+	client.Close()
+	waitStopped(t, client)
+
+	var seqs []uint64
+	for _, m := range transport.written() {
+		seqs = append(seqs, m.seq)
+	}
+	return seqs
+}
+
+func TestConcurrentPublishToOneClient(t *testing.T) {
+	const publishers, events = 8, 50
+
+	hub := NewHub(publishers*events, CloseClient)
+	transport := newFakeTransport()
+	client := startClient(transport, hub.sendQueueSize, hub.policy)
+	hub.RegisterListener("game/1", client)
+
+	var wg sync.WaitGroup
+	for p := 0; p < publishers; p++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < events; i++ {
+				hub.Publish("game/1", testEvent(i))
+			}
+		}()
+	}
+	wg.Wait()
+
+	received := map[uint64]bool{}
+	for _, seq := range seqs(t, client, transport) {
+		if received[seq] {
+			t.Errorf("event %d received twice", seq)
+		}
+		received[seq] = true
+	}
+	for seq := uint64(1); seq <= publishers*events; seq++ {
+		if !received[seq] {
+			t.Errorf("event %d not received", seq)
+		}
+	}
+}
+
+func TestUnregisterListenerKeepsOtherClients(t *testing.T) {
+	hub := NewHub(defaultSendQueueSize, CloseClient)
+
+	transportA, transportB := newFakeTransport(), newFakeTransport()
+	clientA := startClient(transportA, hub.sendQueueSize, hub.policy)
+	clientB := startClient(transportB, hub.sendQueueSize, hub.policy)
+	hub.RegisterListener("game/1", clientA)
+	hub.RegisterListener("game/1", clientB)
+
+	hub.Publish("game/1", testEvent(1))
+	hub.UnregisterListener("game/1", clientA)
+	hub.Publish("game/1", testEvent(2))
+
+	if got := seqs(t, clientA, transportA); len(got) != 1 || got[0] != 1 {
+		t.Errorf("unregistered client received %v, want [1]", got)
+	}
+	if got := seqs(t, clientB, transportB); len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("registered client received %v, want [1 2]", got)
+	}
+}
+
+// blockingEventLog lets events be published while a listener is replaying,
+// Since waits for release. With readFirst, the events are read before
+// waiting, so the ones published meanwhile are not part of the replay.
+type blockingEventLog struct {
+	*MemoryEventLog
+	readFirst bool
+	reading   chan struct{}
+	release   chan struct{}
+}
+
+func (l *blockingEventLog) Since(ctx context.Context, topic string, seq uint64) ([]Publication, uint64, error) {
+	if l.readFirst {
+		events, topicSeq, err := l.MemoryEventLog.Since(ctx, topic, seq)
+		close(l.reading)
+		<-l.release
+		return events, topicSeq, err
+	}
+
+	close(l.reading)
+	<-l.release
+	return l.MemoryEventLog.Since(ctx, topic, seq)
+}
+
+func TestRegisterListenerFromBuffersLiveEvents(t *testing.T) {
+	for _, readFirst := range []bool{true, false} {
+		name := "published after the replay was read"
+		if !readFirst {
+			name = "published before the replay was read"
+		}
+
+		t.Run(name, func(t *testing.T) {
+			hub := NewHub(defaultSendQueueSize, CloseClient)
+			events := &blockingEventLog{
+				MemoryEventLog: NewMemoryEventLog(defaultEventLogSize, defaultEventLogRetention),
+				readFirst:      readFirst,
+				reading:        make(chan struct{}),
+				release:        make(chan struct{}),
+			}
+			hub.UseEventLog(events)
+
+			for i := 1; i <= 3; i++ {
+				hub.Publish("game/1", testEvent(i))
+			}
+
+			transport := newFakeTransport()
+			client := startClient(transport, hub.sendQueueSize, hub.policy)
+			registered := make(chan struct{})
+			go func() {
+				defer close(registered)
+				hub.RegisterListenerFrom(context.Background(), "game/1", client, 1)
+			}()
+
+			<-events.reading
+			hub.Publish("game/1", testEvent(4))
+			hub.Publish("game/1", testEvent(5))
+			close(events.release)
+			<-registered
+
+			hub.Publish("game/1", testEvent(6))
+
+			got := seqs(t, client, transport)
+			want := []uint64{2, 3, 4, 5, 6}
+			if len(got) != len(want) {
+				t.Fatalf("received %v, want %v", got, want)
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("received %v, want %v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRegisterListenerFromRequiresResync(t *testing.T) {
+	hub := NewHub(defaultSendQueueSize, CloseClient)
+	hub.UseEventLog(NewMemoryEventLog(2, defaultEventLogRetention))
+	for i := 1; i <= 4; i++ {
+		hub.Publish("game/1", testEvent(i))
+	}
+
+	transport := newFakeTransport()
+	client := startClient(transport, hub.sendQueueSize, hub.policy)
+	hub.RegisterListenerFrom(context.Background(), "game/1", client, 1)
+
+	client.Close()
+	waitStopped(t, client)
+	writes := transport.written()
+	if len(writes) != 3 {
+		t.Fatalf("written %d messages, want a resync and 2 events", len(writes))
+	}
+
+	var resync struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(writes[0].data, &resync); err != nil {
+		t.Fatal(err)
+	}
+	if resync.Type != (wsevent.ResyncRequired{}).EventType() {
+		t.Errorf("first message is %s, want a resync", resync.Type)
+	}
+	if writes[1].seq != 3 || writes[2].seq != 4 {
+		t.Errorf("replayed %d and %d, want 3 and 4", writes[1].seq, writes[2].seq)
+	}
+}
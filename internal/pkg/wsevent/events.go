@@ -0,0 +1,174 @@
+package wsevent
+
+import (
+	"encoding/json"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/money"
+)
+
+// Ids are database ids, gameId is never the Flow id of a game and user ids
+// are never wallet addresses.
+
+type GameCreated struct {
+	GameId     uint64       `json:"gameId"`
+	FlowGameId uint64       `json:"flowGameId"`
+	OwnerId    uint64       `json:"ownerId"`
+	Stake      money.Amount `json:"stake"`
+}
+
+func (GameCreated) EventType() string {
+	return "GAME_CREATED"
+}
+
+type ChallengerJoined struct {
+	GameId         uint64 `json:"gameId"`
+	OwnerId        uint64 `json:"ownerId"`
+	ChallengerId   uint64 `json:"challengerId"`
+	ChallengerName string `json:"challengerName"`
+	Turn           uint64 `json:"turn"`
+	GameStatus     string `json:"gameStatus"`
+}
+
+func (ChallengerJoined) EventType() string {
+	return "CHALLENGER_JOINED"
+}
+
+type MoveDone struct {
+	GameId uint64 `json:"gameId"`
+	UserId uint64 `json:"userId"`
+	Turn   uint64 `json:"turn"`
+	IsHit  bool   `json:"isHit"`
+	X      uint   `json:"x"`
+	Y      uint   `json:"y"`
+}
+
+func (MoveDone) EventType() string {
+	return "MOVE_DONE"
+}
+
+type GameOver struct {
+	GameId        uint64       `json:"gameId"`
+	WinnerId      uint64       `json:"winnerId"`
+	WinnerAddress string       `json:"winnerAddress"`
+	Stake         money.Amount `json:"stake"`
+}
+
+func (GameOver) EventType() string {
+	return "GAME_OVER"
+}
+
+type Emote struct {
+	GameId uint64 `json:"gameId"`
+	UserId uint64 `json:"userId"`
+	Emote  string `json:"emote"`
+}
+
+func (Emote) EventType() string {
+	return "EMOTE"
+}
+
+// CommandFailed is published to the topic the blockchain command referenced
+type CommandFailed struct {
+	CommandId   string `json:"commandId"`
+	CommandType string `json:"commandType"`
+	Error       string `json:"error"`
+}
+
+func (CommandFailed) EventType() string {
+	return "COMMAND_FAILED"
+}
+
+type AccountCreated struct {
+	UserId                   uint64  `json:"userId"`
+	Email                    string  `json:"email"`
+	Username                 string  `json:"username"`
+	CustodialWalletAddress   string  `json:"custodialWalletAddress"`
+	SelfCustodyWalletAddress *string `json:"selfCustodyWalletAddress"`
+}
+
+func (AccountCreated) EventType() string {
+	return "ACCOUNT_CREATED"
+}
+
+// OpponentDisconnected tells the players that a player of a game that is
+// played left the game topic, a turn of the player times out once the grace
+// period is over, at GraceUntil at the earliest
+type OpponentDisconnected struct {
+	GameId     uint64 `json:"gameId"`
+	UserId     uint64 `json:"userId"`
+	GraceUntil int64  `json:"graceUntil"`
+}
+
+func (OpponentDisconnected) EventType() string {
+	return "OPPONENT_DISCONNECTED"
+}
+
+type OpponentReconnected struct {
+	GameId uint64 `json:"gameId"`
+	UserId uint64 `json:"userId"`
+}
+
+func (OpponentReconnected) EventType() string {
+	return "OPPONENT_RECONNECTED"
+}
+
+// TurnTimedOut tells the players that the player of the turn did not move
+// before the deadline, the turn timeout or the end of the grace period of a
+// player who left the game
+type TurnTimedOut struct {
+	GameId   uint64 `json:"gameId"`
+	UserId   uint64 `json:"userId"`
+	Turn     uint64 `json:"turn"`
+	Deadline int64  `json:"deadline"`
+}
+
+func (TurnTimedOut) EventType() string {
+	return "TURN_TIMED_OUT"
+}
+
+// NftMinted is the payload of NFT_MINTED notifications
+type NftMinted struct {
+	NftId   uint64 `json:"nftId"`
+	FlowId  uint64 `json:"flowId"`
+	BlockId uint64 `json:"blockId"`
+	Name    string `json:"name"`
+}
+
+func (NftMinted) EventType() string {
+	return "NFT_MINTED"
+}
+
+// Notification is a stored notification, its payload is the payload of the
+// event of the same name, e.g. MOVE_DONE for OPPONENT_MOVED
+type Notification struct {
+	Id        uint64          `json:"id"`
+	UserId    uint64          `json:"userId"`
+	Type      string          `json:"type"`
+	Reference string          `json:"reference"`
+	Payload   json.RawMessage `json:"payload"`
+	ReadAt    *int64          `json:"readAt"`
+	CreatedAt int64           `json:"createdAt"`
+}
+
+func (Notification) EventType() string {
+	return "NOTIFICATION"
+}
+
+// ResyncRequired tells a reconnecting client that events it missed are no
+// longer kept, it has to reload the state of the topic
+type ResyncRequired struct {
+	Topic   string `json:"topic"`
+	LastSeq uint64 `json:"lastSeq"`
+	Seq     uint64 `json:"seq"`
+}
+
+func (ResyncRequired) EventType() string {
+	return "RESYNC_REQUIRED"
+}
+
+// Authenticated acknowledges the AUTH frame of a connection
+type Authenticated struct{}
+
+func (Authenticated) EventType() string {
+	return "AUTHENTICATED"
+}
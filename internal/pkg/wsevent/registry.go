@@ -0,0 +1,127 @@
+package wsevent
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Event is the payload of a websocket event
+type Event interface {
+	EventType() string
+}
+
+// Envelope is the message a client receives for an event. Topic and Seq are
+// added by the hub when the event is delivered.
+type Envelope struct {
+	Type      string `json:"type"`
+	Version   int    `json:"version"`
+	Topic     string `json:"topic,omitempty"`
+	Seq       uint64 `json:"seq,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+	Payload   Event  `json:"payload"`
+}
+
+// Definition registers an event type. Version is bumped whenever the payload
+// changes in a way existing clients cannot read.
+type Definition struct {
+	Version int
+	// Topics are the topics the event is published to, {name} stands for
+	// a part of the topic
+	Topics      []string
+	Description string
+	// Payload is the zero value of the payload struct
+	Payload Event
+}
+
+var Catalogue = []Definition{
+	{1, []string{"game/{gameId}", "lobby"}, "The game was created on-chain and waits for a challenger", GameCreated{}},
+	{1, []string{"game/{gameId}", "lobby"}, "A challenger joined the game, which is now played", ChallengerJoined{}},
+	{1, []string{"game/{gameId}"}, "A player made a move", MoveDone{}},
+	{1, []string{"game/{gameId}"}, "The game is over, the winner received the stakes", GameOver{}},
+	{1, []string{"game/{gameId}"}, "A player sent an emote", Emote{}},
+	{1, []string{"game/{gameId}"}, "A blockchain command of the game failed", CommandFailed{}},
+	{1, []string{"game/{gameId}"}, "The last connection of a player to the game on any instance was closed, userId is the player who left", OpponentDisconnected{}},
+	{1, []string{"game/{gameId}"}, "A player who left the game connected to it again", OpponentReconnected{}},
+	{1, []string{"game/{gameId}"}, "The player of the turn did not move before the deadline, the game goes on", TurnTimedOut{}},
+	{1, []string{"registration/{email}"}, "The custodial account of the user was created", AccountCreated{}},
+	{1, nil, "Payload of NFT_MINTED notifications, it is not published on its own", NftMinted{}},
+	{1, []string{"notifications/{userId}"}, "A notification was stored for the user", Notification{}},
+	{1, []string{"{topic}"}, "Events the client missed are no longer kept, the client has to reload the state of the topic", ResyncRequired{}},
+	{1, nil, "The connection was authenticated with an AUTH frame", Authenticated{}},
+}
+
+var definitions = map[string]Definition{}
+
+func init() {
+	for _, definition := range Catalogue {
+		eventType := definition.Payload.EventType()
+		if _, ok := definitions[eventType]; ok {
+			panic(fmt.Sprintf("websocket event %s is registered twice", eventType))
+		}
+		definitions[eventType] = definition
+	}
+}
+
+func (d Definition) Type() string {
+	return d.Payload.EventType()
+}
+
+// Lookup returns the definition of the event type
+func Lookup(eventType string) (Definition, bool) {
+	definition, ok := definitions[eventType]
+	return definition, ok
+}
+
+// Wrap puts the event in an envelope with the version of its definition,
+// events missing from the catalogue are a programming error
+func Wrap(event Event) Envelope {
+	definition, ok := definitions[event.EventType()]
+	if !ok {
+		panic(fmt.Sprintf("websocket event %s is not registered", event.EventType()))
+	}
+
+	return Envelope{
+		Type:      event.EventType(),
+		Version:   definition.Version,
+		Timestamp: time.Now().UTC().UnixMilli(),
+		Payload:   event,
+	}
+}
+
+// Decode reads an envelope with the payload struct of its type
+func Decode(data []byte) (*Envelope, error) {
+	var raw struct {
+		Type      string          `json:"type"`
+		Version   int             `json:"version"`
+		Topic     string          `json:"topic"`
+		Seq       uint64          `json:"seq"`
+		Timestamp int64           `json:"timestamp"`
+		Payload   json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	definition, ok := definitions[raw.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown websocket event %s", raw.Type)
+	}
+
+	payload := reflect.New(reflect.TypeOf(definition.Payload))
+	if len(raw.Payload) > 0 {
+		if err := json.Unmarshal(raw.Payload, payload.Interface()); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Envelope{
+		Type:      raw.Type,
+		Version:   raw.Version,
+		Topic:     raw.Topic,
+		Seq:       raw.Seq,
+		Timestamp: raw.Timestamp,
+		Payload:   payload.Elem().Interface().(Event),
+	}, nil
+}
@@ -5,22 +5,26 @@ import (
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/middleware"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/reject"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/utils"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/ws"
 	"gorm.io/gorm"
 	"net/http"
 )
 
 type profileHandler struct {
-	profile *ProfileService
+	profile         *ProfileService
+	notificationHub *ws.WebSocketNotificationHub
 }
 
 func RegisterRoutes(rg *gin.RouterGroup, db *gorm.DB) {
 	handler := profileHandler{
-		profile: &ProfileService{Db: db},
+		profile:         &ProfileService{Db: db},
+		notificationHub: ws.NewNotificationHub(),
 	}
 
 	routes := rg.Group("/profile")
 	routes.GET("", middleware.VerifyAuthToken, handler.getProfile)
 	routes.PUT("/blocks", middleware.VerifyAuthToken, handler.activateBlock)
+	routes.GET("/friends/online", middleware.VerifyAuthToken, handler.getOnlineFriends)
 }
 
 func (h profileHandler) getProfile(c *gin.Context) {
@@ -62,3 +66,34 @@ func (h profileHandler) activateBlock(c *gin.Context) {
 
 	c.JSON(http.StatusOK, profile)
 }
+
+// getOnlineFriends returns the users the user played against who are
+// connected
+func (h profileHandler) getOnlineFriends(c *gin.Context) {
+	friends, err := h.profile.findFriends(utils.GetUserEmail(c))
+	if err != nil {
+		c.JSON(err.Problem.Status, err.Problem)
+		return
+	}
+
+	friendIds := make([]uint64, 0, len(friends))
+	for _, friend := range friends {
+		friendIds = append(friendIds, friend.Id)
+	}
+
+	online, presenceErr := h.notificationHub.Online(c.Request.Context(), friendIds)
+	if presenceErr != nil {
+		problem := reject.UnexpectedProblem(presenceErr)
+		c.JSON(problem.Status, problem)
+		return
+	}
+
+	onlineFriends := []Friend{}
+	for _, friend := range friends {
+		if online[friend.Id] {
+			onlineFriends = append(onlineFriends, friend)
+		}
+	}
+
+	c.JSON(http.StatusOK, onlineFriends)
+}
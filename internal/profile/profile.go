@@ -18,3 +18,9 @@ type UserInventoryBlock struct {
 	ColorHex string `json:"colorHex"`
 	Active   bool   `json:"active"`
 }
+
+// Friend is a user the user played a game against
+type Friend struct {
+	Id       uint64 `json:"id"`
+	Username string `json:"username"`
+}
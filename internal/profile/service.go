@@ -197,3 +197,26 @@ func (s *ProfileService) activateBlocks(userEmail string, body ActivateBlocksReq
 
 	return nil
 }
+
+// findFriends returns the users the user played a game against
+func (s *ProfileService) findFriends(email string) ([]Friend, *reject.ProblemWithTrace) {
+	friends := []Friend{}
+	result := s.Db.Raw(`
+		SELECT DISTINCT friend.id, friend.username
+		FROM game
+		INNER JOIN battleblocks_user AS me
+			ON me.email = ? AND me.id IN (game.owner_id, game.challenger_id)
+		INNER JOIN battleblocks_user AS friend
+			ON friend.id IN (game.owner_id, game.challenger_id) AND friend.id <> me.id
+		ORDER BY friend.username
+	`, email).Scan(&friends)
+
+	if result.Error != nil {
+		return nil, &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(result.Error),
+			Cause:   result.Error,
+		}
+	}
+
+	return friends, nil
+}
@@ -0,0 +1,222 @@
+package reconcile
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/blockchain"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/flowclient"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/model"
+	"gorm.io/gorm"
+)
+
+func (r *Reconciler) check(tx *gorm.DB, game model.Game, chain *flowclient.GameState) ([]finding, error) {
+	if game.FlowId == nil {
+		return r.checkCreation(tx, game)
+	}
+
+	if chain == nil {
+		return []finding{{
+			kind:   model.DiscrepancyMissingOnChain,
+			detail: fmt.Sprintf("game with flow id %d does not exist on-chain", *game.FlowId),
+		}}, nil
+	}
+
+	switch chain.Status {
+	case flowclient.GameWaitingForChallenger:
+		if game.GameStatus != model.GameCreated {
+			return []finding{{
+				kind:   model.DiscrepancyStatusMismatch,
+				detail: fmt.Sprintf("game is %s but waits for a challenger on-chain", game.GameStatus),
+			}}, nil
+		}
+		return nil, nil
+
+	case flowclient.GamePlaying:
+		if game.Turn == nil || game.ChallengerId == nil {
+			return r.checkJoin(tx, game, chain)
+		}
+		return checkTurn(game, chain), nil
+
+	case flowclient.GameFinished:
+		return r.checkGameOver(tx, game, chain)
+
+	default:
+		return []finding{{
+			kind:   model.DiscrepancyStatusMismatch,
+			detail: fmt.Sprintf("unknown on-chain game state %d", chain.Status),
+		}}, nil
+	}
+}
+
+// checkCreation looks at the GAME_CREATE command of a game without flow id
+func (r *Reconciler) checkCreation(tx *gorm.DB, game model.Game) ([]finding, error) {
+	var command model.BlockchainCommand
+	result := tx.
+		Where("type = ? AND reference = ?", blockchain.CommandGameCreate, gameReference(game.Id)).
+		Order("created_at DESC").
+		Limit(1).
+		Find(&command)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	pendingSince := time.Now().Add(-r.pendingTimeout).UnixMilli()
+
+	switch {
+	case result.RowsAffected == 0:
+		if game.TimeCreated > pendingSince {
+			return nil, nil
+		}
+		return []finding{{
+			kind:   model.DiscrepancyCreationPending,
+			detail: "no GAME_CREATE command was dispatched",
+		}}, nil
+
+	case command.Status == model.CommandFailed:
+		return []finding{{
+			kind:   model.DiscrepancyCreationFailed,
+			detail: fmt.Sprintf("GAME_CREATE command %s failed, game cancelled and stakes released", command.Id),
+			repair: func(tx *gorm.DB) error {
+				err := tx.Model(&model.Game{}).
+					Where("id = ?", game.Id).
+					Update("game_status", model.GameCancelled).Error
+				if err != nil {
+					return err
+				}
+				return r.ledger.ReleaseGame(tx, game.Id)
+			},
+		}}, nil
+
+	case command.Status == model.CommandSealed:
+		return []finding{{
+			kind:   model.DiscrepancyCreatedEventMissing,
+			detail: fmt.Sprintf("GAME_CREATE command %s is sealed but its GameCreated event was not applied", command.Id),
+		}}, nil
+
+	case command.CreatedAt < pendingSince:
+		return []finding{{
+			kind:   model.DiscrepancyCreationPending,
+			detail: fmt.Sprintf("GAME_CREATE command %s is %s since %s", command.Id, command.Status, time.UnixMilli(command.CreatedAt).UTC().Format(time.RFC3339)),
+		}}, nil
+	}
+
+	return nil, nil
+}
+
+// checkJoin repairs a game whose ChallengerJoined event was lost
+func (r *Reconciler) checkJoin(tx *gorm.DB, game model.Game, chain *flowclient.GameState) ([]finding, error) {
+	challenger, err := userByAddress(tx, chain.PlayerB)
+	if err != nil {
+		return nil, err
+	}
+
+	if challenger == nil {
+		return []finding{{
+			kind:   model.DiscrepancyJoinMissing,
+			detail: fmt.Sprintf("challenger %s joined on-chain but has no account", chain.PlayerB),
+		}}, nil
+	}
+
+	return []finding{{
+		kind:   model.DiscrepancyJoinMissing,
+		detail: fmt.Sprintf("challenger %d joined on-chain, turn set to %d", challenger.Id, chain.Turn),
+		repair: func(tx *gorm.DB) error {
+			return tx.Model(&model.Game{}).
+				Where("id = ?", game.Id).
+				Updates(map[string]any{
+					"challenger_id": challenger.Id,
+					"game_status":   model.GamePlaying,
+					"turn":          chain.Turn,
+				}).Error
+		},
+	}}, nil
+}
+
+// checkTurn reports a turn that differs from the chain. A turn behind is not
+// repaired: the Moved events in between may still be retried as out of
+// order, setting the turn would make them stale and lose their moves.
+func checkTurn(game model.Game, chain *flowclient.GameState) []finding {
+	switch {
+	case *game.Turn < chain.Turn:
+		return []finding{{
+			kind:   model.DiscrepancyTurnBehind,
+			detail: fmt.Sprintf("turn %d is behind the on-chain turn %d", *game.Turn, chain.Turn),
+		}}
+	case *game.Turn > chain.Turn:
+		return []finding{{
+			kind:   model.DiscrepancyTurnAhead,
+			detail: fmt.Sprintf("turn %d is ahead of the on-chain turn %d", *game.Turn, chain.Turn),
+		}}
+	}
+	return nil
+}
+
+// checkGameOver repairs a game whose GameOver event was lost
+func (r *Reconciler) checkGameOver(tx *gorm.DB, game model.Game, chain *flowclient.GameState) ([]finding, error) {
+	winner, err := userByAddress(tx, chain.Winner)
+	if err != nil {
+		return nil, err
+	}
+
+	if winner == nil {
+		return []finding{{
+			kind:   model.DiscrepancyGameOverMissing,
+			detail: fmt.Sprintf("game is over on-chain but winner %s has no account", chain.Winner),
+		}}, nil
+	}
+
+	updates := map[string]any{
+		"winner_id":   winner.Id,
+		"game_status": model.GameFinished,
+		"turn":        chain.Turn,
+	}
+	if game.ChallengerId == nil {
+		challenger, err := userByAddress(tx, chain.PlayerB)
+		if err != nil {
+			return nil, err
+		}
+		if challenger != nil {
+			updates["challenger_id"] = challenger.Id
+		}
+	}
+
+	return []finding{{
+		kind:   model.DiscrepancyGameOverMissing,
+		detail: fmt.Sprintf("game is over on-chain, winner %d set and stakes settled", winner.Id),
+		repair: func(tx *gorm.DB) error {
+			err := tx.Model(&model.Game{}).
+				Where("id = ?", game.Id).
+				Updates(updates).Error
+			if err != nil {
+				return err
+			}
+			return r.ledger.SettleGame(tx, game.Id)
+		},
+	}}, nil
+}
+
+func userByAddress(tx *gorm.DB, address string) (*model.User, error) {
+	if address == "" {
+		return nil, nil
+	}
+
+	var user model.User
+	result := tx.Raw(`SELECT bu.* FROM battleblocks_user bu
+		LEFT JOIN custodial_wallet cw ON bu.custodial_wallet_id = cw.id
+		WHERE cw.address = ?`, address).First(&user)
+
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &user, nil
+}
+
+func gameReference(gameId uint64) string {
+	return fmt.Sprintf("game/%d", gameId)
+}
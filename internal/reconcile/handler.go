@@ -0,0 +1,81 @@
+package reconcile
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/middleware"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/model"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/reject"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/utils"
+	"gorm.io/gorm"
+)
+
+type discrepancyHandler struct {
+	discrepancies *discrepancyService
+	reconciler    *Reconciler
+}
+
+func RegisterRoutes(rg *gin.RouterGroup, db *gorm.DB, reconciler *Reconciler) {
+	handler := discrepancyHandler{
+		discrepancies: &discrepancyService{db: db},
+		reconciler:    reconciler,
+	}
+
+	routes := rg.Group("/admin/discrepancies", middleware.VerifyAuthToken, middleware.RequireAdmin)
+	routes.GET("", handler.getDiscrepancies)
+	routes.POST("/reconcile", handler.reconcile)
+	routes.POST("/:id/resolve", handler.resolve)
+}
+
+func (h discrepancyHandler) getDiscrepancies(c *gin.Context) {
+	page, err := utils.NewPageRequest(c)
+	if err != nil {
+		c.JSON(err.Problem.Status, err.Problem)
+		return
+	}
+
+	discrepancies, count, err := h.discrepancies.findAll(page, c.Query("status"))
+	if err != nil {
+		c.JSON(err.Problem.Status, err.Problem)
+		return
+	}
+
+	response := utils.NewPageResponse[model.GameDiscrepancy]().
+		WithItems(discrepancies).
+		WithItemCount(count)
+
+	if int(count) > (page.Token+1)*page.Size {
+		response.WithNextPageToken(int64(page.Token + 1))
+	}
+
+	c.JSON(http.StatusOK, response.Build())
+}
+
+func (h discrepancyHandler) reconcile(c *gin.Context) {
+	report, err := h.reconciler.Reconcile(c.Request.Context())
+	if err != nil {
+		problem := reject.UnexpectedProblem(err)
+		c.JSON(problem.Status, problem)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func (h discrepancyHandler) resolve(c *gin.Context) {
+	id, parseErr := strconv.ParseUint(c.Param("id"), 0, 64)
+	if parseErr != nil {
+		c.JSON(http.StatusBadRequest, reject.RequestParamsProblem())
+		return
+	}
+
+	discrepancy, err := h.discrepancies.resolve(id)
+	if err != nil {
+		c.JSON(err.Problem.Status, err.Problem)
+		return
+	}
+
+	c.JSON(http.StatusOK, discrepancy)
+}
@@ -0,0 +1,66 @@
+package reconcile
+
+import (
+	"expvar"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/model"
+	"github.com/rs/zerolog/log"
+)
+
+// reconciler metrics, published by expvar under "reconciler"
+var (
+	metrics = expvar.NewMap("reconciler")
+
+	runs         = new(expvar.Int)
+	gamesChecked = new(expvar.Int)
+	gamesFailed  = new(expvar.Int)
+	lastRunAt    = new(expvar.Int)
+	repaired     = new(expvar.Map).Init()
+)
+
+func init() {
+	metrics.Set("runs", runs)
+	metrics.Set("gamesChecked", gamesChecked)
+	metrics.Set("gamesFailed", gamesFailed)
+	metrics.Set("lastRunAt", lastRunAt)
+	metrics.Set("repaired", repaired)
+	metrics.Set("open", new(expvar.Map).Init())
+}
+
+func (r *Reconciler) record(report *Report) {
+	runs.Add(1)
+	gamesChecked.Add(int64(report.GamesChecked))
+	gamesFailed.Add(int64(report.Failed))
+	lastRunAt.Set(report.FinishedAt)
+
+	for _, discrepancy := range report.Found {
+		if discrepancy.Repaired {
+			repaired.Add(string(discrepancy.Kind), 1)
+		}
+	}
+
+	// open discrepancies of all instances
+	var counts []struct {
+		Kind  model.DiscrepancyKind
+		Count int64
+	}
+	result := r.db.
+		Model(&model.GameDiscrepancy{}).
+		Select("kind, COUNT(*) AS count").
+		Where("resolved_at IS NULL").
+		Group("kind").
+		Scan(&counts)
+
+	if result.Error != nil {
+		log.Warn().Err(result.Error).Msg("Error while counting open game discrepancies")
+		return
+	}
+
+	open := new(expvar.Map).Init()
+	for _, count := range counts {
+		value := new(expvar.Int)
+		value.Set(count.Count)
+		open.Set(string(count.Kind), value)
+	}
+	metrics.Set("open", open)
+}
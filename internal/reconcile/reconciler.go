@@ -0,0 +1,256 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/ledger"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/flowclient"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/model"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultInterval       = 5 * time.Minute
+	defaultPendingTimeout = 15 * time.Minute
+	batchSize             = 100
+)
+
+var openStatuses = []model.GameStatus{model.GamePreparing, model.GameCreated, model.GamePlaying}
+
+// Reconciler compares every game that is not finished with its on-chain
+// state. Discrepancies caused by lost events are repaired from the chain, the
+// others are recorded as open game discrepancies for an admin.
+//
+// Games are locked while they are reconciled, so any number of instances can
+// run a reconciler next to the event handlers.
+type Reconciler struct {
+	db             *gorm.DB
+	flowClient     flowclient.Client
+	ledger         *ledger.LedgerService
+	interval       time.Duration
+	pendingTimeout time.Duration
+
+	// one run at a time per instance
+	mu sync.Mutex
+}
+
+type Report struct {
+	StartedAt    int64                   `json:"startedAt"`
+	FinishedAt   int64                   `json:"finishedAt"`
+	GamesChecked int                     `json:"gamesChecked"`
+	Failed       int                     `json:"failed"`
+	Found        []model.GameDiscrepancy `json:"found"`
+}
+
+// finding is a discrepancy of a game, repair is nil when it cannot be
+// repaired safely
+type finding struct {
+	kind   model.DiscrepancyKind
+	detail string
+	repair func(tx *gorm.DB) error
+}
+
+// NewReconciler reads RECONCILE_INTERVAL, how often games are reconciled, and
+// RECONCILE_PENDING_TIMEOUT, how long a game creation may take.
+func NewReconciler(db *gorm.DB, flowClient flowclient.Client, ledger *ledger.LedgerService) *Reconciler {
+	interval := viper.GetDuration("RECONCILE_INTERVAL")
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+
+	pendingTimeout := viper.GetDuration("RECONCILE_PENDING_TIMEOUT")
+	if pendingTimeout <= 0 {
+		pendingTimeout = defaultPendingTimeout
+	}
+
+	return &Reconciler{
+		db:             db,
+		flowClient:     flowClient,
+		ledger:         ledger,
+		interval:       interval,
+		pendingTimeout: pendingTimeout,
+	}
+}
+
+func (r *Reconciler) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := r.Reconcile(ctx); err != nil {
+			log.Warn().Err(err).Msg("Error while reconciling games")
+		}
+	}
+}
+
+// Reconcile checks every open game once. A game that cannot be checked is
+// counted as failed and retried by the next run.
+func (r *Reconciler) Reconcile(ctx context.Context) (*Report, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := &Report{StartedAt: time.Now().UTC().UnixMilli(), Found: []model.GameDiscrepancy{}}
+
+	var lastId uint64
+	for {
+		var games []model.Game
+		result := r.db.
+			Select("id, flow_id").
+			Where("game_status IN ? AND id > ?", openStatuses, lastId).
+			Order("id").
+			Limit(batchSize).
+			Find(&games)
+
+		if result.Error != nil {
+			return nil, result.Error
+		}
+
+		for _, game := range games {
+			found, err := r.reconcileGame(ctx, game.Id, game.FlowId)
+			if err != nil {
+				log.Warn().Err(err).Msg(fmt.Sprintf("Error while reconciling game %d", game.Id))
+				report.Failed++
+				continue
+			}
+
+			report.GamesChecked++
+			report.Found = append(report.Found, found...)
+		}
+
+		if len(games) < batchSize {
+			break
+		}
+		lastId = games[len(games)-1].Id
+	}
+
+	report.FinishedAt = time.Now().UTC().UnixMilli()
+	r.record(report)
+	return report, nil
+}
+
+func (r *Reconciler) reconcileGame(ctx context.Context, gameId uint64, flowId *uint64) ([]model.GameDiscrepancy, error) {
+	var chain *flowclient.GameState
+	if flowId != nil {
+		state, err := flowclient.GetGameState(ctx, r.flowClient, *flowId)
+		if err != nil {
+			return nil, err
+		}
+		chain = state
+	}
+
+	var found []model.GameDiscrepancy
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var game model.Game
+		result := tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", gameId).
+			First(&game)
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		// an event was applied since the chain was read, checked by the next run
+		if !sameFlowId(game.FlowId, flowId) {
+			return nil
+		}
+
+		var findings []finding
+		if isOpen(game.GameStatus) {
+			var err error
+			findings, err = r.check(tx, game, chain)
+			if err != nil {
+				return err
+			}
+		}
+
+		now := time.Now().UTC().UnixMilli()
+		kinds := make([]model.DiscrepancyKind, 0, len(findings))
+		for _, f := range findings {
+			discrepancy, err := r.apply(tx, game.Id, f, now)
+			if err != nil {
+				return err
+			}
+			kinds = append(kinds, f.kind)
+			found = append(found, discrepancy)
+		}
+
+		return resolveOpen(tx, game.Id, kinds, now)
+	})
+
+	return found, err
+}
+
+// apply repairs the finding when possible and records it
+func (r *Reconciler) apply(tx *gorm.DB, gameId uint64, f finding, now int64) (model.GameDiscrepancy, error) {
+	discrepancy := model.GameDiscrepancy{
+		GameId:      gameId,
+		Kind:        f.kind,
+		Detail:      f.detail,
+		FirstSeenAt: now,
+		LastSeenAt:  now,
+	}
+
+	if f.repair != nil {
+		if err := f.repair(tx); err != nil {
+			return discrepancy, err
+		}
+		discrepancy.Repaired = true
+		discrepancy.ResolvedAt = &now
+
+		log.Info().Msg(fmt.Sprintf("Repaired %s of game %d: %s", f.kind, gameId, f.detail))
+		return discrepancy, tx.Create(&discrepancy).Error
+	}
+
+	result := tx.
+		Clauses(clause.OnConflict{
+			Columns:     []clause.Column{{Name: "game_id"}, {Name: "kind"}},
+			TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "resolved_at IS NULL"}}},
+			DoUpdates:   clause.AssignmentColumns([]string{"detail", "last_seen_at"}),
+		}).
+		Create(&discrepancy)
+
+	return discrepancy, result.Error
+}
+
+// resolveOpen resolves the open discrepancies of the game that were not
+// found again
+func resolveOpen(tx *gorm.DB, gameId uint64, found []model.DiscrepancyKind, now int64) error {
+	query := tx.
+		Model(&model.GameDiscrepancy{}).
+		Where("game_id = ? AND resolved_at IS NULL", gameId)
+
+	if len(found) > 0 {
+		query = query.Where("kind NOT IN ?", found)
+	}
+
+	return query.Update("resolved_at", now).Error
+}
+
+func isOpen(status model.GameStatus) bool {
+	for _, open := range openStatuses {
+		if status == open {
+			return true
+		}
+	}
+	return false
+}
+
+func sameFlowId(a *uint64, b *uint64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
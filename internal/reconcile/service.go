@@ -0,0 +1,93 @@
+package reconcile
+
+import (
+	"errors"
+	"time"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/model"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/reject"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	statusOpen     = "open"
+	statusResolved = "resolved"
+)
+
+type discrepancyService struct {
+	db *gorm.DB
+}
+
+func (s *discrepancyService) findAll(page utils.PageRequest, status string) ([]model.GameDiscrepancy, int64, *reject.ProblemWithTrace) {
+	query := s.db.Model(&model.GameDiscrepancy{})
+	switch status {
+	case "":
+	case statusOpen:
+		query = query.Where("resolved_at IS NULL")
+	case statusResolved:
+		query = query.Where("resolved_at IS NOT NULL")
+	default:
+		return nil, 0, &reject.ProblemWithTrace{
+			Problem: reject.RequestParamsProblem(),
+			Cause:   errors.New("unknown discrepancy status " + status),
+		}
+	}
+
+	var count int64
+	result := query.Count(&count)
+	if result.Error != nil {
+		return nil, 0, &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(result.Error),
+			Cause:   result.Error,
+		}
+	}
+
+	discrepancies := []model.GameDiscrepancy{}
+	result = query.
+		Order("last_seen_at DESC, id DESC").
+		Limit(page.Size).
+		Offset(page.Offset).
+		Find(&discrepancies)
+
+	if result.Error != nil {
+		return nil, 0, &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(result.Error),
+			Cause:   result.Error,
+		}
+	}
+
+	return discrepancies, count, nil
+}
+
+// resolve closes an open discrepancy an admin took care of
+func (s *discrepancyService) resolve(id uint64) (*model.GameDiscrepancy, *reject.ProblemWithTrace) {
+	result := s.db.
+		Model(&model.GameDiscrepancy{}).
+		Where("id = ? AND resolved_at IS NULL", id).
+		Update("resolved_at", time.Now().UTC().UnixMilli())
+
+	if result.Error != nil {
+		return nil, &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(result.Error),
+			Cause:   result.Error,
+		}
+	}
+
+	var discrepancy model.GameDiscrepancy
+	result = s.db.First(&discrepancy, id)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, &reject.ProblemWithTrace{
+			Problem: reject.NotFoundProblem(),
+			Cause:   result.Error,
+		}
+	}
+	if result.Error != nil {
+		return nil, &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(result.Error),
+			Cause:   result.Error,
+		}
+	}
+
+	return &discrepancy, nil
+}
@@ -1,98 +1,126 @@
 package registration
 
 import (
-	gcppubsub "cloud.google.com/go/pubsub"
 	"context"
 	"fmt"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/blockchain"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/flowevent"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/inbox"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/model"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/money"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/notification"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/pubsub"
-	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/utils"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/ws"
-	"github.com/kollektive-hackathon/battleblocks-backend/internal/profile"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/wsevent"
 	"github.com/rs/zerolog/log"
 	"gorm.io/gorm"
 )
 
-type AccountCreated struct {
-	PublicKey string `json:"originatingPublicKey"`
-	Address   string `json:"address"`
-}
-
-type AccountDelegated struct {
-	PublicKey           string `json:"originatingPublicKey"`
-	CustodialAddress    string `json:"address"`
-	NonCustodialAddress string `json:"parent"`
-}
+var initialFundingAmount = money.MustParse("10.0")
 
 type accountContractBridge struct {
+	broker          pubsub.Broker
 	db              *gorm.DB
-	profileService  *profile.ProfileService
 	notificationHub *ws.WebSocketNotificationHub
+	notifier        *notification.Notifier
 }
 
-func (b *accountContractBridge) createCustodialAccount(publicKey string) {
-	commandType := "CREATE_USER_ACCOUNT"
-	initialFundingAmount := float64(10)
-	payload := []any{
-		publicKey,
-		initialFundingAmount,
+func (b *accountContractBridge) createCustodialAccount(tx *gorm.DB, publicKey string) error {
+	payload := blockchain.CreateUserAccount{
+		PublicKey:            publicKey,
+		InitialFundingAmount: initialFundingAmount,
 	}
 	authorizers := []blockchain.Authorizer{blockchain.GetAdminAuthorizer()}
-	cmd := blockchain.NewBlockchainCommand(commandType, payload, authorizers)
-	pubsub.Publish(cmd)
+	cmd, err := blockchain.NewBlockchainCommand(payload, authorizers)
+	if err != nil {
+		return err
+	}
+
+	return blockchain.Dispatch(tx, cmd.WithReference(accountReference(publicKey)))
 }
 
-func (b *accountContractBridge) handleCustodialAccountCreated(_ context.Context, message *gcppubsub.Message) {
-	log.Info().Msg("Received message payload " + string(message.Data))
-	messagePayload, err := utils.JsonDecodeByteStream[AccountCreated](message.Data)
+func (b *accountContractBridge) handleCustodialAccountCreated(_ context.Context, event *inbox.Event) error {
+	messagePayload, err := flowevent.DecodeAs[flowevent.AccountCreated](event.Message.Data)
 	if err != nil {
 		log.Warn().Err(err).Msg("Error while parsing AccountCreated message")
-		return
+		return inbox.Permanent(err)
 	}
 
-	result := b.db.
+	result := event.Tx.
 		Model(&model.CustodialWallet{}).
 		Where("public_key = ?", messagePayload.PublicKey).
 		Update("address", messagePayload.Address)
 
 	if result.Error != nil {
 		log.Warn().Err(result.Error).Msg("Error while handling AccountCreated")
-		return
+		return result.Error
 	}
 
-	message.Ack()
+	err = blockchain.CorrelateEvent(event.Tx, event.Message.Attributes, blockchain.CommandCreateUserAccount, accountReference(messagePayload.PublicKey))
+	if err != nil {
+		log.Warn().Err(err).Msg("Error while correlating AccountCreated with its command")
+		return err
+	}
 
-	p, loadProfileProblem := b.profileService.FindByCustodialAddress(messagePayload.Address)
+	var user model.User
+	result = event.Tx.Raw(`SELECT bu.* FROM battleblocks_user bu
+		JOIN custodial_wallet cw ON bu.custodial_wallet_id = cw.id
+		WHERE cw.public_key = ?`, messagePayload.PublicKey).Scan(&user)
+	if result.Error != nil {
+		log.Warn().Err(result.Error).Msg("Error while fetching user of AccountCreated")
+		return result.Error
+	}
 
-	if loadProfileProblem != nil {
-		log.Warn().Err(result.Error).Msg("Cannot fetch profile on AccountCreated event")
+	if result.RowsAffected == 0 {
+		log.Warn().Msg(fmt.Sprintf("No user of custodial account %s", messagePayload.Address))
+		return nil
 	}
 
-	wsEvent := map[string]any{
-		"type":    "ACCOUNT_CREATED",
-		"payload": p,
+	accountCreated := wsevent.AccountCreated{
+		UserId:                   user.Id,
+		Email:                    user.Email,
+		Username:                 user.Username,
+		CustodialWalletAddress:   messagePayload.Address,
+		SelfCustodyWalletAddress: user.SelfCustodyWalletAddress,
 	}
-	b.notificationHub.Publish(fmt.Sprintf("registration/%s", p.Email), wsEvent)
+
+	err = b.notifier.Notify(event, user.Id, model.NotificationAccountCreated, accountReference(messagePayload.PublicKey), accountCreated)
+	if err != nil {
+		log.Warn().Err(err).Msg("Error while notifying the user of AccountCreated")
+		return err
+	}
+
+	event.AfterCommit(func() {
+		b.notificationHub.Publish(fmt.Sprintf("registration/%s", user.Email), accountCreated)
+	})
+	return nil
 }
 
-func (b *accountContractBridge) handleCustodialAccountDelegated(_ context.Context, message *gcppubsub.Message) {
-	log.Info().Msg("Received message payload " + string(message.Data))
-	messagePayload, err := utils.JsonDecodeByteStream[AccountDelegated](message.Data)
+func (b *accountContractBridge) handleCustodialAccountDelegated(_ context.Context, event *inbox.Event) error {
+	messagePayload, err := flowevent.DecodeAs[flowevent.AccountDelegated](event.Message.Data)
 	if err != nil {
 		log.Warn().Err(err).Msg("Error while parsing AccountDelegated message")
-		return
+		return inbox.Permanent(err)
 	}
 
-	result := b.db.
+	result := event.Tx.
 		Model(&model.User{}).
 		Where("custodial_wallet_id = (SELECT id FROM custodial_wallet WHERE address = ?)", messagePayload.CustodialAddress).
 		Update("self_custody_wallet_address", messagePayload.NonCustodialAddress)
 
 	if result.Error != nil {
-		log.Warn().Err(result.Error).Msg("Error while handling AccountCreated")
-		return
+		log.Warn().Err(result.Error).Msg("Error while handling AccountDelegated")
+		return result.Error
 	}
 
-	message.Ack()
+	return nil
+}
+
+func accountReference(publicKey string) string {
+	return fmt.Sprintf("account/%s", publicKey)
+}
+
+func (b *accountContractBridge) subscribe() {
+	inbox.Subscribe(b.broker, b.db, "blockchain.flow.events.account-created-sub", b.handleCustodialAccountCreated)
+	inbox.Subscribe(b.broker, b.db, "blockchain.flow.events.account-delegated-sub", b.handleCustodialAccountDelegated)
 }
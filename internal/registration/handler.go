@@ -3,6 +3,7 @@ package registration
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/middleware"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/notification"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/pubsub"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/reject"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/utils"
@@ -18,14 +19,15 @@ type registrationHandler struct {
 	profile      *profile.ProfileService
 }
 
-func RegisterRoutesAndSubscriptions(rg *gin.RouterGroup, db *gorm.DB) {
+func RegisterRoutesAndSubscriptions(rg *gin.RouterGroup, db *gorm.DB, broker pubsub.Broker) {
 	handler := registrationHandler{
 		registration: &registrationService{
 			db: db,
 			bridge: &accountContractBridge{
+				broker:          broker,
 				db:              db,
-				profileService:  &profile.ProfileService{Db: db},
 				notificationHub: ws.NewNotificationHub(),
+				notifier:        notification.NewNotifier(ws.NewNotificationHub()),
 			},
 		},
 		profile: &profile.ProfileService{Db: db},
@@ -34,15 +36,7 @@ func RegisterRoutesAndSubscriptions(rg *gin.RouterGroup, db *gorm.DB) {
 	routes := rg.Group("/registration")
 	routes.POST("", middleware.VerifyAuthToken, handler.register)
 
-	go pubsub.Subscribe(pubsub.SubscriptionHandler{
-		SubscriptionId: "blockchain.flow.events.account-created-sub",
-		Handler:        handler.registration.bridge.handleCustodialAccountCreated,
-	})
-
-	go pubsub.Subscribe(pubsub.SubscriptionHandler{
-		SubscriptionId: "blockchain.flow.events.account-delegated-sub",
-		Handler:        handler.registration.bridge.handleCustodialAccountDelegated,
-	})
+	handler.registration.bridge.subscribe()
 }
 
 type RegistrationRequest struct {
@@ -57,14 +57,12 @@ func (s *registrationService) register(username string, email string, googleIden
 
 		userId = user.Id
 
-		return nil
+		return s.bridge.createCustodialAccount(tx, publicKey)
 	})
 
 	if err != nil {
 		return 0, &reject.ProblemWithTrace{Problem: reject.UnexpectedProblem(err), Cause: err}
 	}
 
-	s.bridge.createCustodialAccount(publicKey)
-
 	return userId, nil
 }
@@ -5,7 +5,9 @@ import (
 	"io/ioutil"
 	"net/http"
 
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/notification"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/pubsub"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/ws"
 	"github.com/rs/zerolog/log"
 
 	"github.com/gin-gonic/gin"
@@ -26,12 +28,14 @@ type PPData struct {
 	} `json:"resource"`
 }
 
-func RegisterRoutesAndSubscriptions(rg *gin.RouterGroup, db *gorm.DB) {
+func RegisterRoutesAndSubscriptions(rg *gin.RouterGroup, db *gorm.DB, broker pubsub.Broker) {
 	handler := shopHandler{
 		shop: shopService{
 			db: db,
 			bridge: &nftContractBridge{
-				db: db,
+				broker:   broker,
+				db:       db,
+				notifier: notification.NewNotifier(ws.NewNotificationHub()),
 			},
 		},
 	}
@@ -40,10 +44,7 @@ func RegisterRoutesAndSubscriptions(rg *gin.RouterGroup, db *gorm.DB) {
 	routes.GET("", middleware.VerifyAuthToken, handler.getShopList)
 	routes.POST("/pp", handler.paypalWebhook)
 
-	go pubsub.Subscribe(pubsub.SubscriptionHandler{
-		SubscriptionId: "blockchain.flow.events.minted",
-		Handler:        handler.shop.bridge.handleMinted,
-	})
+	handler.shop.bridge.subscribe()
 }
 
 func (h shopHandler) getShopList(c *gin.Context) {
@@ -2,145 +2,151 @@ package shop
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
 
-	gcppubsub "cloud.google.com/go/pubsub"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/blockchain"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/flowevent"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/inbox"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/model"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/notification"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/pubsub"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/wsevent"
 	"github.com/rs/zerolog/log"
 	"gorm.io/gorm"
 )
 
-type MintedEvent struct {
-	To   string `json:"to"`
-	Name string `json:"name"`
-	Id   uint64 `json:"id"`
-}
-
 type nftContractBridge struct {
-	db *gorm.DB
+	broker   pubsub.Broker
+	db       *gorm.DB
+	notifier *notification.Notifier
 }
 
-func (b *nftContractBridge) mint(recipientAddress string, block model.Block, authorizers []blockchain.Authorizer) {
-	commandType := "NFT_MINT"
-	payload := []any{
-		recipientAddress,
-		block.Name,
-		map[string]any{
-			"type":     block.BlockType,
-			"rarity":   block.Rarity,
-			"colorHex": block.ColorHex,
-		},
+func (b *nftContractBridge) mint(tx *gorm.DB, recipientAddress string, block model.Block, authorizers []blockchain.Authorizer) error {
+	payload := blockchain.NftMint{
+		RecipientAddress: recipientAddress,
+		Name:             block.Name,
+		BlockType:        block.BlockType,
+		Rarity:           block.Rarity,
+		ColorHex:         block.ColorHex,
 	}
+	return b.dispatch(tx, payload, authorizers, mintReference(recipientAddress, block.Name))
+}
 
-	cmd := blockchain.NewBlockchainCommand(commandType, payload, authorizers)
-	pubsub.Publish(cmd)
+func (b *nftContractBridge) transfer(tx *gorm.DB, recipientAddress string, withdrawId uint64, authorizers []blockchain.Authorizer) error {
+	payload := blockchain.NftTransfer{
+		RecipientAddress: recipientAddress,
+		WithdrawId:       withdrawId,
+	}
+	return b.dispatch(tx, payload, authorizers, "")
 }
 
-func (b *nftContractBridge) transfer(recipientAddress string, withdrawId uint64, authorizers []blockchain.Authorizer) {
-	commandType := "NFT_TRANSFER"
-	payload := []any{
-		recipientAddress,
-		withdrawId,
+func (b *nftContractBridge) transferAdmin(tx *gorm.DB, recipientAddress string, withdrawId uint64, authorizers []blockchain.Authorizer) error {
+	payload := blockchain.NftTransferAdmin{
+		RecipientAddress: recipientAddress,
+		WithdrawId:       withdrawId,
 	}
-	cmd := blockchain.NewBlockchainCommand(commandType, payload, authorizers)
-	pubsub.Publish(cmd)
+	return b.dispatch(tx, payload, authorizers, "")
 }
 
-func (b *nftContractBridge) transferAdmin(recipientAddress string, withdrawId uint64, authorizers []blockchain.Authorizer) {
-	commandType := "NFT_TRANSFER_ADMIN"
-	payload := []any{
-		recipientAddress,
-		withdrawId,
+func (b *nftContractBridge) burn(tx *gorm.DB, id uint64, authorizers []blockchain.Authorizer) error {
+	payload := blockchain.NftBurn{
+		Id: id,
 	}
-	cmd := blockchain.NewBlockchainCommand(commandType, payload, authorizers)
-	pubsub.Publish(cmd)
+	return b.dispatch(tx, payload, authorizers, "")
 }
 
-func (b *nftContractBridge) burn(id uint64, authorizers []blockchain.Authorizer) {
-	commandType := "NFT_BURN"
-	payload := []any{
-		id,
+func (b *nftContractBridge) dispatch(tx *gorm.DB, payload blockchain.CommandPayload, authorizers []blockchain.Authorizer, reference string) error {
+	cmd, err := blockchain.NewBlockchainCommand(payload, authorizers)
+	if err != nil {
+		return err
 	}
-	cmd := blockchain.NewBlockchainCommand(commandType, payload, authorizers)
-	pubsub.Publish(cmd)
+
+	return blockchain.Dispatch(tx, cmd.WithReference(reference))
 }
 
 // TODO implement consumers
-func (b *nftContractBridge) handleWithdraw(_ context.Context, _ *gcppubsub.Message) {
+func (b *nftContractBridge) handleWithdraw(_ context.Context, _ *inbox.Event) error {
+	return nil
 }
 
-func (b *nftContractBridge) handleMinted(_ context.Context, m *gcppubsub.Message) {
-	log.Info().Msg("Received message payload " + string(m.Data))
-
-	var eventData MintedEvent
-	err := json.Unmarshal(m.Data, &eventData)
-
+func (b *nftContractBridge) handleMinted(_ context.Context, event *inbox.Event) error {
+	eventData, err := flowevent.DecodeAs[flowevent.Minted](event.Message.Data)
 	if err != nil {
 		log.Warn().Err(err).Msg("Could not unmarshal minted event data")
-		return
+		return inbox.Permanent(err)
+	}
+
+	tx := event.Tx
+	var block model.Block
+	f := tx.Table("block").Where("name = ?", eventData.Name).First(&block)
+	if f.Error != nil {
+		log.Warn().Msg("error fetching block to transfer to user")
+		return errors.New("error fetching block to transfer to user")
+	}
+
+	var user model.User
+	f = tx.Raw(`SELECT bu.* FROM
+		battleblocks_user bu LEFT JOIN
+		custodial_wallet cw ON bu.custodial_wallet_id = cw.id
+		WHERE cw.address = ?`, eventData.To).First(&user)
+	if f.Error != nil {
+		log.Warn().Msg("error fetching user to transfer to ")
+		return errors.New("error fetching user to transfer to ")
 	}
 
-	err = b.db.Transaction(func(tx *gorm.DB) error {
-		var block model.Block
-		f := tx.Table("block").Where("name = ?", eventData.Name).First(&block)
-		if f.Error != nil {
-			log.Warn().Msg("error fetching block to transfer to user")
-			return errors.New("error fetching block to transfer to user")
-		}
-
-		var user model.User
-		f = tx.Raw(`SELECT bu.* FROM
-			battleblocks_user bu LEFT JOIN
-			custodial_wallet cw ON bu.custodial_wallet_id = cw.id
-			WHERE cw.address = ?`, eventData.To).First(&user)
-		if f.Error != nil {
-			log.Warn().Msg("error fetching user to transfer to ")
-			return errors.New("error fetching user to transfer to ")
-		}
-
-		// Insert NFT on user with TO
-		// Insert NFT purchase history
-		flowId := eventData.Id
-		nft := model.Nft{
-			FlowId:  flowId,
-			BlockId: block.Id,
-		}
-		tx.Table("nft").Create(&nft)
-
-		nft_history := model.NftPurchaseHistory{
-			NftId:       nft.Id,
-			BuyerId:     user.Id,
-			PurchasedAt: time.Now().UTC().UnixMilli(),
-		}
-
-		tx.Table("nft_purchase_history").Create(&nft_history)
-
-		result := tx.Exec(fmt.Sprintf(`INSERT INTO user_block_inventory(user_id, block_id, active)
-			SELECT %d, %d, true`, user.Id, block.Id))
-
-		if result.Error != nil {
-			log.Warn().Msg("error inserting the item to user inventory")
-			return errors.New("error inserting the item to user inventory")
-		}
-
-		return nil
+	// Insert NFT on user with TO
+	// Insert NFT purchase history
+	flowId := eventData.Id
+	nft := model.Nft{
+		FlowId:  flowId,
+		BlockId: block.Id,
+	}
+	tx.Table("nft").Create(&nft)
+
+	nft_history := model.NftPurchaseHistory{
+		NftId:       nft.Id,
+		BuyerId:     user.Id,
+		PurchasedAt: time.Now().UTC().UnixMilli(),
+	}
+
+	tx.Table("nft_purchase_history").Create(&nft_history)
+
+	result := tx.Exec(fmt.Sprintf(`INSERT INTO user_block_inventory(user_id, block_id, active)
+		SELECT %d, %d, true`, user.Id, block.Id))
+
+	if result.Error != nil {
+		log.Warn().Msg("error inserting the item to user inventory")
+		return errors.New("error inserting the item to user inventory")
+	}
+
+	err = b.notifier.Notify(event, user.Id, model.NotificationNftMinted, fmt.Sprintf("nft/%d", nft.Id), wsevent.NftMinted{
+		NftId:   nft.Id,
+		FlowId:  flowId,
+		BlockId: block.Id,
+		Name:    block.Name,
 	})
 	if err != nil {
-		log.Info().Interface("ev", eventData).Msg("Could not handle mitned event")
-		return
+		log.Warn().Err(err).Msg("Error while notifying the owner of Minted")
+		return err
 	}
-	m.Ack()
+
+	return blockchain.CorrelateEvent(tx, event.Message.Attributes, blockchain.CommandNftMint, mintReference(eventData.To, eventData.Name))
 }
 
-func (b *nftContractBridge) handleDeposited(_ context.Context, _ *gcppubsub.Message) {
+func (b *nftContractBridge) handleDeposited(_ context.Context, _ *inbox.Event) error {
+	return nil
+}
 
+func (b *nftContractBridge) handleBurned(_ context.Context, _ *inbox.Event) error {
+	return nil
 }
 
-func (b *nftContractBridge) handleBurned(_ context.Context, _ *gcppubsub.Message) {
+func mintReference(recipientAddress string, blockName string) string {
+	return fmt.Sprintf("mint/%s/%s", recipientAddress, blockName)
+}
 
+func (b *nftContractBridge) subscribe() {
+	inbox.Subscribe(b.broker, b.db, "blockchain.flow.events.minted", b.handleMinted)
 }
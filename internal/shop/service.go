@@ -54,7 +54,6 @@ func (ss *shopService) SendBoughtToUser(userId, blockId string) {
 			return result.Error
 		}
 
-		ss.bridge.mint(*wallet.Address, block, []blockchain.Authorizer{blockchain.GetAdminAuthorizer()})
-		return nil
+		return ss.bridge.mint(tx, *wallet.Address, block, []blockchain.Authorizer{blockchain.GetAdminAuthorizer()})
 	})
 }
@@ -0,0 +1,71 @@
+package ws
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/reject"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/utils"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/ws"
+)
+
+// Browsers cannot set the Authorization header of a websocket handshake, so
+// the token is also accepted as the access_token query parameter, as the
+// second subprotocol after "bearer", or in the first frame:
+//
+//	{"type": "AUTH", "token": "<id token>"}
+const (
+	bearerSubprotocol = "bearer"
+	authFrameType     = "AUTH"
+	authFrameTimeout  = 10 * time.Second
+)
+
+type authFrame struct {
+	Type  string `json:"type"`
+	Token string `json:"token"`
+}
+
+func tokenFromRequest(r *http.Request) string {
+	if token := r.URL.Query().Get("access_token"); token != "" {
+		return token
+	}
+
+	protocols := websocket.Subprotocols(r)
+	if len(protocols) == 2 && protocols[0] == bearerSubprotocol {
+		return protocols[1]
+	}
+
+	return r.Header.Get("Authorization")
+}
+
+func readAuthFrame(conn *websocket.Conn) (string, error) {
+	conn.SetReadDeadline(time.Now().Add(authFrameTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+
+	var frame authFrame
+	if err := conn.ReadJSON(&frame); err != nil {
+		return "", err
+	}
+	if frame.Type != authFrameType {
+		return "", errors.New("first frame is not an AUTH frame")
+	}
+	return frame.Token, nil
+}
+
+// closeOnExpiry closes the connection once its token expires
+func closeOnExpiry(client *ws.Client, accessToken *utils.AccessToken) *time.Timer {
+	expiresIn := time.Until(time.Unix(accessToken.Token.Expires, 0))
+	return time.AfterFunc(expiresIn, func() {
+		client.CloseWith(websocket.ClosePolicyViolation, "Access token expired")
+	})
+}
+
+func closeWithProblem(client *ws.Client, problem *reject.ProblemWithTrace) {
+	code := websocket.ClosePolicyViolation
+	if problem.Problem.Status >= http.StatusInternalServerError {
+		code = websocket.CloseInternalServerErr
+	}
+	client.CloseWith(code, problem.Problem.Title)
+}
@@ -3,75 +3,194 @@ package ws
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/middleware"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/notification"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/reject"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/utils"
 	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/ws"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/wsevent"
 	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
 )
 
 type wsHandler struct {
 	notificationHub *ws.WebSocketNotificationHub
+	commands        *ws.CommandRouter
+	service         *wsService
 }
 
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	Subprotocols:    []string{bearerSubprotocol},
 	CheckOrigin: func(r *http.Request) bool {
 		return true
 	},
 }
 
-func RegisterRoutes(rg *gin.RouterGroup) {
+func RegisterRoutes(rg *gin.RouterGroup, db *gorm.DB) {
 	handler := wsHandler{
 		notificationHub: ws.NewNotificationHub(),
+		commands:        ws.NewCommandRouter(),
+		service:         &wsService{db: db},
 	}
 
+	rg.GET("/ws", handler.serveMultiplexed)
+
 	routes := rg.Group("/ws")
-	routes.GET("/game/:id", handler.serveGameWs)
-	routes.GET("/registration/:userEmail", handler.serveRegistrationWs)
+	routes.GET("/game/:id", handler.gameTopic(handler.serve))
+	routes.GET("/registration/:userEmail", handler.registrationTopic(handler.serve))
+	routes.GET("/notifications/:userId", handler.notificationsTopic(handler.serve))
+
+	sseRoutes := rg.Group("/sse")
+	sseRoutes.GET("/game/:id", handler.gameTopic(handler.serveSSE))
+	sseRoutes.GET("/registration/:userEmail", handler.registrationTopic(handler.serveSSE))
+	sseRoutes.GET("/notifications/:userId", handler.notificationsTopic(handler.serveSSE))
 }
 
-func (wsh *wsHandler) serveGameWs(c *gin.Context) {
-	gameId := c.Param("id")
-	conn, er := upgrader.Upgrade(c.Writer, c.Request, nil)
-	if er != nil {
-		log.Warn().Err(er).Msg("Couldnt upgrade request")
-		return
+type authorizer func(userEmail string) *reject.ProblemWithTrace
+
+// topicServer streams the events of the topic to a client the authorizer
+// accepts
+type topicServer func(c *gin.Context, topic string, authorize authorizer)
+
+func (wsh *wsHandler) gameTopic(serve topicServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		gameId, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, reject.RequestParamsProblem())
+			return
+		}
+
+		serve(c, gameTopic(gameId), func(userEmail string) *reject.ProblemWithTrace {
+			return wsh.service.authorizeGame(userEmail, gameId)
+		})
 	}
+}
 
-	defer wsh.notificationHub.UnregisterListener(fmt.Sprintf("game/%s", gameId), conn)
+func (wsh *wsHandler) registrationTopic(serve topicServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		topicEmail := c.Param("userEmail")
 
-	wsh.notificationHub.RegisterListener(fmt.Sprintf("game/%s", gameId), conn)
+		serve(c, fmt.Sprintf("registration/%s", topicEmail), func(userEmail string) *reject.ProblemWithTrace {
+			return wsh.service.authorizeRegistration(userEmail, topicEmail)
+		})
+	}
+}
 
-	for {
-		var buffer any
-		err := conn.ReadJSON(&buffer)
+func (wsh *wsHandler) notificationsTopic(serve topicServer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userId, err := strconv.ParseUint(c.Param("userId"), 10, 64)
 		if err != nil {
-			log.Warn().Err(err).Msg("Error reading ws message")
+			c.JSON(http.StatusBadRequest, reject.RequestParamsProblem())
 			return
 		}
+
+		serve(c, notification.Topic(userId), func(userEmail string) *reject.ProblemWithTrace {
+			return wsh.service.authorizeNotifications(userEmail, userId)
+		})
 	}
 }
 
-func (wsh *wsHandler) serveRegistrationWs(c *gin.Context) {
-	//userEmail := utils.GetUserEmail(c)
-	userEmail := c.Param("userEmail")
+// serve authenticates the connection and listens to the topic until either
+// side closes the connection or the token expires. Messages of the client
+// are commands answered with an ACK or a PROBLEM. A client reconnecting with
+// the lastSeq query parameter gets the events it missed first.
+func (wsh *wsHandler) serve(c *gin.Context, topic string, authorize authorizer) {
+	client, accessToken, ok := wsh.connect(c, authorize)
+	if !ok {
+		return
+	}
+	defer client.Close()
+
+	expiry := closeOnExpiry(client, accessToken)
+	defer expiry.Stop()
+
+	defer wsh.notificationHub.UnregisterListener(topic, client)
+
+	if lastSeq, err := strconv.ParseUint(c.Query("lastSeq"), 10, 64); err == nil {
+		wsh.notificationHub.RegisterListenerFrom(c.Request.Context(), topic, client, lastSeq)
+	} else {
+		wsh.notificationHub.RegisterListener(topic, client)
+	}
+
+	err := client.ReadMessages(func(data []byte) {
+		client.SendJSON(wsh.commands.Dispatch(accessToken.Email(), topic, data))
+	})
+	log.Warn().Err(err).Msg("Error reading ws message")
+}
+
+// connect upgrades an authenticated connection. A token sent with the
+// handshake is checked before upgrading, so a rejected client gets a problem
+// response instead of a close frame.
+func (wsh *wsHandler) connect(c *gin.Context, authorize authorizer) (*ws.Client, *utils.AccessToken, bool) {
+	var accessToken *utils.AccessToken
+	if rawToken := tokenFromRequest(c.Request); rawToken != "" {
+		token, problem := wsh.authenticate(rawToken, authorize)
+		if problem != nil {
+			c.JSON(problem.Problem.Status, problem.Problem)
+			return nil, nil, false
+		}
+		accessToken = token
+	}
+
 	conn, er := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if er != nil {
 		log.Warn().Err(er).Msg("Couldnt upgrade request")
-		return
+		return nil, nil, false
 	}
-	defer wsh.notificationHub.UnregisterListener(fmt.Sprintf("registration/%s", userEmail), conn)
 
-	wsh.notificationHub.RegisterListener(fmt.Sprintf("registration/%s", userEmail), conn)
+	client := wsh.notificationHub.NewClient(conn)
+	if accessToken != nil {
+		wsh.identify(client, accessToken)
+		return client, accessToken, true
+	}
 
-	for {
-		var buffer any
-		err := conn.ReadJSON(&buffer)
-		if err != nil {
-			log.Warn().Err(err).Msg("Error reading ws message")
-			return
+	rawToken, err := readAuthFrame(conn)
+	if err != nil {
+		log.Warn().Err(err).Msg("Error reading ws auth frame")
+		client.CloseWith(websocket.ClosePolicyViolation, "Missing access token")
+		return nil, nil, false
+	}
+
+	token, problem := wsh.authenticate(rawToken, authorize)
+	if problem != nil {
+		closeWithProblem(client, problem)
+		return nil, nil, false
+	}
+
+	client.SendJSON(wsevent.Wrap(wsevent.Authenticated{}))
+	wsh.identify(client, token)
+	return client, token, true
+}
+
+// identify counts the connection towards the presence of the user, users
+// who are still registering have no id yet
+func (wsh *wsHandler) identify(client *ws.Client, accessToken *utils.AccessToken) {
+	userId, problem := wsh.service.userId(accessToken.Email())
+	if problem != nil {
+		if problem.Problem.Status != http.StatusNotFound {
+			log.Warn().Err(problem.Cause).Msg("Error while identifying ws client")
 		}
+		return
+	}
+
+	wsh.notificationHub.Identify(client, userId)
+}
+
+func (wsh *wsHandler) authenticate(rawToken string, authorize authorizer) (*utils.AccessToken, *reject.ProblemWithTrace) {
+	accessToken, problem := middleware.VerifyToken(rawToken)
+	if problem != nil {
+		return nil, problem
+	}
+
+	if problem := authorize(accessToken.Email()); problem != nil {
+		log.Warn().Msg(fmt.Sprintf("Websocket topic denied to %s: %s", accessToken.Email(), problem.Problem.Title))
+		return nil, problem
 	}
+	return accessToken, nil
 }
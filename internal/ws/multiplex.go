@@ -0,0 +1,262 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/reject"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/ws"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/wsevent"
+	"github.com/rs/zerolog/log"
+)
+
+// A multiplexed connection listens to the topics it subscribes to:
+//
+//	{"id": "1", "type": "SUBSCRIBE", "topic": "game/12", "lastSeq": 4}
+//	{"id": "2", "type": "UNSUBSCRIBE", "topic": "game/12"}
+//
+// game/* subscribes to every game of the user that is not finished,
+// including the ones the user creates or joins later. Other messages are
+// commands on one of the subscribed topics.
+const (
+	subscribeType   = "SUBSCRIBE"
+	unsubscribeType = "UNSUBSCRIBE"
+	myGamesTopic    = "game/*"
+
+	notSubscribed string = "error.ws.not-subscribed"
+)
+
+type subscriptionRequest struct {
+	Id      string  `json:"id"`
+	Type    string  `json:"type"`
+	Topic   string  `json:"topic"`
+	LastSeq *uint64 `json:"lastSeq"`
+}
+
+func (wsh *wsHandler) serveMultiplexed(c *gin.Context) {
+	client, accessToken, ok := wsh.connect(c, func(string) *reject.ProblemWithTrace { return nil })
+	if !ok {
+		return
+	}
+	defer client.Close()
+
+	expiry := closeOnExpiry(client, accessToken)
+	defer expiry.Stop()
+
+	subs := &subscriptions{
+		hub:    wsh.notificationHub,
+		client: client,
+		topics: map[string]subscriptionSource{},
+	}
+	defer subs.close()
+
+	err := client.ReadMessages(func(data []byte) {
+		client.SendJSON(wsh.handleMultiplexed(c.Request.Context(), subs, accessToken.Email(), data))
+	})
+	log.Warn().Err(err).Msg("Error reading ws message")
+}
+
+func (wsh *wsHandler) handleMultiplexed(ctx context.Context, subs *subscriptions, userEmail string, data []byte) ws.CommandResponse {
+	var request subscriptionRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		return ws.CommandResponse{Id: request.Id, Type: ws.ProblemType, Payload: reject.BodyParseProblem()}
+	}
+
+	switch request.Type {
+	case subscribeType:
+		topics, problem := wsh.subscribe(ctx, subs, userEmail, request)
+		if problem != nil {
+			return ws.CommandResponse{Id: request.Id, Type: ws.ProblemType, Payload: problem.Problem}
+		}
+		return ws.CommandResponse{Id: request.Id, Type: ws.AckType, Payload: map[string]any{"topics": topics}}
+
+	case unsubscribeType:
+		if request.Topic == myGamesTopic {
+			subs.unfollowMyGames()
+		} else {
+			subs.remove(request.Topic, explicitSubscription)
+		}
+		return ws.CommandResponse{Id: request.Id, Type: ws.AckType}
+
+	default:
+		if !subs.has(request.Topic) {
+			return ws.CommandResponse{
+				Id:   request.Id,
+				Type: ws.ProblemType,
+				Payload: reject.NewProblem().
+					WithTitle("Not subscribed to topic").
+					WithStatus(http.StatusForbidden).
+					WithCode(notSubscribed).
+					WithParam("topic", request.Topic).
+					Build(),
+			}
+		}
+		return wsh.commands.Dispatch(userEmail, request.Topic, data)
+	}
+}
+
+// subscribe returns the topics the connection listens to because of the
+// subscription
+func (wsh *wsHandler) subscribe(ctx context.Context, subs *subscriptions, userEmail string, request subscriptionRequest) ([]string, *reject.ProblemWithTrace) {
+	if request.Topic == myGamesTopic {
+		userId, gameIds, problem := wsh.service.playerGames(userEmail)
+		if problem != nil {
+			return nil, problem
+		}
+		return subs.followMyGames(userId, gameIds), nil
+	}
+
+	if problem := wsh.service.authorizeTopic(userEmail, request.Topic); problem != nil {
+		return nil, problem
+	}
+
+	subs.add(ctx, request.Topic, explicitSubscription, request.LastSeq)
+	return []string{request.Topic}, nil
+}
+
+type subscriptionSource uint8
+
+const (
+	explicitSubscription subscriptionSource = 1 << iota
+	myGamesSubscription
+)
+
+// subscriptions are the topics of a multiplexed connection. A topic can be
+// subscribed both explicitly and through game/*, it is left once neither
+// subscription remains.
+type subscriptions struct {
+	hub    *ws.WebSocketNotificationHub
+	client *ws.Client
+
+	mu     sync.Mutex
+	closed bool
+	topics map[string]subscriptionSource
+	// stopMyGames stops following the lobby for new games of the user
+	stopMyGames func()
+}
+
+func (s *subscriptions) add(ctx context.Context, topic string, source subscriptionSource, lastSeq *uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing := s.topics[topic]
+	if s.closed || existing&source != 0 {
+		return
+	}
+	s.topics[topic] = existing | source
+	if existing != 0 {
+		return
+	}
+
+	if lastSeq != nil {
+		s.hub.RegisterListenerFrom(ctx, topic, s.client, *lastSeq)
+	} else {
+		s.hub.RegisterListener(topic, s.client)
+	}
+}
+
+func (s *subscriptions) remove(topic string, source subscriptionSource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeLocked(topic, source)
+}
+
+func (s *subscriptions) removeLocked(topic string, source subscriptionSource) {
+	existing := s.topics[topic]
+	if existing&source == 0 {
+		return
+	}
+
+	if left := existing &^ source; left != 0 {
+		s.topics[topic] = left
+		return
+	}
+	delete(s.topics, topic)
+	s.hub.UnregisterListener(topic, s.client)
+}
+
+func (s *subscriptions) has(topic string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.topics[topic] != 0
+}
+
+// followMyGames subscribes to the games of the user and to the ones the
+// lobby announces later. The lobby is watched first, so a game created in
+// between is not missed.
+func (s *subscriptions) followMyGames(userId uint64, gameIds []uint64) []string {
+	s.mu.Lock()
+	if !s.closed && s.stopMyGames == nil {
+		s.stopMyGames = s.hub.Watch(ws.LobbyTopic, func(publication ws.Publication) {
+			gameId, ok := lobbyGameOf(publication, userId)
+			if ok {
+				// the events of a new game published before subscribing are replayed
+				var lastSeq uint64
+				go s.add(context.Background(), gameTopic(gameId), myGamesSubscription, &lastSeq)
+			}
+		})
+	}
+	s.mu.Unlock()
+
+	topics := make([]string, len(gameIds))
+	for i, gameId := range gameIds {
+		topics[i] = gameTopic(gameId)
+		s.add(context.Background(), topics[i], myGamesSubscription, nil)
+	}
+	return topics
+}
+
+func (s *subscriptions) unfollowMyGames() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopMyGames == nil {
+		return
+	}
+	s.stopMyGames()
+	s.stopMyGames = nil
+
+	for topic := range s.topics {
+		s.removeLocked(topic, myGamesSubscription)
+	}
+}
+
+func (s *subscriptions) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	if s.stopMyGames != nil {
+		s.stopMyGames()
+	}
+	for topic := range s.topics {
+		s.hub.UnregisterListener(topic, s.client)
+	}
+	s.topics = map[string]subscriptionSource{}
+}
+
+// lobbyGameOf returns the game of a lobby event if the user plays it
+func lobbyGameOf(publication ws.Publication, userId uint64) (uint64, bool) {
+	envelope, err := wsevent.Decode(publication.Event)
+	if err != nil {
+		return 0, false
+	}
+
+	switch event := envelope.Payload.(type) {
+	case wsevent.GameCreated:
+		return event.GameId, event.OwnerId == userId
+	case wsevent.ChallengerJoined:
+		return event.GameId, event.OwnerId == userId || event.ChallengerId == userId
+	}
+	return 0, false
+}
+
+func gameTopic(gameId uint64) string {
+	return fmt.Sprintf("game/%d", gameId)
+}
@@ -0,0 +1,166 @@
+package ws
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/middleware"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/model"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/reject"
+	"github.com/kollektive-hackathon/battleblocks-backend/internal/pkg/ws"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+const (
+	topicForbidden string = "error.ws.topic-forbidden"
+	unknownTopic   string = "error.ws.unknown-topic"
+)
+
+type wsService struct {
+	db *gorm.DB
+}
+
+// authorizeRegistration lets a user listen to their own registration only
+func (s *wsService) authorizeRegistration(userEmail string, topicEmail string) *reject.ProblemWithTrace {
+	if userEmail != "" && strings.EqualFold(userEmail, topicEmail) {
+		return nil
+	}
+	return forbidden("Registration of another user")
+}
+
+// authorizeGame lets the players of a game listen to it. Admins and, when
+// WS_ALLOW_SPECTATORS is set, any other user can spectate.
+func (s *wsService) authorizeGame(userEmail string, gameId uint64) *reject.ProblemWithTrace {
+	var game model.Game
+	result := s.db.Where("id = ?", gameId).First(&game)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return &reject.ProblemWithTrace{
+			Problem: reject.NotFoundProblem(),
+			Cause:   result.Error,
+		}
+	}
+	if result.Error != nil {
+		return &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(result.Error),
+			Cause:   result.Error,
+		}
+	}
+
+	if viper.GetBool("WS_ALLOW_SPECTATORS") || middleware.IsAdmin(userEmail) {
+		return nil
+	}
+
+	var userId uint64
+	result = s.db.Raw("SELECT u.id FROM battleblocks_user u WHERE email = ?", userEmail).Scan(&userId)
+	if result.Error != nil {
+		return &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(result.Error),
+			Cause:   result.Error,
+		}
+	}
+
+	if result.RowsAffected > 0 && (game.OwnerId == userId || (game.ChallengerId != nil && *game.ChallengerId == userId)) {
+		return nil
+	}
+	return forbidden("Game of other players")
+}
+
+// authorizeNotifications lets a user listen to their own notifications only
+func (s *wsService) authorizeNotifications(userEmail string, userId uint64) *reject.ProblemWithTrace {
+	var id uint64
+	result := s.db.Raw("SELECT u.id FROM battleblocks_user u WHERE email = ?", userEmail).Scan(&id)
+	if result.Error != nil {
+		return &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(result.Error),
+			Cause:   result.Error,
+		}
+	}
+
+	if result.RowsAffected > 0 && id == userId {
+		return nil
+	}
+	return forbidden("Notifications of another user")
+}
+
+// authorizeTopic checks a topic a multiplexed connection subscribes to, the
+// lobby is open to every user
+func (s *wsService) authorizeTopic(userEmail string, topic string) *reject.ProblemWithTrace {
+	switch {
+	case topic == ws.LobbyTopic:
+		return nil
+	case strings.HasPrefix(topic, "game/"):
+		gameId, err := strconv.ParseUint(strings.TrimPrefix(topic, "game/"), 10, 64)
+		if err != nil {
+			break
+		}
+		return s.authorizeGame(userEmail, gameId)
+	case strings.HasPrefix(topic, "registration/"):
+		return s.authorizeRegistration(userEmail, strings.TrimPrefix(topic, "registration/"))
+	case strings.HasPrefix(topic, "notifications/"):
+		userId, err := strconv.ParseUint(strings.TrimPrefix(topic, "notifications/"), 10, 64)
+		if err != nil {
+			break
+		}
+		return s.authorizeNotifications(userEmail, userId)
+	}
+
+	return &reject.ProblemWithTrace{
+		Problem: reject.NewProblem().
+			WithTitle("Unknown topic").
+			WithStatus(http.StatusNotFound).
+			WithCode(unknownTopic).
+			WithParam("topic", topic).
+			Build(),
+	}
+}
+
+// playerGames returns the id of the user and the games the user created or
+// plays that are not finished
+func (s *wsService) playerGames(userEmail string) (uint64, []uint64, *reject.ProblemWithTrace) {
+	userId, problem := s.userId(userEmail)
+	if problem != nil {
+		return 0, nil, problem
+	}
+
+	var gameIds []uint64
+	result := s.db.Model(&model.Game{}).
+		Where("game_status IN ?", []model.GameStatus{model.GameCreated, model.GamePlaying}).
+		Where("(owner_id = ? OR challenger_id = ?)", userId, userId).
+		Pluck("id", &gameIds)
+	if result.Error != nil {
+		return 0, nil, &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(result.Error),
+			Cause:   result.Error,
+		}
+	}
+
+	return userId, gameIds, nil
+}
+
+func (s *wsService) userId(userEmail string) (uint64, *reject.ProblemWithTrace) {
+	var userId uint64
+	result := s.db.Raw("SELECT u.id FROM battleblocks_user u WHERE email = ?", userEmail).Scan(&userId)
+	if result.Error != nil {
+		return 0, &reject.ProblemWithTrace{
+			Problem: reject.UnexpectedProblem(result.Error),
+			Cause:   result.Error,
+		}
+	}
+	if result.RowsAffected == 0 {
+		return 0, &reject.ProblemWithTrace{Problem: reject.NotFoundProblem()}
+	}
+	return userId, nil
+}
+
+func forbidden(title string) *reject.ProblemWithTrace {
+	return &reject.ProblemWithTrace{
+		Problem: reject.NewProblem().
+			WithTitle(title).
+			WithStatus(http.StatusForbidden).
+			WithCode(topicForbidden).
+			Build(),
+	}
+}
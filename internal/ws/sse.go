@@ -0,0 +1,48 @@
+package ws
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serveSSE streams the topic as Server-Sent Events until the client goes
+// away or the token expires. EventSource cannot set headers, so browsers
+// send the token as the access_token query parameter. Reconnecting browsers
+// send the last event id, the seq of the event, with the Last-Event-ID
+// header, other clients can use the lastSeq query parameter.
+func (wsh *wsHandler) serveSSE(c *gin.Context, topic string, authorize authorizer) {
+	accessToken, problem := wsh.authenticate(tokenFromRequest(c.Request), authorize)
+	if problem != nil {
+		c.JSON(problem.Problem.Status, problem.Problem)
+		return
+	}
+
+	client := wsh.notificationHub.NewSSEClient(c.Writer)
+	defer func() {
+		client.Close()
+		<-client.Stopped()
+	}()
+	wsh.identify(client, accessToken)
+
+	expiry := closeOnExpiry(client, accessToken)
+	defer expiry.Stop()
+
+	defer wsh.notificationHub.UnregisterListener(topic, client)
+
+	lastEventId := c.GetHeader("Last-Event-ID")
+	if lastEventId == "" {
+		lastEventId = c.Query("lastSeq")
+	}
+
+	if lastSeq, err := strconv.ParseUint(lastEventId, 10, 64); err == nil {
+		wsh.notificationHub.RegisterListenerFrom(c.Request.Context(), topic, client, lastSeq)
+	} else {
+		wsh.notificationHub.RegisterListener(topic, client)
+	}
+
+	select {
+	case <-client.Done():
+	case <-c.Request.Context().Done():
+	}
+}
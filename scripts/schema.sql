@@ -36,12 +36,12 @@ CREATE TABLE block
     name       TEXT       NOT NULL,
     block_type BLOCK_TYPE NOT NULL,
     rarity     RARITY     NOT NULL,
-    price      BIGINT     NOT NULL,
+    price      NUMERIC(20, 8) NOT NULL,
     color_hex  CHAR(7),
     stock      BOOL       NOT NULL
 );
 
-CREATE TYPE GAME_STATUS AS enum ('CREATED', 'PREPARING', 'PLAYING', 'FINISHED');
+CREATE TYPE GAME_STATUS AS enum ('CREATED', 'PREPARING', 'PLAYING', 'FINISHED', 'CANCELLED');
 
 CREATE TABLE game
 (
@@ -50,7 +50,7 @@ CREATE TABLE game
     owner_id           BIGINT      NOT NULL,
     challenger_id      BIGINT,
     game_status        GAME_STATUS NOT NULL,
-    stake              BIGINT      NOT NULL,
+    stake              NUMERIC(20, 8) NOT NULL,
     time_started       BIGINT,
     time_created       BIGINT,
     turn               BIGINT,
@@ -124,10 +124,190 @@ CREATE TABLE nft_purchase_history
 CREATE TABLE game_grid_point (
     game_id BIGINT NOT NULL,
     user_id BIGINT NOT NULL,
-    block_present BOOL NOT NULL,
     coordinate_x INTEGER NOT NULL,
     coordinate_y INTEGER NOT NULL,
-    nonce text NOT NULL,
+    secret BYTEA NOT NULL,
 
     PRIMARY KEY (game_id, user_id, coordinate_x, coordinate_y)
 );
+
+CREATE TABLE board_key (
+    game_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    key_id TEXT NOT NULL,
+    wrapped_key BYTEA NOT NULL,
+
+    PRIMARY KEY (game_id, user_id)
+);
+
+CREATE INDEX board_key_key_id_idx ON board_key (key_id);
+
+CREATE TABLE outbox_message (
+    id BIGSERIAL PRIMARY KEY,
+    topic TEXT NOT NULL,
+    ordering_key TEXT,
+    payload BYTEA NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    created_at BIGINT NOT NULL,
+    next_attempt_at BIGINT NOT NULL,
+    sent_at BIGINT
+);
+
+CREATE INDEX outbox_message_pending_idx ON outbox_message (next_attempt_at) WHERE sent_at IS NULL;
+CREATE INDEX outbox_message_ordering_key_idx ON outbox_message (ordering_key, id) WHERE sent_at IS NULL;
+
+CREATE TYPE COMMAND_STATUS AS enum ('PENDING', 'SUBMITTED', 'SEALED', 'FAILED');
+
+CREATE TABLE blockchain_command (
+    id TEXT PRIMARY KEY,
+    type TEXT NOT NULL,
+    reference TEXT,
+    payload JSONB NOT NULL,
+    authorizers JSONB NOT NULL,
+    status COMMAND_STATUS NOT NULL,
+    flow_transaction_id TEXT,
+    error TEXT,
+    created_at BIGINT NOT NULL,
+    updated_at BIGINT NOT NULL
+);
+
+CREATE INDEX blockchain_command_reference_idx ON blockchain_command (type, reference);
+CREATE INDEX blockchain_command_flow_transaction_id_idx ON blockchain_command (flow_transaction_id);
+
+CREATE TYPE LEDGER_ENTRY_TYPE AS enum ('RESERVE', 'RELEASE', 'SETTLE');
+
+CREATE TABLE stake_ledger_entry (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES battleblocks_user (id),
+    game_id BIGINT NOT NULL REFERENCES game (id),
+    entry_type LEDGER_ENTRY_TYPE NOT NULL,
+    amount NUMERIC(20, 8) NOT NULL CHECK (amount >= 0),
+    created_at BIGINT NOT NULL
+);
+
+-- one reservation and at most one closing entry (release or settle) per player and game
+CREATE UNIQUE INDEX stake_ledger_entry_reserve_idx ON stake_ledger_entry (user_id, game_id) WHERE entry_type = 'RESERVE';
+CREATE UNIQUE INDEX stake_ledger_entry_close_idx ON stake_ledger_entry (user_id, game_id) WHERE entry_type <> 'RESERVE';
+CREATE INDEX stake_ledger_entry_game_id_idx ON stake_ledger_entry (game_id);
+
+CREATE TABLE processed_event (
+    consumer TEXT NOT NULL,
+    event_key TEXT NOT NULL,
+    message_id TEXT NOT NULL,
+    processed_at BIGINT NOT NULL,
+
+    PRIMARY KEY (consumer, event_key)
+);
+
+CREATE TYPE DEAD_LETTER_STATUS AS enum ('DEAD', 'REPLAYED');
+
+CREATE TABLE dead_letter (
+    id BIGSERIAL PRIMARY KEY,
+    consumer TEXT NOT NULL,
+    message_id TEXT NOT NULL,
+    event_key TEXT NOT NULL,
+    payload BYTEA NOT NULL,
+    attributes JSONB NOT NULL,
+    error TEXT NOT NULL,
+    permanent BOOL NOT NULL,
+    attempts INTEGER NOT NULL,
+    status DEAD_LETTER_STATUS NOT NULL,
+    created_at BIGINT NOT NULL,
+    updated_at BIGINT NOT NULL
+);
+
+CREATE INDEX dead_letter_status_idx ON dead_letter (status, created_at);
+
+CREATE TYPE GAME_EVENT_TYPE AS enum ('CREATED', 'CHALLENGER_JOINED', 'MOVED', 'GAME_OVER');
+
+CREATE TABLE game_event (
+    id BIGSERIAL PRIMARY KEY,
+    game_id BIGINT NOT NULL REFERENCES game (id),
+    event_type GAME_EVENT_TYPE NOT NULL,
+    turn BIGINT NOT NULL,
+    transaction_id TEXT,
+    event_key TEXT NOT NULL,
+    applied_at BIGINT NOT NULL
+);
+
+-- every turn of a game is applied once
+CREATE UNIQUE INDEX game_event_turn_idx ON game_event (game_id, event_type, turn);
+CREATE INDEX game_event_transaction_id_idx ON game_event (transaction_id);
+
+CREATE TABLE game_discrepancy (
+    id BIGSERIAL PRIMARY KEY,
+    game_id BIGINT NOT NULL REFERENCES game (id),
+    kind TEXT NOT NULL,
+    detail TEXT NOT NULL,
+    repaired BOOL NOT NULL,
+    first_seen_at BIGINT NOT NULL,
+    last_seen_at BIGINT NOT NULL,
+    resolved_at BIGINT
+);
+
+-- one open discrepancy per game and kind, seen again by every reconciliation run
+CREATE UNIQUE INDEX game_discrepancy_open_idx ON game_discrepancy (game_id, kind) WHERE resolved_at IS NULL;
+CREATE INDEX game_discrepancy_last_seen_at_idx ON game_discrepancy (last_seen_at);
+
+-- the turns that timed out, announced once
+CREATE TABLE turn_timeout (
+    game_id BIGINT NOT NULL REFERENCES game (id),
+    turn BIGINT NOT NULL,
+    user_id BIGINT NOT NULL REFERENCES battleblocks_user (id),
+    timed_out_at BIGINT NOT NULL,
+
+    PRIMARY KEY (game_id, turn)
+);
+
+CREATE TABLE websocket_topic (
+    topic TEXT PRIMARY KEY,
+    last_seq BIGINT NOT NULL
+);
+
+-- the last events of every websocket topic, replayed to reconnecting clients
+CREATE TABLE websocket_event (
+    topic TEXT NOT NULL,
+    seq BIGINT NOT NULL,
+    event JSONB NOT NULL,
+    created_at BIGINT NOT NULL,
+
+    PRIMARY KEY (topic, seq)
+);
+
+CREATE INDEX websocket_event_created_at_idx ON websocket_event (created_at);
+
+-- the instances serving websocket clients, an instance without heartbeat is taken for stopped
+CREATE TABLE websocket_instance (
+    id TEXT PRIMARY KEY,
+    seen_at BIGINT NOT NULL
+);
+
+-- whether an instance has a connection of the user to the topic, the topic of the connections to any topic is empty
+CREATE TABLE websocket_presence (
+    instance TEXT NOT NULL,
+    topic TEXT NOT NULL,
+    user_id BIGINT NOT NULL,
+    connected BOOL NOT NULL,
+    updated_at BIGINT NOT NULL,
+
+    PRIMARY KEY (topic, user_id, instance)
+);
+
+CREATE INDEX websocket_presence_instance_idx ON websocket_presence (instance) WHERE connected;
+
+CREATE TYPE NOTIFICATION_TYPE AS enum ('GAME_JOINED', 'OPPONENT_MOVED', 'GAME_WON', 'GAME_LOST', 'NFT_MINTED', 'ACCOUNT_CREATED');
+
+CREATE TABLE notification (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES battleblocks_user (id),
+    type NOTIFICATION_TYPE NOT NULL,
+    reference TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    read_at BIGINT,
+    created_at BIGINT NOT NULL
+);
+
+CREATE INDEX notification_user_id_idx ON notification (user_id, created_at);
+-- unread notifications are counted and collapsed per reference
+CREATE INDEX notification_unread_idx ON notification (user_id, type, reference) WHERE read_at IS NULL;